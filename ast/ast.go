@@ -2,49 +2,172 @@ package ast
 
 import (
 	"errors"
-	"log"
 
 	"github.com/Ghytro/sme/helpers"
 )
 
-var astTree *AstTree
 var ErrPackageAlreadyExists = errors.New("package with this name already exists in ast tree")
 var ErrNoSuchPackage = errors.New("no such package declared in AST tree")
 var ErrNoSuchStruct = errors.New("no such struct declared in this package")
 var ErrStructAlreadyExists = errors.New("struct with this name is already declared in this package")
 var ErrFieldAlreadyExists = errors.New("field with this name was already declared in this struct")
+var ErrNoSuchField = errors.New("no such field declared in this struct")
+var ErrFieldTagAlreadyUsed = errors.New("field with this tag was already declared in this struct")
+var ErrIncorrectFieldTag = errors.New("field tag is out of range")
+
+// the tags are written on the wire as uint16, zero tag means no tag
+const MaxFieldTag = 65535
 
 type AstModuleNode struct {
-	syntaxVer        string
-	cppNamespaceName *string
-	goPackageName    *string
+	syntaxVer string
 
 	children []*AstPackageNode
+	files    []*AstFileNode
+}
+
+// returns the syntax version of the parsed files, it is empty until
+// the first file with the correct header is parsed
+func (mn AstModuleNode) GetSyntaxVer() string {
+	return mn.syntaxVer
+}
+
+func (mn *AstModuleNode) SetSyntaxVer(syntaxVer string) {
+	mn.syntaxVer = syntaxVer
+}
+
+func (mn AstModuleNode) GetPackages() []*AstPackageNode {
+	return mn.children
 }
 
+// the package and the struct nodes are also created when they are
+// referenced by the field type before the declaration, such nodes are
+// not declared until the parser reaches the declaration, the nodes that
+// are never declared are reported by the semantic analysis
 type AstPackageNode struct {
-	name string
+	name     string
+	declared bool
+	// the options of the files of the package, nil if not set
+	cppNamespaceName *string
+	goPackageName    *string
+	javaPackageName  *string
+	pythonModuleName *string
 
 	children []*AstStructNode
+	enums    []*AstEnumNode
 }
 
 func (pn AstPackageNode) GetName() string {
 	return pn.name
 }
 
+func (pn AstPackageNode) IsDeclared() bool {
+	return pn.declared
+}
+
+func (pn AstPackageNode) GetStructs() []*AstStructNode {
+	return pn.children
+}
+
+func (pn AstPackageNode) GetEnums() []*AstEnumNode {
+	return pn.enums
+}
+
+func (pn AstPackageNode) GetCppNamespaceName() *string {
+	return pn.cppNamespaceName
+}
+
+func (pn *AstPackageNode) SetCppNamespaceName(name string) {
+	pn.cppNamespaceName = &name
+}
+
+func (pn AstPackageNode) GetGoPackageName() *string {
+	return pn.goPackageName
+}
+
+func (pn *AstPackageNode) SetGoPackageName(name string) {
+	pn.goPackageName = &name
+}
+
+func (pn AstPackageNode) GetJavaPackageName() *string {
+	return pn.javaPackageName
+}
+
+func (pn *AstPackageNode) SetJavaPackageName(name string) {
+	pn.javaPackageName = &name
+}
+
+func (pn AstPackageNode) GetPythonModuleName() *string {
+	return pn.pythonModuleName
+}
+
+func (pn *AstPackageNode) SetPythonModuleName(name string) {
+	pn.pythonModuleName = &name
+}
+
 type AstStructNode struct {
-	name string
+	name        string
+	packageName string
+	declared    bool
+	position    Position
+	endPosition Position
 
 	children []*AstStructFieldNode
+	reserved []*AstReservedNode
 }
 
 func (sn AstStructNode) GetName() string {
 	return sn.name
 }
 
+func (sn AstStructNode) IsDeclared() bool {
+	return sn.declared
+}
+
+// returns the position of the struct name in the declaration
+func (sn AstStructNode) GetPosition() Position {
+	return sn.position
+}
+
+// returns the position of the closing brace of the struct
+func (sn AstStructNode) GetEndPosition() Position {
+	return sn.endPosition
+}
+
+func (sn *AstStructNode) SetEndPosition(pos Position) {
+	sn.endPosition = pos
+}
+
+func (sn AstStructNode) GetPackageName() string {
+	return sn.packageName
+}
+
+func (sn AstStructNode) GetFields() []*AstStructFieldNode {
+	return sn.children
+}
+
+// tells if the fields of the struct are written with their tags,
+// the struct is tagged if at least one of its fields has the tag
+func (sn AstStructNode) IsTagged() bool {
+	for _, c := range sn.children {
+		if c.tag != 0 {
+			return true
+		}
+	}
+	return false
+}
+
 type AstStructFieldNode struct {
 	fieldType SmeType
 	name      string
+	tag       int
+	position  Position
+	// the field is declared with "= null", that is the same as no default value
+	nullDefault bool
+
+	// the types are shared between the fields by the type pool,
+	// so the positions of the struct names used in the field type
+	// are kept by the field, the keys are "package.Struct"
+	referencePositions map[string]Position
 }
 
 func (sn AstStructFieldNode) GetName() string {
@@ -55,55 +178,145 @@ func (sn AstStructFieldNode) GetFieldType() SmeType {
 	return sn.fieldType
 }
 
-type AstTree struct {
-	root *AstModuleNode
+// returns the tag the field is written with, zero if the field has no tag
+func (sn AstStructFieldNode) GetTag() int {
+	return sn.tag
+}
+
+// tells if the field is declared with "= null"
+func (sn AstStructFieldNode) HasNullDefault() bool {
+	return sn.nullDefault
 }
 
-func InitAstTree(syntaxVer string) {
-	if astTree != nil {
-		log.Fatal("Debug: an attempt to initialize AST tree twice while running a program")
+func (sn *AstStructFieldNode) SetNullDefault() {
+	sn.nullDefault = true
+}
+
+// the type is replaced when the references to the enums are resolved
+func (sn *AstStructFieldNode) SetFieldType(t SmeType) {
+	sn.fieldType = t
+}
+
+// returns the position of the field name
+func (sn AstStructFieldNode) GetPosition() Position {
+	return sn.position
+}
+
+func (sn *AstStructFieldNode) SetPosition(pos Position) {
+	sn.position = pos
+}
+
+// returns the position where the struct is referenced in the field type,
+// the position of the field is returned if the struct is not referenced
+func (sn AstStructFieldNode) GetReferencePosition(n *AstStructNode) Position {
+	if pos, ok := sn.referencePositions[n.packageName+"."+n.name]; ok {
+		return pos
 	}
-	astTree = &AstTree{
-		root: &AstModuleNode{
-			syntaxVer: syntaxVer,
-		},
+	return sn.position
+}
+
+// returns the positions of all the structs and enums referenced in the
+// field type, the keys are "package.Name"
+func (sn AstStructFieldNode) GetReferencePositions() map[string]Position {
+	return sn.referencePositions
+}
+
+func (sn *AstStructFieldNode) SetReferencePosition(qualifiedName string, pos Position) {
+	if sn.referencePositions == nil {
+		sn.referencePositions = make(map[string]Position)
+	}
+	if _, ok := sn.referencePositions[qualifiedName]; !ok {
+		sn.referencePositions[qualifiedName] = pos
 	}
 }
 
+// AstTree is the parsed schema, the types of the fields are shared
+// between the fields of the tree by its type pool, so every tree
+// has its own pool and several trees can be built in one process
+type AstTree struct {
+	root     *AstModuleNode
+	typePool *smeTypePool
+}
+
+// NewAstTree returns the empty tree to parse the schema into
+func NewAstTree() *AstTree {
+	return &AstTree{root: &AstModuleNode{}, typePool: newSmeTypePool()}
+}
+
+func (t AstTree) GetRoot() *AstModuleNode {
+	return t.root
+}
+
 // returns tree node that contains added package
-// if the package exists returns a node with existing package
-func AddPackage(packageName string) (*AstPackageNode, error) {
-	for _, c := range astTree.root.children {
-		if c.name == packageName {
-			return c, ErrPackageAlreadyExists
+// if the package exists returns a node with existing package,
+// the package referenced before is declared by this call
+func (tree *AstTree) AddPackage(packageName string) (*AstPackageNode, error) {
+	if packageNode := tree.findPackage(packageName); packageNode != nil {
+		if packageNode.declared {
+			return packageNode, ErrPackageAlreadyExists
 		}
+		packageNode.declared = true
+		// the packages are kept in the order of declaration
+		packages := tree.root.children
+		for i, c := range packages {
+			if c == packageNode {
+				tree.root.children = append(append(packages[:i:i], packages[i+1:]...), packageNode)
+				break
+			}
+		}
+		return packageNode, nil
 	}
-	newPackageNode := &AstPackageNode{name: packageName}
-	astTree.root.children = append(
-		astTree.root.children,
+	newPackageNode := &AstPackageNode{name: packageName, declared: true}
+	tree.root.children = append(
+		tree.root.children,
 		newPackageNode,
 	)
 	return newPackageNode, nil
 }
 
-// returns tree node that contains added struct
-func AddStruct(packageName string, structName string) (*AstStructNode, error) {
-	packageNode := new(AstPackageNode)
-	for _, c := range astTree.root.children {
+// returns the package node for the reference from the field type,
+// the package is added undeclared if it does not exist
+func (tree *AstTree) referencePackage(packageName string) *AstPackageNode {
+	if packageNode := tree.findPackage(packageName); packageNode != nil {
+		return packageNode
+	}
+	newPackageNode := &AstPackageNode{name: packageName}
+	tree.root.children = append(
+		tree.root.children,
+		newPackageNode,
+	)
+	return newPackageNode
+}
+
+func (tree *AstTree) findPackage(packageName string) *AstPackageNode {
+	for _, c := range tree.root.children {
 		if c.name == packageName {
-			packageNode = c
-			break
+			return c
 		}
 	}
-	if packageNode == nil {
-		return nil, ErrNoSuchPackage
-	}
+	return nil
+}
+
+func findStruct(packageNode *AstPackageNode, structName string) *AstStructNode {
 	for _, c := range packageNode.children {
 		if c.name == structName {
-			return nil, ErrStructAlreadyExists
+			return c
 		}
 	}
-	newStructNode := &AstStructNode{name: structName}
+	return nil
+}
+
+// returns tree node that contains added struct,
+// the struct is not declared until DeclareStruct is called for it
+func (tree *AstTree) AddStruct(packageName string, structName string) (*AstStructNode, error) {
+	packageNode := tree.findPackage(packageName)
+	if packageNode == nil {
+		return nil, ErrNoSuchPackage
+	}
+	if findStruct(packageNode, structName) != nil {
+		return nil, ErrStructAlreadyExists
+	}
+	newStructNode := &AstStructNode{name: structName, packageName: packageName}
 	packageNode.children = append(
 		packageNode.children,
 		newStructNode,
@@ -111,55 +324,84 @@ func AddStruct(packageName string, structName string) (*AstStructNode, error) {
 	return newStructNode, nil
 }
 
-func GetStructNode(packageName string, structName string) (*AstStructNode, error) {
-	packageNode := new(AstPackageNode)
-	for _, c := range astTree.root.children {
-		if c.name == packageName {
-			packageNode = c
-			break
+// returns tree node of the declared struct, the struct referenced before
+// is declared by this call, if the struct is already declared the existing
+// node is returned with ErrStructAlreadyExists
+func (tree *AstTree) DeclareStruct(packageName string, structName string, pos Position) (*AstStructNode, error) {
+	packageNode := tree.findPackage(packageName)
+	if packageNode == nil {
+		return nil, ErrNoSuchPackage
+	}
+	if findEnum(packageNode, structName) != nil {
+		return nil, ErrEnumAlreadyExists
+	}
+	structNode := findStruct(packageNode, structName)
+	if structNode == nil {
+		var err error
+		if structNode, err = tree.AddStruct(packageName, structName); err != nil {
+			return nil, err
+		}
+	} else if structNode.declared {
+		return structNode, ErrStructAlreadyExists
+	} else {
+		// the structs are kept in the order of declaration
+		structs := packageNode.children
+		for i, c := range structs {
+			if c == structNode {
+				packageNode.children = append(append(structs[:i:i], structs[i+1:]...), structNode)
+				break
+			}
 		}
 	}
+	structNode.declared = true
+	structNode.position = pos
+	return structNode, nil
+}
+
+// removes the struct node referenced by the field types, but never declared,
+// it is used when the referenced name turns out to be the name of the enum
+func (tree *AstTree) RemoveUndeclaredStruct(n *AstStructNode) {
+	packageNode := tree.findPackage(n.packageName)
+	if packageNode == nil || n.declared {
+		return
+	}
+	for i, c := range packageNode.children {
+		if c == n {
+			packageNode.children = append(packageNode.children[:i:i], packageNode.children[i+1:]...)
+			return
+		}
+	}
+}
+
+func (tree *AstTree) GetStructNode(packageName string, structName string) (*AstStructNode, error) {
+	packageNode := tree.findPackage(packageName)
 	if packageNode == nil {
 		return nil, ErrNoSuchPackage
 	}
-	for _, c := range packageNode.children {
-		if c.name == structName {
-			return c, nil
-		}
+	if structNode := findStruct(packageNode, structName); structNode != nil {
+		return structNode, nil
 	}
 	return nil, ErrNoSuchStruct
 }
 
-func AddStructField(
+func (tree *AstTree) AddStructField(
 	packageName string,
 	structName string,
 	fieldName string,
 	fieldType SmeType) (*AstStructFieldNode, error) {
-	packageNode := new(AstPackageNode)
-	for _, c := range astTree.root.children {
-		if c.name == packageName {
-			packageNode = c
-			break
-		}
-	}
+	packageNode := tree.findPackage(packageName)
 	if packageNode == nil {
 		return nil, ErrNoSuchPackage
 	}
-
-	structNode := new(AstStructNode)
-	for _, c := range packageNode.children {
-		if c.name == structName {
-			structNode = c
-			break
-		}
-	}
+	structNode := findStruct(packageNode, structName)
 	if structNode == nil {
 		return nil, ErrNoSuchStruct
 	}
-	for _, c := range structNode.children {
-		if c.name == fieldName {
-			return nil, ErrFieldAlreadyExists
-		}
+	if isFieldNameUsed(structNode, fieldName) {
+		return nil, ErrFieldAlreadyExists
+	}
+	if structNode.FindReservedName(fieldName) != nil {
+		return nil, ErrFieldNameReserved
 	}
 
 	newFieldNode := &AstStructFieldNode{name: fieldName, fieldType: fieldType}
@@ -171,22 +413,41 @@ func AddStructField(
 	return newFieldNode, nil
 }
 
+// sets the tag of the field, the tags are unique within the struct and can not be reserved,
+// if the tag is used the field using it is returned with ErrFieldTagAlreadyUsed
+func (tree *AstTree) SetFieldTag(packageName string, structName string, fieldName string, tag int) (*AstStructFieldNode, error) {
+	if tag < 1 || tag > MaxFieldTag {
+		return nil, ErrIncorrectFieldTag
+	}
+	structNode, err := tree.GetStructNode(packageName, structName)
+	if err != nil {
+		return nil, err
+	}
+	if structNode.FindReservedTag(tag) != nil {
+		return nil, ErrFieldTagReserved
+	}
+	var fieldNode *AstStructFieldNode
+	for _, c := range structNode.children {
+		if c.name == fieldName {
+			fieldNode = c
+		} else if c.tag == tag {
+			return c, ErrFieldTagAlreadyUsed
+		}
+	}
+	if fieldNode == nil {
+		return nil, ErrNoSuchField
+	}
+	fieldNode.tag = tag
+	return fieldNode, nil
+}
+
 func GetStructId(n *AstStructNode) uint32 {
 	if n == nil {
 		return 0
 	}
-
-	for _, pNode := range astTree.root.children {
-		for _, sNode := range pNode.children {
-			if sNode == n {
-				hash, err := helpers.HashValuesUint32(pNode.name, sNode.name)
-				if err != nil {
-					return 0
-				}
-				return hash
-			}
-		}
+	hash, err := helpers.HashValuesUint32(n.packageName, n.name)
+	if err != nil {
+		return 0
 	}
-
-	return 0
+	return hash
 }
@@ -1,6 +1,10 @@
 package ast
 
-import "strconv"
+import (
+	"math"
+	"strconv"
+	"strings"
+)
 
 type SmeType interface {
 	Id() uint32
@@ -59,13 +63,17 @@ func (ib *SmeIntegerBase) IsUnsigned() bool {
 	return false
 }
 
-func (ib *SmeIntegerBase) SetDefaultValue(v string) error {
-	if _, err := strconv.ParseUint(v, 10, int(ib.SizeOf())); err != nil {
-		if ib.IsUnsigned() {
-			if _, err := strconv.ParseInt(v, 10, int(ib.SizeOf())); err != nil {
-				return err
-			}
-		}
+// the integer types know their size and signedness, the embedded
+// base does not, so every type passes them to the check
+func (ib *SmeIntegerBase) setIntegerDefaultValue(v string, bitSize int, unsigned bool) error {
+	var err error
+	if unsigned {
+		_, err = strconv.ParseUint(v, 10, bitSize)
+	} else {
+		_, err = strconv.ParseInt(v, 10, bitSize)
+	}
+	if err != nil {
+		return ErrIncorrectDefaultValue
 	}
 	ib.hasDefaultValue = true
 	ib.defaultValue = v
@@ -76,9 +84,12 @@ type SmeFloatingBase struct {
 	SmeBaseType
 }
 
-func (fb *SmeFloatingBase) SetDefaultValue(v string) error {
-	if _, err := strconv.ParseFloat(v, int(fb.SizeOf())); err != nil {
-		return err
+// infinities, NaNs and hex floats are accepted by strconv,
+// but not all the generated languages have the literals for them
+func (fb *SmeFloatingBase) setFloatingDefaultValue(v string, bitSize int) error {
+	f, err := strconv.ParseFloat(v, bitSize)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) || strings.ContainsAny(v, "xX") {
+		return ErrIncorrectDefaultValue
 	}
 	fb.hasDefaultValue = true
 	fb.defaultValue = v
@@ -11,7 +11,7 @@ var errNoDefaultValue = errors.New("type has no default value")
 var errIncorrectType = errors.New("incorrect type specified")
 var errListTypeIncorrectFormat = errors.New("incorrect declaration of list")
 var errMapTypeIncorrectFormat = errors.New("incorrect declaration of map")
-var errIncorrectDefaultValue = errors.New("incorrect default value for the type")
+var ErrIncorrectDefaultValue = errors.New("incorrect default value for the type")
 var errNotParametricType = errors.New("given type is not parametric")
 
 type SmeString struct {
@@ -56,7 +56,7 @@ func (c *SmeChar) SizeOf() uint {
 
 func (c *SmeChar) SetDefaultValue(v string) error {
 	if len(v) != 1 {
-		return errIncorrectDefaultValue
+		return ErrIncorrectDefaultValue
 	}
 	c.hasDefaultValue = true
 	c.defaultValue = v[:1]
@@ -93,7 +93,7 @@ func (b *SmeBool) SetDefaultValue(v string) error {
 		b.defaultValue = "false"
 		return nil
 	}
-	return errIncorrectDefaultValue
+	return ErrIncorrectDefaultValue
 }
 
 type SmeList struct {
@@ -106,7 +106,7 @@ func (l *SmeList) IsParametric() bool {
 }
 
 func (l *SmeList) Id() uint32 {
-	hash, err := helpers.HashValuesUint32(listTypeId, l.valueType, l.isOptional)
+	hash, err := helpers.HashValuesUint32(listTypeId, l.valueType.Id(), l.isOptional)
 	if err != nil {
 		log.Fatalf("Debug: error counting hash in SmeList.Id(), %s", err)
 	}
@@ -136,7 +136,7 @@ func (m *SmeMap) IsParametric() bool {
 }
 
 func (m *SmeMap) Id() uint32 {
-	hash, err := helpers.HashValuesUint32(mapTypeId, m.keyType, m.valueType, m.isOptional)
+	hash, err := helpers.HashValuesUint32(mapTypeId, m.keyType.Id(), m.valueType.Id(), m.isOptional)
 	if err != nil {
 		log.Fatalf("Debug: error counting hash in SmeMap.Id(), %s", err)
 	}
@@ -180,6 +180,10 @@ func (uds *UserDefinedStruct) SetImplNode(n *AstStructNode) {
 	uds.implNode = n
 }
 
+func (uds *UserDefinedStruct) GetImplNode() *AstStructNode {
+	return uds.implNode
+}
+
 const (
 	// primitive types
 	int8TypeId = uint32(iota)
@@ -200,4 +204,8 @@ const (
 	// parametrised types
 	listTypeId
 	mapTypeId
+
+	// user defined types
+	enumTypeId
+	oneofTypeId
 )
@@ -0,0 +1,257 @@
+package ast
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/Ghytro/sme/helpers"
+)
+
+var ErrEnumAlreadyExists = errors.New("enum with this name is already declared in this package")
+var ErrEnumValueAlreadyExists = errors.New("enum value with this name was already declared in this enum")
+var ErrEnumValueNumberAlreadyUsed = errors.New("enum value with this number was already declared in this enum")
+var ErrEnumValueOutOfRange = errors.New("enum value does not fit the underlying type of the enum")
+var ErrNotIntegerType = errors.New("underlying type of the enum must be an integer type")
+var ErrNoSuchEnumValue = errors.New("no such value declared in this enum")
+var ErrNoSuchEnum = errors.New("no such enum declared in this package")
+
+// the integer types, they are the only types the enum can be based on
+type SmeIntegerType interface {
+	SmeType
+	IsUnsigned() bool
+}
+
+type AstEnumNode struct {
+	name           string
+	packageName    string
+	underlyingType SmeIntegerType
+	position       Position
+	endPosition    Position
+
+	values []*AstEnumValueNode
+}
+
+func (en AstEnumNode) GetName() string {
+	return en.name
+}
+
+func (en AstEnumNode) GetPackageName() string {
+	return en.packageName
+}
+
+func (en AstEnumNode) GetUnderlyingType() SmeIntegerType {
+	return en.underlyingType
+}
+
+// returns the position of the enum name in the declaration
+func (en AstEnumNode) GetPosition() Position {
+	return en.position
+}
+
+// returns the position of the closing brace of the enum
+func (en AstEnumNode) GetEndPosition() Position {
+	return en.endPosition
+}
+
+func (en *AstEnumNode) SetEndPosition(pos Position) {
+	en.endPosition = pos
+}
+
+func (en AstEnumNode) GetValues() []*AstEnumValueNode {
+	return en.values
+}
+
+// returns the value with the given name, nil if there is no such value
+func (en AstEnumNode) GetValue(name string) *AstEnumValueNode {
+	for _, v := range en.values {
+		if v.name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+type AstEnumValueNode struct {
+	name     string
+	number   string
+	position Position
+}
+
+func (vn AstEnumValueNode) GetName() string {
+	return vn.name
+}
+
+// returns the number of the value in decimal form
+func (vn AstEnumValueNode) GetNumber() string {
+	return vn.number
+}
+
+func (vn AstEnumValueNode) GetPosition() Position {
+	return vn.position
+}
+
+func findEnum(packageNode *AstPackageNode, enumName string) *AstEnumNode {
+	for _, e := range packageNode.enums {
+		if e.name == enumName {
+			return e
+		}
+	}
+	return nil
+}
+
+// returns tree node of the declared enum, if the enum is already declared
+// the existing node is returned with ErrEnumAlreadyExists
+func (tree *AstTree) DeclareEnum(packageName string, enumName string, underlyingType SmeType, pos Position) (*AstEnumNode, error) {
+	packageNode := tree.findPackage(packageName)
+	if packageNode == nil {
+		return nil, ErrNoSuchPackage
+	}
+	if enumNode := findEnum(packageNode, enumName); enumNode != nil {
+		return enumNode, ErrEnumAlreadyExists
+	}
+	if structNode := findStruct(packageNode, enumName); structNode != nil && structNode.declared {
+		return nil, ErrStructAlreadyExists
+	}
+	integerType, ok := underlyingType.(SmeIntegerType)
+	if !ok {
+		return nil, ErrNotIntegerType
+	}
+	newEnumNode := &AstEnumNode{
+		name:           enumName,
+		packageName:    packageName,
+		underlyingType: integerType,
+		position:       pos,
+	}
+	packageNode.enums = append(packageNode.enums, newEnumNode)
+	return newEnumNode, nil
+}
+
+func (tree *AstTree) GetEnumNode(packageName string, enumName string) (*AstEnumNode, error) {
+	packageNode := tree.findPackage(packageName)
+	if packageNode == nil {
+		return nil, ErrNoSuchPackage
+	}
+	if enumNode := findEnum(packageNode, enumName); enumNode != nil {
+		return enumNode, nil
+	}
+	return nil, ErrNoSuchEnum
+}
+
+// adds the value to the enum, empty number means the number of the
+// previous value plus one, or zero for the first value. If the name or
+// the number is already used the existing value is returned with the error
+func AddEnumValue(enumNode *AstEnumNode, name string, number string, pos Position) (*AstEnumValueNode, error) {
+	if v := enumNode.GetValue(name); v != nil {
+		return v, ErrEnumValueAlreadyExists
+	}
+	bits := int(enumNode.underlyingType.SizeOf()) * 8
+	unsigned := enumNode.underlyingType.IsUnsigned()
+	if number == "" {
+		number = "0"
+		if len(enumNode.values) != 0 {
+			number = nextEnumNumber(enumNode.values[len(enumNode.values)-1].number, unsigned)
+		}
+	}
+	// the number is kept in the canonical decimal form, so +1 and 01 are the same as 1
+	if unsigned {
+		n, err := strconv.ParseUint(number, 10, bits)
+		if err != nil {
+			return nil, ErrEnumValueOutOfRange
+		}
+		number = strconv.FormatUint(n, 10)
+	} else {
+		n, err := strconv.ParseInt(number, 10, bits)
+		if err != nil {
+			return nil, ErrEnumValueOutOfRange
+		}
+		number = strconv.FormatInt(n, 10)
+	}
+	for _, v := range enumNode.values {
+		if v.number == number {
+			return v, ErrEnumValueNumberAlreadyUsed
+		}
+	}
+	newValueNode := &AstEnumValueNode{name: name, number: number, position: pos}
+	enumNode.values = append(enumNode.values, newValueNode)
+	return newValueNode, nil
+}
+
+// the overflow is detected by AddEnumValue parsing the result
+func nextEnumNumber(previous string, unsigned bool) string {
+	if unsigned {
+		n, _ := strconv.ParseUint(previous, 10, 64)
+		if n == ^uint64(0) {
+			return "18446744073709551616"
+		}
+		return strconv.FormatUint(n+1, 10)
+	}
+	n, _ := strconv.ParseInt(previous, 10, 64)
+	if n == int64(^uint64(0)>>1) {
+		return "9223372036854775808"
+	}
+	return strconv.FormatInt(n+1, 10)
+}
+
+// SmeEnum is written on the wire as its underlying integer type
+type SmeEnum struct {
+	SmeBaseType
+	implNode *AstEnumNode
+}
+
+func (e *SmeEnum) IsParametric() bool {
+	return false
+}
+
+func (e *SmeEnum) Id() uint32 {
+	hash, err := helpers.HashValuesUint32(enumTypeId, e.implNode.packageName, e.implNode.name, e.implNode.underlyingType.Id(), e.isOptional)
+	if err != nil {
+		log.Fatalf("Debug: error counting hash in SmeEnum.Id(), %s", err)
+	}
+	return hash
+}
+
+func (e *SmeEnum) SizeOf() uint {
+	return e.implNode.underlyingType.SizeOf()
+}
+
+func (e *SmeEnum) GetImplNode() *AstEnumNode {
+	return e.implNode
+}
+
+// the default value is the name of the enum value
+func (e *SmeEnum) SetDefaultValue(v string) error {
+	if e.implNode.GetValue(v) == nil {
+		return ErrNoSuchEnumValue
+	}
+	e.hasDefaultValue = true
+	e.defaultValue = v
+	return nil
+}
+
+type enumTypeKey struct {
+	implNode        *AstEnumNode
+	isOptional      bool
+	hasDefaultValue bool
+	defaultValue    string
+}
+
+// returns the type of the field holding the enum,
+// the types are shared the same way TypeFromString shares them
+func (tree *AstTree) EnumType(n *AstEnumNode, isOptional bool, hasDefaultValue bool, defaultValue string) (SmeType, error) {
+	key := enumTypeKey{n, isOptional, hasDefaultValue, defaultValue}
+	if t, ok := tree.typePool.enumTypes[key]; ok {
+		return t, nil
+	}
+	t := &SmeEnum{implNode: n}
+	if isOptional {
+		t.SetOptionality()
+	}
+	if hasDefaultValue {
+		if err := t.SetDefaultValue(defaultValue); err != nil {
+			return nil, err
+		}
+	}
+	tree.typePool.enumTypes[key] = t
+	return t, nil
+}
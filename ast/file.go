@@ -0,0 +1,131 @@
+package ast
+
+import "errors"
+
+var ErrNoSuchFile = errors.New("no such file parsed into AST tree")
+
+// AstFileNode is the parsed sme file, the declarations of the file are
+// kept in the package nodes, the file node tells which package the file
+// declares and which files it imports
+type AstFileNode struct {
+	name            string
+	packageName     string
+	packagePosition Position
+
+	imports []*AstImportNode
+	options []*AstOptionNode
+}
+
+func (fn AstFileNode) GetName() string {
+	return fn.name
+}
+
+// returns the name of the package declared in the file,
+// it is empty if the file has no correct package declaration
+func (fn AstFileNode) GetPackageName() string {
+	return fn.packageName
+}
+
+func (fn *AstFileNode) SetPackageName(packageName string) {
+	fn.packageName = packageName
+}
+
+// returns the position of the package name in the package declaration
+func (fn AstFileNode) GetPackagePosition() Position {
+	return fn.packagePosition
+}
+
+func (fn *AstFileNode) SetPackagePosition(pos Position) {
+	fn.packagePosition = pos
+}
+
+func (fn AstFileNode) GetImports() []*AstImportNode {
+	return fn.imports
+}
+
+func (fn AstFileNode) GetOptions() []*AstOptionNode {
+	return fn.options
+}
+
+// AstImportNode is the import statement of the file
+type AstImportNode struct {
+	path     string
+	fileName string
+	position Position
+}
+
+// returns the path as it is written in the import statement
+func (in AstImportNode) GetPath() string {
+	return in.path
+}
+
+// returns the name of the imported file, resolved
+// relative to the directory of the importing file
+func (in AstImportNode) GetFileName() string {
+	return in.fileName
+}
+
+// returns the position of the path in the import statement
+func (in AstImportNode) GetPosition() Position {
+	return in.position
+}
+
+func (mn AstModuleNode) GetFiles() []*AstFileNode {
+	return mn.files
+}
+
+// returns the node of the added file, the file parsed twice
+// gets the new node that replaces the old one
+func (tree *AstTree) AddFile(fileName string) *AstFileNode {
+	newFileNode := &AstFileNode{name: fileName}
+	for i, f := range tree.root.files {
+		if f.name == fileName {
+			tree.root.files[i] = newFileNode
+			return newFileNode
+		}
+	}
+	tree.root.files = append(tree.root.files, newFileNode)
+	return newFileNode
+}
+
+func (tree *AstTree) GetFileNode(fileName string) (*AstFileNode, error) {
+	for _, f := range tree.root.files {
+		if f.name == fileName {
+			return f, nil
+		}
+	}
+	return nil, ErrNoSuchFile
+}
+
+func AddImport(fileNode *AstFileNode, path string, fileName string, pos Position) *AstImportNode {
+	newImportNode := &AstImportNode{path: path, fileName: fileName, position: pos}
+	fileNode.imports = append(fileNode.imports, newImportNode)
+	return newImportNode
+}
+
+// AstOptionNode is the option statement of the file, like
+// option go_package = "github.com/acme/schemas"
+type AstOptionNode struct {
+	name     string
+	value    string
+	position Position
+}
+
+func (on AstOptionNode) GetName() string {
+	return on.name
+}
+
+func (on AstOptionNode) GetValue() string {
+	return on.value
+}
+
+// returns the position of the option name in the option statement
+func (on AstOptionNode) GetPosition() Position {
+	return on.position
+}
+
+func AddOption(fileNode *AstFileNode, name string, value string, pos Position) *AstOptionNode {
+	newOptionNode := &AstOptionNode{name: name, value: value, position: pos}
+	fileNode.options = append(fileNode.options, newOptionNode)
+	return newOptionNode
+}
@@ -26,6 +26,10 @@ func (f *SmeFloat) SizeOf() uint {
 	return 4
 }
 
+func (f *SmeFloat) SetDefaultValue(v string) error {
+	return f.setFloatingDefaultValue(v, 32)
+}
+
 type SmeDouble struct {
 	SmeFloatingBase
 }
@@ -45,3 +49,7 @@ func (d *SmeDouble) Id() uint32 {
 func (d *SmeDouble) SizeOf() uint {
 	return 8
 }
+
+func (d *SmeDouble) SetDefaultValue(v string) error {
+	return d.setFloatingDefaultValue(v, 64)
+}
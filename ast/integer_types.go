@@ -30,6 +30,10 @@ func (i8 *SmeInt8) IsUnsigned() bool {
 	return false
 }
 
+func (i8 *SmeInt8) SetDefaultValue(v string) error {
+	return i8.setIntegerDefaultValue(v, 8, false)
+}
+
 type SmeInt16 struct {
 	SmeIntegerBase
 }
@@ -54,6 +58,10 @@ func (i16 *SmeInt16) IsUnsigned() bool {
 	return false
 }
 
+func (i16 *SmeInt16) SetDefaultValue(v string) error {
+	return i16.setIntegerDefaultValue(v, 16, false)
+}
+
 type SmeInt32 struct {
 	SmeIntegerBase
 }
@@ -78,6 +86,10 @@ func (i32 *SmeInt32) IsUnsigned() bool {
 	return false
 }
 
+func (i32 *SmeInt32) SetDefaultValue(v string) error {
+	return i32.setIntegerDefaultValue(v, 32, false)
+}
+
 type SmeInt64 struct {
 	SmeIntegerBase
 }
@@ -102,6 +114,10 @@ func (i64 *SmeInt64) IsUnsigned() bool {
 	return false
 }
 
+func (i64 *SmeInt64) SetDefaultValue(v string) error {
+	return i64.setIntegerDefaultValue(v, 64, false)
+}
+
 type SmeUint8 struct {
 	SmeIntegerBase
 }
@@ -126,6 +142,10 @@ func (ui8 *SmeUint8) IsUnsigned() bool {
 	return true
 }
 
+func (ui8 *SmeUint8) SetDefaultValue(v string) error {
+	return ui8.setIntegerDefaultValue(v, 8, true)
+}
+
 type SmeUint16 struct {
 	SmeIntegerBase
 }
@@ -150,6 +170,10 @@ func (ui16 *SmeUint16) IsUnsigned() bool {
 	return true
 }
 
+func (ui16 *SmeUint16) SetDefaultValue(v string) error {
+	return ui16.setIntegerDefaultValue(v, 16, true)
+}
+
 type SmeUint32 struct {
 	SmeIntegerBase
 }
@@ -174,6 +198,10 @@ func (ui32 *SmeUint32) IsUnsigned() bool {
 	return true
 }
 
+func (ui32 *SmeUint32) SetDefaultValue(v string) error {
+	return ui32.setIntegerDefaultValue(v, 32, true)
+}
+
 type SmeUint64 struct {
 	SmeIntegerBase
 }
@@ -197,3 +225,7 @@ func (ui64 *SmeUint64) SizeOf() uint {
 func (ui64 *SmeUint64) IsUnsigned() bool {
 	return true
 }
+
+func (ui64 *SmeUint64) SetDefaultValue(v string) error {
+	return ui64.setIntegerDefaultValue(v, 64, true)
+}
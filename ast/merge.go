@@ -0,0 +1,139 @@
+package ast
+
+import "sort"
+
+// DeclarationConflict is the struct or the enum of the merged file whose
+// name is already declared in the tree, Err is ErrStructAlreadyExists if
+// the struct is declared in the tree, ErrEnumAlreadyExists if the enum is
+type DeclarationConflict struct {
+	Err      error
+	Name     string
+	Position Position
+	Declared Position
+}
+
+// MergeFile adds the single file parsed into its own tree to the tree.
+// The declarations are added in the order of the file and the field types
+// are requested from the pool of the tree the same way the parser requests
+// them, so the tree is the same as if the file was parsed into it. The
+// declarations conflicting with the ones of the tree are not added, the
+// syntax version of the file is not checked. The merged tree can not be
+// used after the call, its nodes are moved to the tree
+func (tree *AstTree) MergeFile(file *AstTree) []DeclarationConflict {
+	var conflicts []DeclarationConflict
+	for _, f := range file.root.files {
+		tree.addFileNode(f)
+		if f.packageName == "" {
+			continue
+		}
+		tree.AddPackage(f.packageName)
+		for _, decl := range declarationsOf(file.findPackage(f.packageName)) {
+			var c *DeclarationConflict
+			switch d := decl.(type) {
+			case *AstStructNode:
+				c = tree.mergeStruct(file, d)
+			case *AstEnumNode:
+				c = tree.mergeEnum(file, d)
+			}
+			if c != nil {
+				conflicts = append(conflicts, *c)
+			}
+		}
+	}
+	return conflicts
+}
+
+func (tree *AstTree) addFileNode(fileNode *AstFileNode) {
+	for i, f := range tree.root.files {
+		if f.name == fileNode.name {
+			tree.root.files[i] = fileNode
+			return
+		}
+	}
+	tree.root.files = append(tree.root.files, fileNode)
+}
+
+type declaration interface {
+	GetPosition() Position
+}
+
+// returns the declared structs and the enums of the package in the order of the file
+func declarationsOf(packageNode *AstPackageNode) []declaration {
+	var result []declaration
+	if packageNode == nil {
+		return result
+	}
+	for _, s := range packageNode.children {
+		if s.declared {
+			result = append(result, s)
+		}
+	}
+	for _, e := range packageNode.enums {
+		result = append(result, e)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i].GetPosition(), result[j].GetPosition()
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return result
+}
+
+func (tree *AstTree) mergeStruct(file *AstTree, s *AstStructNode) *DeclarationConflict {
+	structNode, err := tree.DeclareStruct(s.packageName, s.name, s.position)
+	switch err {
+	case nil:
+	case ErrStructAlreadyExists:
+		return &DeclarationConflict{err, s.name, s.position, structNode.position}
+	case ErrEnumAlreadyExists:
+		enumNode, _ := tree.GetEnumNode(s.packageName, s.name)
+		return &DeclarationConflict{err, s.name, s.position, enumNode.position}
+	default:
+		// the package is added above
+		return nil
+	}
+	for _, f := range s.children {
+		if o, ok := f.fieldType.(*SmeOneof); ok {
+			for _, m := range o.implNode.members {
+				m.fieldType = tree.mergeType(file, m.fieldType)
+			}
+			continue
+		}
+		f.fieldType = tree.mergeType(file, f.fieldType)
+	}
+	structNode.children = s.children
+	structNode.reserved = s.reserved
+	structNode.endPosition = s.endPosition
+	return nil
+}
+
+func (tree *AstTree) mergeEnum(file *AstTree, e *AstEnumNode) *DeclarationConflict {
+	enumNode, err := tree.DeclareEnum(e.packageName, e.name, tree.mergeType(file, e.underlyingType), e.position)
+	switch err {
+	case nil:
+	case ErrEnumAlreadyExists:
+		return &DeclarationConflict{err, e.name, e.position, enumNode.position}
+	case ErrStructAlreadyExists:
+		structNode, _ := tree.GetStructNode(e.packageName, e.name)
+		return &DeclarationConflict{err, e.name, e.position, structNode.position}
+	default:
+		// the package is added above and the type is accepted by the file
+		return nil
+	}
+	enumNode.values = e.values
+	enumNode.endPosition = e.endPosition
+	return nil
+}
+
+// returns the type of the tree for the type of the merged file, the
+// structs referenced by the type are added to the tree if they are not there
+func (tree *AstTree) mergeType(file *AstTree, t SmeType) SmeType {
+	result, err := tree.pooledType(file.typePool.keyOf(t))
+	if err != nil {
+		// the arguments are already accepted by the pool of the file
+		return t
+	}
+	return result
+}
@@ -0,0 +1,176 @@
+package ast
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/Ghytro/sme/helpers"
+)
+
+var ErrTooManyOneofMembers = errors.New("too many members declared in this oneof")
+
+// the number of the active member is written on the wire as uint8,
+// zero tells that no member is set
+const MaxOneofMembers = 255
+
+// AstOneofNode is the union of the fields, only one of the members
+// is set at a time. The oneof takes the place of the field in the struct,
+// the type of this field is SmeOneof
+type AstOneofNode struct {
+	name        string
+	packageName string
+	structName  string
+	position    Position
+	endPosition Position
+
+	members []*AstStructFieldNode
+}
+
+func (on AstOneofNode) GetName() string {
+	return on.name
+}
+
+// returns the name of the struct the oneof is declared in
+func (on AstOneofNode) GetStructName() string {
+	return on.structName
+}
+
+func (on AstOneofNode) GetPackageName() string {
+	return on.packageName
+}
+
+func (on AstOneofNode) GetPosition() Position {
+	return on.position
+}
+
+// returns the position of the closing brace of the oneof
+func (on AstOneofNode) GetEndPosition() Position {
+	return on.endPosition
+}
+
+func (on *AstOneofNode) SetEndPosition(pos Position) {
+	on.endPosition = pos
+}
+
+// returns the members in the order of declaration,
+// the number of the member on the wire is its index plus one
+func (on AstOneofNode) GetMembers() []*AstStructFieldNode {
+	return on.members
+}
+
+type SmeOneof struct {
+	SmeBaseType
+	implNode *AstOneofNode
+}
+
+func (o *SmeOneof) IsParametric() bool {
+	return false
+}
+
+func (o *SmeOneof) Id() uint32 {
+	hash, err := helpers.HashValuesUint32(oneofTypeId, o.implNode.packageName, o.implNode.structName, o.implNode.name)
+	if err != nil {
+		log.Fatalf("Debug: error counting hash in SmeOneof.Id(), %s", err)
+	}
+	return hash
+}
+
+func (o *SmeOneof) SizeOf() uint {
+	return 1 // number of the active member
+}
+
+func (o *SmeOneof) SetDefaultValue(v string) error {
+	return ErrIncorrectDefaultValue
+}
+
+func (o *SmeOneof) GetImplNode() *AstOneofNode {
+	return o.implNode
+}
+
+// tells if the name is used by the field or by the member of the oneof,
+// the members are accessed through the struct, so they share the names.
+// The names differing only in case are the same name, because
+// the generated accessors capitalize them, so x and X would clash
+func isFieldNameUsed(structNode *AstStructNode, name string) bool {
+	for _, c := range structNode.children {
+		if strings.EqualFold(c.name, name) {
+			return true
+		}
+		if o, ok := c.fieldType.(*SmeOneof); ok {
+			for _, m := range o.implNode.members {
+				if strings.EqualFold(m.name, name) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// returns the field or the member of the oneof with the name, nil if there is none
+func findField(structNode *AstStructNode, name string) *AstStructFieldNode {
+	for _, c := range structNode.children {
+		if c.name == name {
+			return c
+		}
+		if o, ok := c.fieldType.(*SmeOneof); ok {
+			for _, m := range o.implNode.members {
+				if m.name == name {
+					return m
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// adds the oneof to the struct as the field of SmeOneof type,
+// the members are added with AddOneofMember
+func (tree *AstTree) AddOneof(packageName string, structName string, oneofName string, pos Position) (*AstOneofNode, error) {
+	packageNode := tree.findPackage(packageName)
+	if packageNode == nil {
+		return nil, ErrNoSuchPackage
+	}
+	structNode := findStruct(packageNode, structName)
+	if structNode == nil {
+		return nil, ErrNoSuchStruct
+	}
+	if isFieldNameUsed(structNode, oneofName) {
+		return nil, ErrFieldAlreadyExists
+	}
+	if structNode.FindReservedName(oneofName) != nil {
+		return nil, ErrFieldNameReserved
+	}
+	oneofNode := &AstOneofNode{
+		name:        oneofName,
+		packageName: packageName,
+		structName:  structName,
+		position:    pos,
+	}
+	structNode.children = append(structNode.children, &AstStructFieldNode{
+		name:      oneofName,
+		fieldType: &SmeOneof{implNode: oneofNode},
+		position:  pos,
+	})
+	return oneofNode, nil
+}
+
+func (tree *AstTree) AddOneofMember(oneofNode *AstOneofNode, memberName string, memberType SmeType) (*AstStructFieldNode, error) {
+	structNode, err := tree.GetStructNode(oneofNode.packageName, oneofNode.structName)
+	if err != nil {
+		return nil, err
+	}
+	if isFieldNameUsed(structNode, memberName) {
+		return nil, ErrFieldAlreadyExists
+	}
+	if structNode.FindReservedName(memberName) != nil {
+		return nil, ErrFieldNameReserved
+	}
+	if len(oneofNode.members) == MaxOneofMembers {
+		return nil, ErrTooManyOneofMembers
+	}
+	newMemberNode := &AstStructFieldNode{name: memberName, fieldType: memberType}
+	oneofNode.members = append(oneofNode.members, newMemberNode)
+	return newMemberNode, nil
+}
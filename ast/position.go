@@ -0,0 +1,22 @@
+package ast
+
+import "fmt"
+
+// Position points to the place in the sme file,
+// Line and Column are counted from 1, zero Position means no position
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return p.File
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
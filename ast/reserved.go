@@ -0,0 +1,90 @@
+package ast
+
+import "errors"
+
+var ErrFieldNameReserved = errors.New("field name is reserved in this struct")
+var ErrFieldTagReserved = errors.New("field tag is reserved in this struct")
+
+// the name or the range of tags retired from the struct,
+// the fields of the struct can not use them
+type AstReservedNode struct {
+	name     string
+	from     int
+	to       int
+	position Position
+}
+
+// tells if the name is reserved, otherwise the range of tags is reserved
+func (rn AstReservedNode) IsName() bool {
+	return rn.name != ""
+}
+
+func (rn AstReservedNode) GetName() string {
+	return rn.name
+}
+
+// returns the first and the last reserved tags, both are included
+func (rn AstReservedNode) GetRange() (int, int) {
+	return rn.from, rn.to
+}
+
+func (rn AstReservedNode) GetPosition() Position {
+	return rn.position
+}
+
+func (sn AstStructNode) GetReserved() []*AstReservedNode {
+	return sn.reserved
+}
+
+// returns the reservation of the name, nil if the name is not reserved
+func (sn AstStructNode) FindReservedName(name string) *AstReservedNode {
+	for _, r := range sn.reserved {
+		if r.IsName() && r.name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// returns the reservation of the tag, nil if the tag is not reserved
+func (sn AstStructNode) FindReservedTag(tag int) *AstReservedNode {
+	for _, r := range sn.reserved {
+		if !r.IsName() && r.from <= tag && tag <= r.to {
+			return r
+		}
+	}
+	return nil
+}
+
+// reserves the field name in the struct, the name is reserved even if
+// it is used, then the field using it is returned with ErrFieldNameReserved
+func (tree *AstTree) ReserveFieldName(packageName string, structName string, name string, pos Position) (*AstStructFieldNode, error) {
+	structNode, err := tree.GetStructNode(packageName, structName)
+	if err != nil {
+		return nil, err
+	}
+	structNode.reserved = append(structNode.reserved, &AstReservedNode{name: name, position: pos})
+	if fieldNode := findField(structNode, name); fieldNode != nil {
+		return fieldNode, ErrFieldNameReserved
+	}
+	return nil, nil
+}
+
+// reserves the tags from the range in the struct, the tags are reserved even if
+// one of them is used, then the field using it is returned with ErrFieldTagReserved
+func (tree *AstTree) ReserveFieldTags(packageName string, structName string, from int, to int, pos Position) (*AstStructFieldNode, error) {
+	if from < 1 || to > MaxFieldTag || from > to {
+		return nil, ErrIncorrectFieldTag
+	}
+	structNode, err := tree.GetStructNode(packageName, structName)
+	if err != nil {
+		return nil, err
+	}
+	structNode.reserved = append(structNode.reserved, &AstReservedNode{from: from, to: to, position: pos})
+	for _, c := range structNode.children {
+		if c.tag != 0 && from <= c.tag && c.tag <= to {
+			return c, ErrFieldTagReserved
+		}
+	}
+	return nil, nil
+}
@@ -3,6 +3,7 @@ package ast
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/Ghytro/sme/helpers"
@@ -14,6 +15,26 @@ import (
 type smeTypePool struct {
 	requiredTypes *requiredTypesNode
 	optionalTypes *optionalTypesNode
+	enumTypes     map[enumTypeKey]SmeType
+	// the arguments the types were added with, so the same
+	// type can be requested from the pool of the other tree
+	keys map[SmeType]typeKey
+}
+
+type typeKey struct {
+	typeName        string
+	isOptional      bool
+	hasDefaultValue bool
+	defaultValue    interface{}
+}
+
+// returns the arguments the type was added to the pool with, the primitive
+// types with no default value are in the pool from the start
+func (tp *smeTypePool) keyOf(t SmeType) typeKey {
+	if key, ok := tp.keys[t]; ok {
+		return key
+	}
+	return typeKey{typeName: TypeName(t), isOptional: t.IsOptional()}
 }
 
 var errNoSuchType = errors.New("no such type added to pool")
@@ -47,7 +68,7 @@ func (tp *smeTypePool) getType(typeName string, isOptional bool, hasDefaultValue
 	}
 }
 
-func newSmeTypeByName(typeName string, isOptional, hasDefaulValue bool, defaultValue interface{}) (SmeType, error) {
+func (tree *AstTree) newSmeTypeByName(typeName string, isOptional, hasDefaulValue bool, defaultValue interface{}) (SmeType, error) {
 	var baseType SmeType
 	if IsPrimitiveTypeName(typeName) {
 		switch typeName {
@@ -85,52 +106,65 @@ func newSmeTypeByName(typeName string, isOptional, hasDefaulValue bool, defaultV
 			switch v := defaultValue.(type) {
 			case string:
 				if v != "" {
-					baseType.SetDefaultValue(v)
+					if err := baseType.SetDefaultValue(v); err != nil {
+						return nil, fmt.Errorf("%w %s: %s", ErrIncorrectDefaultValue, typeName, v)
+					}
 				}
 			}
 		}
 		return baseType, nil
 	}
 	if IsParametricTypeName(typeName) {
-		if strings.HasPrefix(typeName, "list") {
+		if isListTypeName(typeName) {
 			baseType = &SmeList{}
 			valueTypeName, err := getListValueType(typeName)
 			if err != nil {
 				return nil, err
 			}
-			valueType, err := TypeFromString("", valueTypeName, false, false, nil)
+			valueType, err := tree.TypeFromString("", valueTypeName, false, false, nil)
 			if err != nil {
 				return nil, err
 			}
 			baseType.(*SmeList).SetValueType(valueType)
+			if isOptional {
+				baseType.SetOptionality()
+			}
 			return baseType, nil
 		}
-		if strings.HasPrefix(typeName, "map") {
+		if isMapTypeName(typeName) {
 			baseType = &SmeMap{}
 			keyTypeName, valueTypeName, err := getMapKeyValueTypes(typeName)
 			if err != nil {
 				return nil, err
 			}
-			keyType, err := TypeFromString("", keyTypeName, false, false, nil)
+			keyType, err := tree.TypeFromString("", keyTypeName, false, false, nil)
 			if err != nil {
 				return nil, err
 			}
-			valueType, err := TypeFromString("", valueTypeName, false, false, nil)
+			valueType, err := tree.TypeFromString("", valueTypeName, false, false, nil)
 			if err != nil {
 				return nil, err
 			}
 			baseType.(*SmeMap).SetKeyType(keyType)
 			baseType.(*SmeMap).SetValueType(valueType)
+			if isOptional {
+				baseType.SetOptionality()
+			}
 			return baseType, nil
 		}
 	}
 	baseType = &UserDefinedStruct{}
 	splittedTypeName := strings.Split(typeName, ".")
 	packageName, structName := splittedTypeName[0], splittedTypeName[1]
-	node, err := AddStruct(packageName, structName)
+	// the struct may be declared later or in the other file,
+	// so the undeclared nodes are added and checked after parsing.
+	// The name may also be the name of the enum, such references are
+	// replaced with SmeEnum after parsing, so the default value is kept
+	tree.referencePackage(packageName)
+	node, err := tree.AddStruct(packageName, structName)
 	if err != nil {
 		if err == ErrStructAlreadyExists {
-			node, err = GetStructNode(packageName, structName)
+			node, err = tree.GetStructNode(packageName, structName)
 			if err != nil {
 				return nil, err
 			}
@@ -139,15 +173,20 @@ func newSmeTypeByName(typeName string, isOptional, hasDefaulValue bool, defaultV
 		}
 	}
 	baseType.(*UserDefinedStruct).SetImplNode(node)
+	if isOptional {
+		baseType.SetOptionality()
+	}
+	if v, ok := defaultValue.(string); hasDefaulValue && ok {
+		if err := baseType.SetDefaultValue(v); err != nil {
+			return nil, fmt.Errorf("%w %s: %s", ErrIncorrectDefaultValue, typeName, v)
+		}
+	}
 	return baseType, nil
 }
 
 // only unwrapped type name should be passed
-func (tp *smeTypePool) addType(typeName string, isOptional, hasDefaultValue bool, defaultValue interface{}) (SmeType, error) {
-	t, err := newSmeTypeByName(typeName, isOptional, hasDefaultValue, defaultValue)
-	if err != nil {
-		return nil, err
-	}
+func (tp *smeTypePool) addType(typeName string, isOptional, hasDefaultValue bool, defaultValue interface{}, t SmeType) {
+	tp.keys[t] = typeKey{typeName, isOptional, hasDefaultValue, defaultValue}
 	if isOptional {
 		if hasDefaultValue {
 			if _, ok := tp.optionalTypes.defaultValueTypes[typeName]; !ok {
@@ -167,13 +206,14 @@ func (tp *smeTypePool) addType(typeName string, isOptional, hasDefaultValue bool
 			tp.requiredTypes.noDefaultValueTypes[typeName] = t
 		}
 	}
-	return t, nil
 }
 
 func newSmeTypePool() *smeTypePool {
 	result := new(smeTypePool)
 	result.requiredTypes = newRequiredTypesNode()
 	result.optionalTypes = newOptionalTypesNode()
+	result.enumTypes = make(map[enumTypeKey]SmeType)
+	result.keys = make(map[SmeType]typeKey)
 	return result
 }
 
@@ -231,18 +271,26 @@ func newOptionalTypesNode() *optionalTypesNode {
 
 type optionalDefaultValueTypes map[string]map[interface{}]SmeType
 
-var typePool = newSmeTypePool()
+var primitiveTypeNamePattern = regexp.MustCompile(`^(u?int(8|16|32|64)|float|double|string|bool|char)$`)
 
 func IsPrimitiveTypeName(typeName string) bool {
-	result, err := helpers.MatchString(`u?int(8|16|32|64)|float|double|string|bool|char`, typeName)
-	if err != nil {
-		helpers.PrintError("debug: error compiling regex at isPrimitiveTypeName")
-	}
-	return result
+	return primitiveTypeNamePattern.MatchString(typeName)
 }
 
+// the parametric type names are built by the parser with the brackets
+// right after the keyword, the names of the structs and the packages
+// can not contain the brackets, so a package named maps or lists
+// is not mistaken for the parametric type
 func IsParametricTypeName(typeName string) bool {
-	return strings.HasPrefix(typeName, "map") || strings.HasPrefix(typeName, "list")
+	return isMapTypeName(typeName) || isListTypeName(typeName)
+}
+
+func isMapTypeName(typeName string) bool {
+	return strings.HasPrefix(typeName, "map[")
+}
+
+func isListTypeName(typeName string) bool {
+	return strings.HasPrefix(typeName, "list[")
 }
 
 func unwrapTypeName(packageName, typeName string) (string, error) {
@@ -280,12 +328,26 @@ func getMapKeyValueTypes(mapTypeName string) (string, string, error) {
 		return "", "", errIncorrectType
 	}
 	bracketsContent := mapTypeName[len("map[") : len(mapTypeName)-1]
-	splittedBracketsContent := strings.Split(bracketsContent, ",")
-	return splittedBracketsContent[0], splittedBracketsContent[1], nil
+	// the key and the value may be parametric types themselves,
+	// so only the comma outside of the nested brackets is a separator
+	depth := 0
+	for i, c := range bracketsContent {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				return bracketsContent[:i], bracketsContent[i+1:], nil
+			}
+		}
+	}
+	return "", "", errMapTypeIncorrectFormat
 }
 
 func unwrapParametricTypeName(packageName, typeName string) (string, error) {
-	if strings.HasPrefix(typeName, "map") {
+	if isMapTypeName(typeName) {
 		keyType, valueType, err := getMapKeyValueTypes(typeName)
 		if err != nil {
 			return "", err
@@ -300,7 +362,7 @@ func unwrapParametricTypeName(packageName, typeName string) (string, error) {
 		}
 		return fmt.Sprintf("map[%s,%s]", unwrappedKey, unwrappedValue), nil
 	}
-	if strings.HasPrefix(typeName, "list") {
+	if isListTypeName(typeName) {
 		valueType, err := getListValueType(typeName)
 		if err != nil {
 			return "", err
@@ -314,17 +376,74 @@ func unwrapParametricTypeName(packageName, typeName string) (string, error) {
 	return "", errNotParametricType
 }
 
-func TypeFromString(packageName, typeName string, isOptional bool, hasDefaultValue bool, defaultValue interface{}) (SmeType, error) {
+func (tree *AstTree) TypeFromString(packageName, typeName string, isOptional bool, hasDefaultValue bool, defaultValue interface{}) (SmeType, error) {
 	typeName, err := unwrapTypeName(packageName, typeName)
 	if err != nil {
 		return nil, err
 	}
-	t, err := typePool.getType(typeName, isOptional, hasDefaultValue, defaultValue)
+	return tree.pooledType(typeKey{typeName, isOptional, hasDefaultValue, defaultValue})
+}
+
+func (tree *AstTree) pooledType(key typeKey) (SmeType, error) {
+	t, err := tree.typePool.getType(key.typeName, key.isOptional, key.hasDefaultValue, key.defaultValue)
 	if err != nil {
-		t, err = typePool.addType(typeName, isOptional, hasDefaultValue, defaultValue)
+		t, err = tree.newSmeTypeByName(key.typeName, key.isOptional, key.hasDefaultValue, key.defaultValue)
 		if err != nil {
 			return nil, err
 		}
+		tree.typePool.addType(key.typeName, key.isOptional, key.hasDefaultValue, key.defaultValue, t)
 	}
 	return t, nil
 }
+
+// the names of the primitive types as they are written in the schema
+var PrimitiveTypeNames = []string{
+	"int8", "int16", "int32", "int64",
+	"uint8", "uint16", "uint32", "uint64",
+	"float", "double", "string", "char", "bool",
+}
+
+// TypeName returns the type as it is written in the schema, the structs
+// and the enums are written with their package names, the optionality
+// and the default value are not the part of the name
+func TypeName(t SmeType) string {
+	switch v := t.(type) {
+	case *SmeInt8:
+		return "int8"
+	case *SmeInt16:
+		return "int16"
+	case *SmeInt32:
+		return "int32"
+	case *SmeInt64:
+		return "int64"
+	case *SmeUint8:
+		return "uint8"
+	case *SmeUint16:
+		return "uint16"
+	case *SmeUint32:
+		return "uint32"
+	case *SmeUint64:
+		return "uint64"
+	case *SmeFloat:
+		return "float"
+	case *SmeDouble:
+		return "double"
+	case *SmeString:
+		return "string"
+	case *SmeChar:
+		return "char"
+	case *SmeBool:
+		return "bool"
+	case *SmeList:
+		return "list[" + TypeName(v.ValueType()) + "]"
+	case *SmeMap:
+		return "map[" + TypeName(v.KeyType()) + ", " + TypeName(v.ValueType()) + "]"
+	case *UserDefinedStruct:
+		return v.GetImplNode().GetPackageName() + "." + v.GetImplNode().GetName()
+	case *SmeEnum:
+		return v.GetImplNode().GetPackageName() + "." + v.GetImplNode().GetName()
+	case *SmeOneof:
+		return "oneof"
+	}
+	return "unknown"
+}
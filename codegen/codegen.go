@@ -0,0 +1,74 @@
+// Package codegen connects the command line with the code generators.
+// Every backend registers itself in init function with Register,
+// so adding the new output language needs only the import of its package:
+//
+//	import _ "github.com/Ghytro/sme/codegen/golang"
+package codegen
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Ghytro/sme/ast"
+)
+
+var ErrNoAstTree = errors.New("no AST tree to generate the code from")
+
+type Generator interface {
+	Generate(tree *ast.AstTree, outDir string, opts Options) error
+}
+
+// Options are the settings of the generation passed to the generator
+type Options struct {
+	// backend specific parameters, every backend documents the ones it reads
+	Params map[string]string
+}
+
+func (o Options) Param(name string) string {
+	return o.Params[name]
+}
+
+var (
+	generatorsMu sync.RWMutex
+	generators   = make(map[string]Generator)
+)
+
+// Register makes the generator available by the language name,
+// it panics if the generator for the language is already registered
+func Register(lang string, g Generator) {
+	generatorsMu.Lock()
+	defer generatorsMu.Unlock()
+	if g == nil {
+		panic("codegen: Register generator is nil")
+	}
+	if _, dup := generators[lang]; dup {
+		panic(fmt.Sprintf("codegen: Register called twice for language %s", lang))
+	}
+	generators[lang] = g
+}
+
+// returns the registered generator of the language, if there is no such
+// generator, looks for sme-gen-<lang> plugin in PATH
+func Lookup(lang string) (Generator, bool) {
+	generatorsMu.RLock()
+	g, ok := generators[lang]
+	generatorsMu.RUnlock()
+	if ok {
+		return g, true
+	}
+	return lookupPlugin(lang)
+}
+
+// returns the sorted names of registered languages, plugins are not included
+func Languages() []string {
+	generatorsMu.RLock()
+	defer generatorsMu.RUnlock()
+	result := make([]string, 0, len(generators))
+	for lang := range generators {
+		result = append(result, lang)
+	}
+	sort.Strings(result)
+	return result
+}
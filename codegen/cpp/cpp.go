@@ -0,0 +1,928 @@
+// Package cpp generates C++17 headers for the parsed sme schemas.
+// Every sme package becomes a header with the enum classes and the
+// classes derived from sme::BaseSmeStruct, the shared base classes and
+// the serialization routines are written once into sme_base.h
+package cpp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Ghytro/sme/ast"
+	"github.com/Ghytro/sme/codegen"
+)
+
+var ErrUnsupportedType = errors.New("type is not supported by the c++ generator")
+var ErrUnsupportedMapKey = errors.New("only primitive types can be used as map keys in c++")
+
+const baseHeaderName = "sme_base.h"
+
+func init() {
+	codegen.Register("cpp", Generator{})
+}
+
+type Generator struct{}
+
+// Generate writes sme_base.h and one <package name>.h per package of the tree into outDir,
+// the "namespace" parameter is the namespace the packages are declared in,
+// without it the package is declared in its cpp_namespace option
+func (Generator) Generate(tree *ast.AstTree, outDir string, opts codegen.Options) error {
+	if tree == nil {
+		return codegen.ErrNoAstTree
+	}
+	root := tree.GetRoot()
+	if err := os.WriteFile(filepath.Join(outDir, baseHeaderName), []byte(baseHeader), 0644); err != nil {
+		return err
+	}
+	namespaces := packageNamespaces(root, opts.Param("namespace"))
+	for _, p := range root.GetPackages() {
+		src, err := generatePackage(root, p, namespaces)
+		if err != nil {
+			return fmt.Errorf("package %s: %w", p.GetName(), err)
+		}
+		if err := os.WriteFile(filepath.Join(outDir, p.GetName()+".h"), src, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type headerGenerator struct {
+	body        bytes.Buffer
+	packageName string
+	// the namespaces of the packages by their names
+	namespaces map[string]string
+	includes   map[string]bool
+}
+
+// the package is declared in the namespace named after it inside the
+// namespace given by the parameter, or in its cpp_namespace option,
+// or in the namespace named after it
+func packageNamespaces(root *ast.AstModuleNode, namespace string) map[string]string {
+	result := make(map[string]string)
+	for _, p := range root.GetPackages() {
+		switch {
+		case namespace != "":
+			result[p.GetName()] = namespace + "::" + p.GetName()
+		case p.GetCppNamespaceName() != nil:
+			result[p.GetName()] = *p.GetCppNamespaceName()
+		default:
+			result[p.GetName()] = p.GetName()
+		}
+	}
+	return result
+}
+
+func generatePackage(root *ast.AstModuleNode, p *ast.AstPackageNode, namespaces map[string]string) ([]byte, error) {
+	g := &headerGenerator{
+		packageName: p.GetName(),
+		namespaces:  namespaces,
+		includes:    make(map[string]bool),
+	}
+	for _, e := range p.GetEnums() {
+		g.writeEnum(e)
+	}
+	// the classes are declared first, so the optional fields,
+	// lists and maps can hold the classes defined below
+	for _, s := range p.GetStructs() {
+		fmt.Fprintf(&g.body, "class %s;\n", s.GetName())
+	}
+	g.body.WriteString("\n")
+	for _, s := range sortByDependencies(p.GetStructs()) {
+		if err := g.writeClass(s); err != nil {
+			return nil, fmt.Errorf("struct %s: %w", s.GetName(), err)
+		}
+	}
+
+	guard := strings.ToUpper(p.GetName()) + "_SME_H"
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "// Code generated by sme from syntax %s. DO NOT EDIT.\n\n", root.GetSyntaxVer())
+	fmt.Fprintf(&file, "#ifndef %s\n#define %s\n\n", guard, guard)
+	file.WriteString("#include <cstdint>\n#include <optional>\n#include <string>\n#include <unordered_map>\n#include <variant>\n#include <vector>\n\n")
+	fmt.Fprintf(&file, "#include %q\n", baseHeaderName)
+	includes := make([]string, 0, len(g.includes))
+	for inc := range g.includes {
+		includes = append(includes, inc)
+	}
+	sort.Strings(includes)
+	for _, inc := range includes {
+		fmt.Fprintf(&file, "#include %q\n", inc)
+	}
+	fmt.Fprintf(&file, "\nnamespace %s {\n\n", namespaces[p.GetName()])
+	file.Write(g.body.Bytes())
+	fmt.Fprintf(&file, "} // namespace %s\n\n#endif // %s\n", namespaces[p.GetName()], guard)
+	return file.Bytes(), nil
+}
+
+// c++ needs the nested structs to be defined before
+// the structs that hold them by value, so the structs are ordered
+// the way every struct goes after its dependencies
+func sortByDependencies(structs []*ast.AstStructNode) []*ast.AstStructNode {
+	visited := make(map[*ast.AstStructNode]bool)
+	inPackage := make(map[*ast.AstStructNode]bool)
+	for _, s := range structs {
+		inPackage[s] = true
+	}
+	result := make([]*ast.AstStructNode, 0, len(structs))
+	var visit func(s *ast.AstStructNode)
+	visit = func(s *ast.AstStructNode) {
+		if visited[s] {
+			return
+		}
+		visited[s] = true
+		for _, f := range s.GetFields() {
+			dep := embeddedStruct(f.GetFieldType())
+			if dep != nil && inPackage[dep] {
+				visit(dep)
+			}
+		}
+		result = append(result, s)
+	}
+	for _, s := range structs {
+		visit(s)
+	}
+	return result
+}
+
+// the optional structs are kept in ::sme::Box, std::vector and
+// std::unordered_map allocate the values too, so only the required
+// struct fields need the struct to be defined
+func embeddedStruct(t ast.SmeType) *ast.AstStructNode {
+	if v, ok := t.(*ast.UserDefinedStruct); ok && !v.IsOptional() {
+		return v.GetImplNode()
+	}
+	return nil
+}
+
+func upperFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+var cppKeywords = map[string]bool{
+	"alignas": true, "alignof": true, "and": true, "asm": true, "auto": true,
+	"bool": true, "break": true, "case": true, "catch": true, "char": true,
+	"class": true, "const": true, "constexpr": true, "continue": true,
+	"default": true, "delete": true, "do": true, "double": true, "else": true,
+	"enum": true, "explicit": true, "export": true, "extern": true, "false": true,
+	"float": true, "for": true, "friend": true, "goto": true, "if": true,
+	"inline": true, "int": true, "long": true, "mutable": true, "namespace": true,
+	"new": true, "noexcept": true, "not": true, "nullptr": true, "operator": true,
+	"or": true, "private": true, "protected": true, "public": true,
+	"register": true, "return": true, "short": true, "signed": true,
+	"sizeof": true, "static": true, "struct": true, "switch": true,
+	"template": true, "this": true, "throw": true, "true": true, "try": true,
+	"typedef": true, "typename": true, "union": true, "unsigned": true,
+	"using": true, "virtual": true, "void": true, "volatile": true, "while": true,
+}
+
+func memberName(fieldName string) string {
+	if cppKeywords[fieldName] {
+		return fieldName + "_"
+	}
+	return fieldName
+}
+
+func (g *headerGenerator) structTypeName(n *ast.AstStructNode) string {
+	if n.GetPackageName() == g.packageName {
+		return n.GetName()
+	}
+	g.includes[n.GetPackageName()+".h"] = true
+	return "::" + g.namespaces[n.GetPackageName()] + "::" + n.GetName()
+}
+
+func (g *headerGenerator) enumTypeName(n *ast.AstEnumNode) string {
+	if n.GetPackageName() == g.packageName {
+		return n.GetName()
+	}
+	g.includes[n.GetPackageName()+".h"] = true
+	return "::" + g.namespaces[n.GetPackageName()] + "::" + n.GetName()
+}
+
+func (g *headerGenerator) writeEnum(e *ast.AstEnumNode) {
+	underlying, _ := g.valueTypeName(e.GetUnderlyingType())
+	fmt.Fprintf(&g.body, "enum class %s: %s {\n", e.GetName(), underlying)
+	for _, v := range e.GetValues() {
+		literal, _ := defaultValueLiteral(e.GetUnderlyingType(), v.GetNumber())
+		fmt.Fprintf(&g.body, "    %s = %s,\n", memberName(v.GetName()), literal)
+	}
+	g.body.WriteString("};\n\n")
+}
+
+// returns the c++ type of the value, ignoring the optionality
+func (g *headerGenerator) valueTypeName(t ast.SmeType) (string, error) {
+	switch v := t.(type) {
+	case *ast.SmeInt8:
+		return "int8_t", nil
+	case *ast.SmeInt16:
+		return "int16_t", nil
+	case *ast.SmeInt32:
+		return "int32_t", nil
+	case *ast.SmeInt64:
+		return "int64_t", nil
+	case *ast.SmeUint8:
+		return "uint8_t", nil
+	case *ast.SmeUint16:
+		return "uint16_t", nil
+	case *ast.SmeUint32:
+		return "uint32_t", nil
+	case *ast.SmeUint64:
+		return "uint64_t", nil
+	case *ast.SmeFloat:
+		return "float", nil
+	case *ast.SmeDouble:
+		return "double", nil
+	case *ast.SmeString:
+		return "std::string", nil
+	case *ast.SmeChar:
+		return "char", nil
+	case *ast.SmeBool:
+		return "bool", nil
+	case *ast.SmeList:
+		elem, err := g.valueTypeName(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return "std::vector<" + elem + ">", nil
+	case *ast.SmeMap:
+		if !isPrimitive(v.KeyType()) {
+			return "", ErrUnsupportedMapKey
+		}
+		key, err := g.valueTypeName(v.KeyType())
+		if err != nil {
+			return "", err
+		}
+		value, err := g.valueTypeName(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return "std::unordered_map<" + key + ", " + value + ">", nil
+	case *ast.UserDefinedStruct:
+		return g.structTypeName(v.GetImplNode()), nil
+	case *ast.SmeEnum:
+		return g.enumTypeName(v.GetImplNode()), nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// the optional structs are kept on the heap in ::sme::Box,
+// so the struct can hold itself through the optional field
+func (g *headerGenerator) fieldTypeName(t ast.SmeType) (string, error) {
+	name, err := g.valueTypeName(t)
+	if err != nil {
+		return "", err
+	}
+	if !t.IsOptional() {
+		return name, nil
+	}
+	if _, ok := t.(*ast.UserDefinedStruct); ok {
+		return "::sme::Box<" + name + ">", nil
+	}
+	return "std::optional<" + name + ">", nil
+}
+
+func isPrimitive(t ast.SmeType) bool {
+	switch t.(type) {
+	case *ast.SmeList, *ast.SmeMap, *ast.UserDefinedStruct, *ast.SmeOneof:
+		return false
+	}
+	return true
+}
+
+// scalars are passed by value, everything else by const reference
+func isScalar(t ast.SmeType) bool {
+	switch t.(type) {
+	case *ast.SmeString, *ast.SmeList, *ast.SmeMap, *ast.UserDefinedStruct:
+		return false
+	}
+	return true
+}
+
+func (g *headerGenerator) writeClass(s *ast.AstStructNode) error {
+	name := s.GetName()
+	fmt.Fprintf(&g.body, "class %s: public ::sme::BaseSmeStruct {\npublic:\n", name)
+	fmt.Fprintf(&g.body, "    %s() {\n    }\n\n", name)
+
+	var members bytes.Buffer
+	for _, f := range s.GetFields() {
+		t := f.GetFieldType()
+		if o, ok := t.(*ast.SmeOneof); ok {
+			if err := g.writeOneofAccessors(o.GetImplNode(), &members); err != nil {
+				return fmt.Errorf("oneof %s: %w", f.GetName(), err)
+			}
+			continue
+		}
+		typeName, err := g.fieldTypeName(t)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.GetName(), err)
+		}
+		valueTypeName, err := g.valueTypeName(t)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.GetName(), err)
+		}
+		member := memberName(f.GetName())
+		accessor := upperFirst(f.GetName())
+		paramType := valueTypeName
+		if !isScalar(t) {
+			paramType = "const " + valueTypeName + "&"
+		}
+
+		if isScalar(t) && !t.IsOptional() {
+			fmt.Fprintf(&g.body, "    %s Get%s() const {\n        return %s;\n    }\n", typeName, accessor, member)
+		} else {
+			fmt.Fprintf(&g.body, "    const %s& Get%s() const {\n        return %s;\n    }\n", typeName, accessor, member)
+			fmt.Fprintf(&g.body, "    %s& Get%s() {\n        return %s;\n    }\n", typeName, accessor, member)
+		}
+		fmt.Fprintf(&g.body, "    void Set%s(%s value) {\n        %s = value;\n    }\n", accessor, paramType, member)
+		if t.IsOptional() {
+			fmt.Fprintf(&g.body, "    bool Has%s() const {\n        return %s.has_value();\n    }\n", accessor, member)
+			fmt.Fprintf(&g.body, "    void Clear%s() {\n        %s.reset();\n    }\n", accessor, member)
+		}
+		g.body.WriteString("\n")
+
+		initializer := "{}"
+		if v, err := t.DefaultValue(); err == nil {
+			literal, err := g.defaultValueLiteral(t, v)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", f.GetName(), err)
+			}
+			initializer = " = " + literal
+		}
+		fmt.Fprintf(&members, "    %s %s%s;\n", typeName, member, initializer)
+	}
+
+	if s.IsTagged() {
+		g.writeTaggedSerialization(s)
+	} else {
+		g.body.WriteString("    void FromIstream(std::istream& is) override {\n")
+		for _, f := range s.GetFields() {
+			fmt.Fprintf(&g.body, "        ::sme::Read(is, %s);\n", memberName(f.GetName()))
+		}
+		g.body.WriteString("    }\n\n")
+		g.body.WriteString("    void WriteToOstream(std::ostream& os) const override {\n")
+		for _, f := range s.GetFields() {
+			fmt.Fprintf(&g.body, "        ::sme::Write(os, %s);\n", memberName(f.GetName()))
+		}
+		g.body.WriteString("    }\n\n")
+	}
+
+	g.body.WriteString("private:\n")
+	g.body.Write(members.Bytes())
+	g.body.WriteString("};\n\n")
+	return nil
+}
+
+// the fields of the tagged struct are written with WriteField and read with
+// ReadFields, the fields missing on the wire keep their default values
+func (g *headerGenerator) writeTaggedSerialization(s *ast.AstStructNode) {
+	g.body.WriteString("    void FromIstream(std::istream& is) override {\n")
+	fmt.Fprintf(&g.body, "        *this = %s();\n", s.GetName())
+	g.body.WriteString("        ::sme::ReadFields(is, [this](uint16_t tag, std::istream& field) {\n")
+	g.body.WriteString("            switch (tag) {\n")
+	for _, f := range s.GetFields() {
+		fmt.Fprintf(&g.body, "            case %d:\n", f.GetTag())
+		fmt.Fprintf(&g.body, "                ::sme::Read(field, %s);\n", memberName(f.GetName()))
+		g.body.WriteString("                break;\n")
+	}
+	g.body.WriteString("            }\n")
+	g.body.WriteString("        });\n")
+	g.body.WriteString("    }\n\n")
+	g.body.WriteString("    void WriteToOstream(std::ostream& os) const override {\n")
+	fmt.Fprintf(&g.body, "        ::sme::WriteUint<uint32_t>(os, %d);\n", len(s.GetFields()))
+	for _, f := range s.GetFields() {
+		fmt.Fprintf(&g.body, "        ::sme::WriteField(os, %d, %s);\n", f.GetTag(), memberName(f.GetName()))
+	}
+	g.body.WriteString("    }\n\n")
+}
+
+func (g *headerGenerator) defaultValueLiteral(t ast.SmeType, v string) (string, error) {
+	if e, ok := t.(*ast.SmeEnum); ok {
+		return g.enumTypeName(e.GetImplNode()) + "::" + memberName(v), nil
+	}
+	return defaultValueLiteral(t, v)
+}
+
+// the oneof is kept in std::variant, its index is the number of the
+// member written on the wire and std::monostate is the unset oneof,
+// see Write and Read of std::variant in sme_base.h.
+// The structs are kept in ::sme::Box, so the struct can hold itself
+// through the oneof
+func (g *headerGenerator) oneofMemberTypeName(t ast.SmeType) (string, error) {
+	name, err := g.valueTypeName(t)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := t.(*ast.UserDefinedStruct); ok {
+		return "::sme::Box<" + name + ">", nil
+	}
+	return name, nil
+}
+
+func (g *headerGenerator) writeOneofAccessors(n *ast.AstOneofNode, members *bytes.Buffer) error {
+	member := memberName(n.GetName())
+	accessor := upperFirst(n.GetName())
+	alternatives := []string{"std::monostate"}
+	fmt.Fprintf(&g.body, "    enum class %sCase: uint8_t {\n        NotSet = 0,\n", accessor)
+	for i, m := range n.GetMembers() {
+		fmt.Fprintf(&g.body, "        %s = %d,\n", upperFirst(m.GetName()), i+1)
+	}
+	g.body.WriteString("    };\n\n")
+	fmt.Fprintf(&g.body, "    %sCase Get%sCase() const {\n        return static_cast<%sCase>(%s.index());\n    }\n", accessor, accessor, accessor, member)
+	fmt.Fprintf(&g.body, "    void Clear%s() {\n        %s.emplace<0>();\n    }\n\n", accessor, member)
+
+	for i, m := range n.GetMembers() {
+		t := m.GetFieldType()
+		alternative, err := g.oneofMemberTypeName(t)
+		if err != nil {
+			return fmt.Errorf("member %s: %w", m.GetName(), err)
+		}
+		alternatives = append(alternatives, alternative)
+		valueTypeName, err := g.valueTypeName(t)
+		if err != nil {
+			return fmt.Errorf("member %s: %w", m.GetName(), err)
+		}
+		paramType := valueTypeName
+		if !isScalar(t) {
+			paramType = "const " + valueTypeName + "&"
+		}
+		value := fmt.Sprintf("std::get<%d>(%s)", i+1, member)
+		if _, ok := t.(*ast.UserDefinedStruct); ok {
+			value = "*" + value
+		}
+		name := upperFirst(m.GetName())
+		fmt.Fprintf(&g.body, "    bool Has%s() const {\n        return %s.index() == %d;\n    }\n", name, member, i+1)
+		fmt.Fprintf(&g.body, "    // throws std::bad_variant_access if the other member is set\n")
+		fmt.Fprintf(&g.body, "    %s Get%s() const {\n        return %s;\n    }\n", paramType, name, value)
+		fmt.Fprintf(&g.body, "    void Set%s(%s value) {\n        %s.emplace<%d>(value);\n    }\n\n", name, paramType, member, i+1)
+	}
+	fmt.Fprintf(members, "    std::variant<%s> %s;\n", strings.Join(alternatives, ", "), member)
+	return nil
+}
+
+func defaultValueLiteral(t ast.SmeType, v string) (string, error) {
+	switch t.(type) {
+	case *ast.SmeString:
+		return cppStringLiteral(v), nil
+	case *ast.SmeChar:
+		c := v[0]
+		if c >= ' ' && c <= '~' && c != '\'' && c != '\\' {
+			return "'" + string(c) + "'", nil
+		}
+		return "static_cast<char>(" + strconv.Itoa(int(c)) + ")", nil
+	case *ast.SmeInt64:
+		if v == "-9223372036854775808" {
+			// the literal is parsed as the negated number, which does not fit int64_t
+			return "(-9223372036854775807LL - 1)", nil
+		}
+		return v + "LL", nil
+	case *ast.SmeUint32:
+		return v + "U", nil
+	case *ast.SmeUint64:
+		return v + "ULL", nil
+	case *ast.SmeList, *ast.SmeMap, *ast.UserDefinedStruct:
+		return "", ErrUnsupportedType
+	}
+	return v, nil
+}
+
+func cppStringLiteral(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c >= ' ' && c <= '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "\\%03o", c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// the header with the types shared by all the generated packages.
+// All the numbers are written in little endian byte order,
+// strings, lists and maps are prefixed with uint32 length,
+// optional values are prefixed with the byte telling if the value is present.
+const baseHeader = `// Code generated by sme. DO NOT EDIT.
+
+#ifndef SME_BASE_H
+#define SME_BASE_H
+
+#include <cstdint>
+#include <cstring>
+#include <istream>
+#include <memory>
+#include <optional>
+#include <ostream>
+#include <sstream>
+#include <string>
+#include <type_traits>
+#include <unordered_map>
+#include <utility>
+#include <variant>
+#include <vector>
+
+namespace sme {
+
+class ParseErrorException: public std::exception {
+public:
+    const char* what() const throw () {
+        return "Incorrect format of incoming binary data, could not parse";
+    }
+};
+
+class BaseSmeStruct {
+public:
+    virtual ~BaseSmeStruct() = default;
+
+    virtual void FromString(const std::string& bytes) final {
+        std::stringstream ss;
+        ss.str(bytes);
+        FromIstream(ss);
+    }
+
+    virtual std::string ToString() const final {
+        std::stringstream ss;
+        WriteToOstream(ss);
+        return ss.str();
+    }
+
+    virtual void FromIstream(std::istream&) = 0;
+
+    virtual void WriteToOstream(std::ostream&) const = 0;
+};
+
+template<class T>
+void WriteUint(std::ostream& os, T value) {
+    for (size_t i = 0; i < sizeof(T); ++i) {
+        os.put(static_cast<char>((value >> (8 * i)) & 0xFF));
+    }
+}
+
+template<class T>
+T ReadUint(std::istream& is) {
+    T value = 0;
+    for (size_t i = 0; i < sizeof(T); ++i) {
+        int c = is.get();
+        if (c == std::char_traits<char>::eof()) {
+            throw ParseErrorException();
+        }
+        value |= static_cast<T>(static_cast<unsigned char>(c)) << (8 * i);
+    }
+    return value;
+}
+
+inline void Write(std::ostream& os, uint8_t value) { WriteUint<uint8_t>(os, value); }
+inline void Write(std::ostream& os, uint16_t value) { WriteUint<uint16_t>(os, value); }
+inline void Write(std::ostream& os, uint32_t value) { WriteUint<uint32_t>(os, value); }
+inline void Write(std::ostream& os, uint64_t value) { WriteUint<uint64_t>(os, value); }
+inline void Write(std::ostream& os, int8_t value) { WriteUint<uint8_t>(os, static_cast<uint8_t>(value)); }
+inline void Write(std::ostream& os, int16_t value) { WriteUint<uint16_t>(os, static_cast<uint16_t>(value)); }
+inline void Write(std::ostream& os, int32_t value) { WriteUint<uint32_t>(os, static_cast<uint32_t>(value)); }
+inline void Write(std::ostream& os, int64_t value) { WriteUint<uint64_t>(os, static_cast<uint64_t>(value)); }
+inline void Write(std::ostream& os, char value) { WriteUint<uint8_t>(os, static_cast<uint8_t>(value)); }
+inline void Write(std::ostream& os, bool value) { WriteUint<uint8_t>(os, value ? 1 : 0); }
+
+inline void Write(std::ostream& os, float value) {
+    uint32_t bits;
+    std::memcpy(&bits, &value, sizeof(bits));
+    WriteUint<uint32_t>(os, bits);
+}
+
+inline void Write(std::ostream& os, double value) {
+    uint64_t bits;
+    std::memcpy(&bits, &value, sizeof(bits));
+    WriteUint<uint64_t>(os, bits);
+}
+
+inline void Write(std::ostream& os, const std::string& value) {
+    WriteUint<uint32_t>(os, static_cast<uint32_t>(value.length()));
+    os.write(value.data(), value.length());
+}
+
+inline void Write(std::ostream& os, const BaseSmeStruct& value) {
+    value.WriteToOstream(os);
+}
+
+inline void Read(std::istream& is, uint8_t& value) { value = ReadUint<uint8_t>(is); }
+inline void Read(std::istream& is, uint16_t& value) { value = ReadUint<uint16_t>(is); }
+inline void Read(std::istream& is, uint32_t& value) { value = ReadUint<uint32_t>(is); }
+inline void Read(std::istream& is, uint64_t& value) { value = ReadUint<uint64_t>(is); }
+inline void Read(std::istream& is, int8_t& value) { value = static_cast<int8_t>(ReadUint<uint8_t>(is)); }
+inline void Read(std::istream& is, int16_t& value) { value = static_cast<int16_t>(ReadUint<uint16_t>(is)); }
+inline void Read(std::istream& is, int32_t& value) { value = static_cast<int32_t>(ReadUint<uint32_t>(is)); }
+inline void Read(std::istream& is, int64_t& value) { value = static_cast<int64_t>(ReadUint<uint64_t>(is)); }
+inline void Read(std::istream& is, char& value) { value = static_cast<char>(ReadUint<uint8_t>(is)); }
+inline void Read(std::istream& is, bool& value) { value = ReadUint<uint8_t>(is) != 0; }
+
+inline void Read(std::istream& is, float& value) {
+    uint32_t bits = ReadUint<uint32_t>(is);
+    std::memcpy(&value, &bits, sizeof(bits));
+}
+
+inline void Read(std::istream& is, double& value) {
+    uint64_t bits = ReadUint<uint64_t>(is);
+    std::memcpy(&value, &bits, sizeof(bits));
+}
+
+// the string is read in chunks, so the length prefix larger than
+// the rest of the input does not allocate more than was actually read
+inline void Read(std::istream& is, std::string& value) {
+    uint32_t size = ReadUint<uint32_t>(is);
+    value.clear();
+    char buf[4096];
+    while (size > 0) {
+        uint32_t n = size < sizeof(buf) ? size : static_cast<uint32_t>(sizeof(buf));
+        is.read(buf, n);
+        if (static_cast<uint32_t>(is.gcount()) != n) {
+            throw ParseErrorException();
+        }
+        value.append(buf, n);
+        size -= n;
+    }
+}
+
+inline void Read(std::istream& is, BaseSmeStruct& value) {
+    value.FromIstream(is);
+}
+
+// the enums are written as their underlying integer type
+template<class T, std::enable_if_t<std::is_enum_v<T>, int> = 0>
+void Write(std::ostream& os, T value) {
+    Write(os, static_cast<std::underlying_type_t<T>>(value));
+}
+
+template<class T, std::enable_if_t<std::is_enum_v<T>, int> = 0>
+void Read(std::istream& is, T& value) {
+    std::underlying_type_t<T> x{};
+    Read(is, x);
+    value = static_cast<T>(x);
+}
+
+// Box holds the optional struct on the heap, so the struct can hold
+// itself through the optional field. It is copied by value and has
+// the same interface as std::optional
+template<class T>
+class Box {
+public:
+    Box() = default;
+
+    Box(const T& value): ptr_(new T(value)) {
+    }
+
+    Box(const Box& other): ptr_(other.ptr_ ? new T(*other.ptr_) : nullptr) {
+    }
+
+    Box(Box&&) noexcept = default;
+
+    Box& operator=(const Box& other) {
+        if (this != &other) {
+            ptr_.reset(other.ptr_ ? new T(*other.ptr_) : nullptr);
+        }
+        return *this;
+    }
+
+    Box& operator=(Box&&) noexcept = default;
+
+    Box& operator=(const T& value) {
+        ptr_.reset(new T(value));
+        return *this;
+    }
+
+    bool has_value() const {
+        return ptr_ != nullptr;
+    }
+
+    explicit operator bool() const {
+        return has_value();
+    }
+
+    void reset() {
+        ptr_.reset();
+    }
+
+    const T& operator*() const {
+        return *ptr_;
+    }
+
+    T& operator*() {
+        return *ptr_;
+    }
+
+    const T* operator->() const {
+        return ptr_.get();
+    }
+
+    T* operator->() {
+        return ptr_.get();
+    }
+
+private:
+    std::unique_ptr<T> ptr_;
+};
+
+template<class T>
+void Write(std::ostream& os, const std::optional<T>& value);
+template<class... Ts>
+void Write(std::ostream& os, const std::variant<std::monostate, Ts...>& value);
+template<class... Ts>
+void Read(std::istream& is, std::variant<std::monostate, Ts...>& value);
+template<class T>
+void Write(std::ostream& os, const Box<T>& value);
+template<class T>
+void Write(std::ostream& os, const std::vector<T>& value);
+template<class K, class V>
+void Write(std::ostream& os, const std::unordered_map<K, V>& value);
+template<class T>
+void Read(std::istream& is, std::optional<T>& value);
+template<class T>
+void Read(std::istream& is, Box<T>& value);
+template<class T>
+void Read(std::istream& is, std::vector<T>& value);
+template<class K, class V>
+void Read(std::istream& is, std::unordered_map<K, V>& value);
+
+template<class T>
+void Write(std::ostream& os, const std::optional<T>& value) {
+    Write(os, value.has_value());
+    if (value.has_value()) {
+        Write(os, *value);
+    }
+}
+
+template<class T>
+void Write(std::ostream& os, const Box<T>& value) {
+    Write(os, value.has_value());
+    if (value.has_value()) {
+        Write(os, *value);
+    }
+}
+
+// the variant holds the oneof, the index of the alternative is written
+// before its value, std::monostate is the oneof with no member set
+template<class T>
+struct Unboxed {
+    using type = T;
+};
+
+template<class T>
+struct Unboxed<Box<T>> {
+    using type = T;
+};
+
+inline void WriteAlternative(std::ostream&, std::monostate) {
+}
+
+template<class T>
+void WriteAlternative(std::ostream& os, const T& value) {
+    Write(os, value);
+}
+
+template<class T>
+void WriteAlternative(std::ostream& os, const Box<T>& value) {
+    Write(os, *value);
+}
+
+template<class... Ts>
+void Write(std::ostream& os, const std::variant<std::monostate, Ts...>& value) {
+    WriteUint<uint8_t>(os, static_cast<uint8_t>(value.index()));
+    std::visit([&os](const auto& x) { WriteAlternative(os, x); }, value);
+}
+
+template<size_t I, class V>
+void ReadAlternative(std::istream& is, V& value, size_t index) {
+    if constexpr (I < std::variant_size_v<V>) {
+        if (index != I) {
+            ReadAlternative<I + 1>(is, value, index);
+            return;
+        }
+        typename Unboxed<std::variant_alternative_t<I, V>>::type x{};
+        Read(is, x);
+        value.template emplace<I>(std::move(x));
+    } else {
+        throw ParseErrorException();
+    }
+}
+
+template<class... Ts>
+void Read(std::istream& is, std::variant<std::monostate, Ts...>& value) {
+    size_t index = ReadUint<uint8_t>(is);
+    if (index == 0) {
+        value.template emplace<0>();
+        return;
+    }
+    ReadAlternative<1>(is, value, index);
+}
+
+template<class T>
+void Write(std::ostream& os, const std::vector<T>& value) {
+    WriteUint<uint32_t>(os, static_cast<uint32_t>(value.size()));
+    for (const auto& x: value) {
+        Write(os, static_cast<const T&>(x));
+    }
+}
+
+template<class K, class V>
+void Write(std::ostream& os, const std::unordered_map<K, V>& value) {
+    WriteUint<uint32_t>(os, static_cast<uint32_t>(value.size()));
+    for (const auto& p: value) {
+        Write(os, p.first);
+        Write(os, p.second);
+    }
+}
+
+template<class T>
+void Read(std::istream& is, std::optional<T>& value) {
+    bool present = false;
+    Read(is, present);
+    if (!present) {
+        value.reset();
+        return;
+    }
+    T x{};
+    Read(is, x);
+    value = std::move(x);
+}
+
+template<class T>
+void Read(std::istream& is, Box<T>& value) {
+    bool present = false;
+    Read(is, present);
+    if (!present) {
+        value.reset();
+        return;
+    }
+    T x{};
+    Read(is, x);
+    value = x;
+}
+
+// the elements of an empty struct take no bytes, so the length of a list
+// or a map is not checked against the input, the elements are appended
+// one by one until the input ends
+template<class T>
+void Read(std::istream& is, std::vector<T>& value) {
+    uint32_t size = ReadUint<uint32_t>(is);
+    value.clear();
+    for (uint32_t i = 0; i < size; ++i) {
+        T x{};
+        Read(is, x);
+        value.push_back(std::move(x));
+    }
+}
+
+template<class K, class V>
+void Read(std::istream& is, std::unordered_map<K, V>& value) {
+    uint32_t size = ReadUint<uint32_t>(is);
+    value.clear();
+    for (uint32_t i = 0; i < size; ++i) {
+        std::pair<K, V> p{};
+        Read(is, p.first);
+        Read(is, p.second);
+        value.insert(std::move(p));
+    }
+}
+
+// the field of the tagged struct is written with its tag and length,
+// so the readers that do not know the tag can skip it
+template<class T>
+void WriteField(std::ostream& os, uint16_t tag, const T& value) {
+    std::ostringstream field;
+    Write(field, value);
+    const std::string bytes = field.str();
+    WriteUint<uint16_t>(os, tag);
+    WriteUint<uint32_t>(os, static_cast<uint32_t>(bytes.length()));
+    os.write(bytes.data(), bytes.length());
+}
+
+// reads the fields of the tagged struct and passes each of them to readField
+// with its tag, the unknown fields are left unread by readField
+template<class F>
+void ReadFields(std::istream& is, F readField) {
+    uint32_t count = ReadUint<uint32_t>(is);
+    for (uint32_t i = 0; i < count; ++i) {
+        uint16_t tag = ReadUint<uint16_t>(is);
+        std::string bytes;
+        Read(is, bytes);
+        std::istringstream field(bytes);
+        readField(tag, field);
+    }
+}
+
+} // namespace sme
+
+#endif // SME_BASE_H
+`
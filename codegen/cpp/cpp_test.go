@@ -0,0 +1,209 @@
+package cpp
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Ghytro/sme/codegen"
+	"github.com/Ghytro/sme/parser"
+)
+
+// generates the schema, compiles the program including the generated headers
+// and runs it with the arguments, the program returns non zero code
+// if the values do not round-trip. Returns the output of the program
+func runRoundTrip(t *testing.T, schema map[string]string, program string, args ...string) string {
+	t.Helper()
+	compiler, err := exec.LookPath("c++")
+	if err != nil {
+		if compiler, err = exec.LookPath("g++"); err != nil {
+			t.Skip("c++ compiler is not found")
+		}
+	}
+	schemaDir, outDir := t.TempDir(), t.TempDir()
+	for name, content := range schema {
+		if err := os.WriteFile(filepath.Join(schemaDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree, diagnostics := parser.Parse([]string{schemaDir}, parser.DefaultExtensions, 1)
+	if diagnostics.HasErrors() {
+		t.Fatalf("schema has errors: %v", diagnostics.Sorted())
+	}
+	if err := (Generator{}).Generate(tree, outDir, codegen.Options{}); err != nil {
+		t.Fatalf("Generate: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "main.cpp"), []byte(program), 0644); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(outDir, "main")
+	cmd := exec.Command(compiler, "-std=c++17", "-Wall", "-Werror", "-o", binary, "main.cpp")
+	cmd.Dir = outDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("compile: %s\n%s", err, output)
+	}
+	output, err := exec.Command(binary, args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("run: %s\n%s", err, output)
+	}
+	return string(output)
+}
+
+// returns the schema shared by the round-trip tests of all the backends
+// and the hex of the bytes of the value every backend encodes
+func loadRoundTripSchema(t *testing.T) (map[string]string, string) {
+	t.Helper()
+	const dir = "../testdata/roundtrip"
+	schema := make(map[string]string)
+	fileNames, err := filepath.Glob(filepath.Join(dir, "*.sme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fileName := range fileNames {
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schema[filepath.Base(fileName)] = string(content)
+	}
+	golden, err := os.ReadFile(filepath.Join(dir, "rt.hex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema, strings.TrimSpace(string(golden))
+}
+
+func TestRoundTrip(t *testing.T) {
+	schema, golden := loadRoundTripSchema(t)
+	output := runRoundTrip(t, schema, `#include <cstdio>
+#include <string>
+
+#include "rt.h"
+
+static std::string ToHex(const std::string& bytes) {
+    static const char digits[] = "0123456789abcdef";
+    std::string result;
+    for (unsigned char c: bytes) {
+        result += digits[c >> 4];
+        result += digits[c & 0xF];
+    }
+    return result;
+}
+
+static std::string FromHex(const std::string& hex) {
+    std::string result;
+    for (size_t i = 0; i + 1 < hex.size(); i += 2) {
+        result += static_cast<char>(std::stoi(hex.substr(i, 2), nullptr, 16));
+    }
+    return result;
+}
+
+static rt::Point MakePoint(int32_t x, int32_t y) {
+    rt::Point p;
+    p.SetX(x);
+    p.SetY(y);
+    return p;
+}
+
+int main(int argc, char** argv) {
+    if (argc != 2) {
+        return 1;
+    }
+    rt::All value;
+    value.SetI8(-5);
+    value.SetU16(65535);
+    value.SetI64(-1234567890123LL);
+    value.SetU64(18446744073709551615ULL);
+    value.SetF(1.5f);
+    value.SetD(-2.25);
+    value.SetB(true);
+    value.SetC('z');
+    value.SetS("h\xc3\xa9llo");
+    value.SetMaybe(7);
+    value.SetOrigin(MakePoint(1, 2));
+    value.SetPoints({MakePoint(3, 4), MakePoint(5, 6)});
+    value.GetEmpties().resize(3);
+    value.GetCounts()["a"] = 1;
+    value.SetColor(rt::Color::BLUE);
+    value.SetColors({rt::Color::RED, rt::Color::GREEN});
+    value.SetLocation(MakePoint(7, 8));
+    value.GetTagged().SetId(42);
+    value.GetTagged().SetLogin("root");
+    value.GetTagged().SetCompany("acme");
+    value.GetTagged().SetScores({1, 2, 3});
+
+    const std::string golden = FromHex(argv[1]);
+    rt::All decoded;
+    decoded.FromString(golden);
+    if (decoded.ToString() != golden) {
+        return 2;
+    }
+    if (decoded.GetS() != value.GetS() || !decoded.GetMaybe() || *decoded.GetMaybe() != 7 || decoded.GetMissing() ||
+        decoded.GetOrigin()->GetY() != 2 || decoded.GetPoints().size() != 2 || decoded.GetEmpties().size() != 3 ||
+        decoded.GetCounts().at("a") != 1 || decoded.GetColor() != rt::Color::BLUE ||
+        decoded.GetContactCase() != rt::All::ContactCase::Location || decoded.GetLocation().GetX() != 7 ||
+        decoded.GetTagged().GetOwnerCase() != rt::Tagged::OwnerCase::Company || decoded.GetTagged().GetCompany() != "acme" ||
+        *decoded.GetTagged().GetLogin() != "root" || decoded.GetTagged().GetScores().size() != 3) {
+        return 3;
+    }
+    std::printf("%s", ToHex(value.ToString()).c_str());
+    return 0;
+}
+`, golden)
+	if output != golden {
+		t.Errorf("encoded value differs from rt.hex:\n%s\nwant:\n%s", output, golden)
+	}
+}
+
+func TestRoundTripListOfEmptyStructs(t *testing.T) {
+	schema := map[string]string{
+		"p.sme": "syntax 0.0.1\n\npackage p\n\nstruct Empty {\n}\n\nstruct C {\n    map[int32, Empty] byId\n    list[Empty] items\n}\n",
+	}
+	runRoundTrip(t, schema, `#include <istream>
+#include <streambuf>
+#include <string>
+
+#include "p.h"
+
+// the stream buffer that can not seek, like the one of a pipe
+class PipeBuf: public std::streambuf {
+public:
+    explicit PipeBuf(std::string data): data_(std::move(data)) {
+        setg(&data_[0], &data_[0], &data_[0] + data_.size());
+    }
+
+protected:
+    pos_type seekoff(off_type, std::ios_base::seekdir, std::ios_base::openmode) override {
+        return pos_type(off_type(-1));
+    }
+
+private:
+    std::string data_;
+};
+
+int main() {
+    p::C in;
+    in.GetItems().resize(10);
+    // the order of several map entries on the wire may differ after the read
+    in.GetById()[1] = p::Empty();
+    const std::string bytes = in.ToString();
+
+    p::C out;
+    out.FromString(bytes);
+    if (out.GetItems().size() != 10 || out.GetById().size() != 1 || out.ToString() != bytes) {
+        return 1;
+    }
+
+    PipeBuf buf(bytes);
+    std::istream pipe(&buf);
+    p::C piped;
+    piped.FromIstream(pipe);
+    if (piped.ToString() != bytes) {
+        return 2;
+    }
+    return 0;
+}
+`)
+}
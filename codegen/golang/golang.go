@@ -0,0 +1,805 @@
+// Package golang generates Go code for the parsed sme schemas.
+// Every sme package becomes a Go package in its own directory,
+// every struct becomes a Go struct with MarshalBinary and
+// UnmarshalBinary methods that use the sme wire layout,
+// every enum becomes a named integer type with a constant per value,
+// every oneof becomes an interface implemented by the wrapper of each member.
+package golang
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Ghytro/sme/ast"
+	"github.com/Ghytro/sme/codegen"
+)
+
+var ErrUnsupportedType = errors.New("type is not supported by the go generator")
+var ErrUnsupportedMapKey = errors.New("only primitive types can be used as map keys in go")
+var ErrNoImportPath = errors.New("the import path of the package is not known, set the \"package\" parameter or the go_package option of the package")
+
+func init() {
+	codegen.Register("go", Generator{})
+}
+
+type Generator struct{}
+
+// Generate writes one .go file per package of the tree
+// into outDir/<package name>/<package name>.sme.go, the "package"
+// parameter is the import path of outDir, the packages import
+// each other by it. Without the parameter the package is imported
+// by its go_package option
+func (Generator) Generate(tree *ast.AstTree, outDir string, opts codegen.Options) error {
+	if tree == nil {
+		return codegen.ErrNoAstTree
+	}
+	root := tree.GetRoot()
+	importPaths := packageImportPaths(root, opts.Param("package"))
+	for _, p := range root.GetPackages() {
+		src, err := generatePackage(root, p, importPaths)
+		if err != nil {
+			return fmt.Errorf("package %s: %w", p.GetName(), err)
+		}
+		pkgDir := filepath.Join(outDir, p.GetName())
+		if err := os.MkdirAll(pkgDir, os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(pkgDir, p.GetName()+".sme.go"), src, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// returns the import paths of the packages by their names,
+// the package with no known import path is not in the result
+func packageImportPaths(root *ast.AstModuleNode, importPrefix string) map[string]string {
+	result := make(map[string]string)
+	for _, p := range root.GetPackages() {
+		switch {
+		case importPrefix != "":
+			result[p.GetName()] = path.Join(importPrefix, p.GetName())
+		case p.GetGoPackageName() != nil:
+			result[p.GetName()] = *p.GetGoPackageName()
+		}
+	}
+	return result
+}
+
+type fileGenerator struct {
+	body        bytes.Buffer
+	packageName string
+	importPaths map[string]string
+	// the imported paths with the names of the packages, the name
+	// is empty for the packages of the standard library
+	imports    map[string]string
+	tmpCounter int
+}
+
+func generatePackage(root *ast.AstModuleNode, p *ast.AstPackageNode, importPaths map[string]string) ([]byte, error) {
+	g := &fileGenerator{
+		packageName: p.GetName(),
+		importPaths: importPaths,
+		imports:     make(map[string]string),
+	}
+	for _, e := range p.GetEnums() {
+		g.writeEnum(e)
+	}
+	for _, s := range p.GetStructs() {
+		if err := g.writeStruct(s); err != nil {
+			return nil, fmt.Errorf("struct %s: %w", s.GetName(), err)
+		}
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "// Code generated by sme from syntax %s. DO NOT EDIT.\n\n", root.GetSyntaxVer())
+	fmt.Fprintf(&file, "package %s\n\n", p.GetName())
+	file.WriteString("import (\n\t\"bytes\"\n\t\"encoding/binary\"\n\t\"errors\"\n\t\"io\"\n\t\"math\"\n")
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		// the last element of the go_package path may differ from
+		// the name of the package, so such packages are named explicitly
+		if name := g.imports[imp]; name != "" && name != path.Base(imp) {
+			fmt.Fprintf(&file, "\t%s %q\n", name, imp)
+		} else {
+			fmt.Fprintf(&file, "\t%q\n", imp)
+		}
+	}
+	file.WriteString(")\n\n")
+	file.WriteString(runtimeHelpers)
+	file.Write(g.body.Bytes())
+	return format.Source(file.Bytes())
+}
+
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func (g *fileGenerator) tmp(prefix string) string {
+	g.tmpCounter++
+	return prefix + strconv.Itoa(g.tmpCounter)
+}
+
+func (g *fileGenerator) structTypeName(n *ast.AstStructNode) (string, error) {
+	return g.qualifiedName(n.GetPackageName(), n.GetName())
+}
+
+func (g *fileGenerator) enumTypeName(n *ast.AstEnumNode) (string, error) {
+	return g.qualifiedName(n.GetPackageName(), n.GetName())
+}
+
+// the types of the other packages are imported by their import paths,
+// a bare package path does not resolve in module mode, so the reference
+// is an error if the import path is not known
+func (g *fileGenerator) qualifiedName(packageName string, name string) (string, error) {
+	if packageName == g.packageName {
+		return exportedName(name), nil
+	}
+	importPath, ok := g.importPaths[packageName]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNoImportPath, packageName)
+	}
+	g.imports[importPath] = packageName
+	return packageName + "." + exportedName(name), nil
+}
+
+// the constants are prefixed with the enum name, like Status_ACTIVE,
+// so the values of the different enums of the package do not clash
+func (g *fileGenerator) enumValueName(n *ast.AstEnumNode, valueName string) (string, error) {
+	typeName, err := g.enumTypeName(n)
+	if err != nil {
+		return "", err
+	}
+	return typeName + "_" + valueName, nil
+}
+
+func (g *fileGenerator) writeEnum(e *ast.AstEnumNode) {
+	name := exportedName(e.GetName())
+	valueName := func(v *ast.AstEnumValueNode) string {
+		return name + "_" + v.GetName()
+	}
+	underlying, _ := g.valueTypeName(e.GetUnderlyingType())
+	fmt.Fprintf(&g.body, "type %s %s\n\n", name, underlying)
+	if len(e.GetValues()) != 0 {
+		g.body.WriteString("const (\n")
+		for _, v := range e.GetValues() {
+			fmt.Fprintf(&g.body, "\t%s %s = %s\n", valueName(v), name, v.GetNumber())
+		}
+		g.body.WriteString(")\n\n")
+	}
+
+	g.imports["strconv"] = ""
+	number := "strconv.FormatInt(int64(e), 10)"
+	if e.GetUnderlyingType().IsUnsigned() {
+		number = "strconv.FormatUint(uint64(e), 10)"
+	}
+	fmt.Fprintf(&g.body, "func (e %s) String() string {\n\tswitch e {\n", name)
+	for _, v := range e.GetValues() {
+		fmt.Fprintf(&g.body, "\tcase %s:\n\t\treturn %q\n", valueName(v), v.GetName())
+	}
+	fmt.Fprintf(&g.body, "\t}\n\treturn \"%s(\" + %s + \")\"\n}\n\n", name, number)
+}
+
+// returns the go type of the value, ignoring the optionality
+func (g *fileGenerator) valueTypeName(t ast.SmeType) (string, error) {
+	switch v := t.(type) {
+	case *ast.SmeInt8:
+		return "int8", nil
+	case *ast.SmeInt16:
+		return "int16", nil
+	case *ast.SmeInt32:
+		return "int32", nil
+	case *ast.SmeInt64:
+		return "int64", nil
+	case *ast.SmeUint8:
+		return "uint8", nil
+	case *ast.SmeUint16:
+		return "uint16", nil
+	case *ast.SmeUint32:
+		return "uint32", nil
+	case *ast.SmeUint64:
+		return "uint64", nil
+	case *ast.SmeFloat:
+		return "float32", nil
+	case *ast.SmeDouble:
+		return "float64", nil
+	case *ast.SmeString:
+		return "string", nil
+	case *ast.SmeChar:
+		return "byte", nil
+	case *ast.SmeBool:
+		return "bool", nil
+	case *ast.SmeList:
+		elem, err := g.valueTypeName(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return "[]" + elem, nil
+	case *ast.SmeMap:
+		if !isPrimitive(v.KeyType()) {
+			return "", ErrUnsupportedMapKey
+		}
+		key, err := g.valueTypeName(v.KeyType())
+		if err != nil {
+			return "", err
+		}
+		value, err := g.valueTypeName(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return "map[" + key + "]" + value, nil
+	case *ast.UserDefinedStruct:
+		return g.structTypeName(v.GetImplNode())
+	case *ast.SmeEnum:
+		return g.enumTypeName(v.GetImplNode())
+	case *ast.SmeOneof:
+		return oneofTypeName(v.GetImplNode()), nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// the oneof payload of struct Event is Event_Payload,
+// the wrapper of its login member is Event_Login
+func oneofTypeName(n *ast.AstOneofNode) string {
+	return exportedName(n.GetStructName()) + "_" + exportedName(n.GetName())
+}
+
+func oneofMemberTypeName(n *ast.AstOneofNode, member *ast.AstStructFieldNode) string {
+	return exportedName(n.GetStructName()) + "_" + exportedName(member.GetName())
+}
+
+// writes the interface of the oneof, the wrappers of the members
+// implementing it and the accessors of the members
+func (g *fileGenerator) writeOneof(n *ast.AstOneofNode) error {
+	name := oneofTypeName(n)
+	structName := exportedName(n.GetStructName())
+	fmt.Fprintf(&g.body, "// %s is the oneof %s of %s, it holds\n", name, n.GetName(), structName)
+	fmt.Fprintf(&g.body, "// the wrapper of the member that is set, or nil if no member is set\n")
+	fmt.Fprintf(&g.body, "type %s interface {\n\tis%s()\n}\n\n", name, name)
+	for _, m := range n.GetMembers() {
+		typeName, err := g.valueTypeName(m.GetFieldType())
+		if err != nil {
+			return fmt.Errorf("member %s: %w", m.GetName(), err)
+		}
+		wrapper := oneofMemberTypeName(n, m)
+		member := exportedName(m.GetName())
+		fmt.Fprintf(&g.body, "type %s struct {\n\t%s %s\n}\n\n", wrapper, member, typeName)
+		fmt.Fprintf(&g.body, "func (*%s) is%s() {}\n\n", wrapper, name)
+
+		fmt.Fprintf(&g.body, "// Get%s returns %s if it is the member set in %s\n", member, m.GetName(), exportedName(n.GetName()))
+		fmt.Fprintf(&g.body, "func (m *%s) Get%s() (%s, bool) {\n", structName, member, typeName)
+		fmt.Fprintf(&g.body, "\tif v, ok := m.%s.(*%s); ok {\n\t\treturn v.%s, true\n\t}\n", exportedName(n.GetName()), wrapper, member)
+		fmt.Fprintf(&g.body, "\tvar zero %s\n\treturn zero, false\n}\n\n", typeName)
+
+		fmt.Fprintf(&g.body, "// Set%s sets %s as the member of %s\n", member, m.GetName(), exportedName(n.GetName()))
+		fmt.Fprintf(&g.body, "func (m *%s) Set%s(v %s) {\n", structName, member, typeName)
+		fmt.Fprintf(&g.body, "\tm.%s = &%s{%s: v}\n}\n\n", exportedName(n.GetName()), wrapper, member)
+	}
+	return nil
+}
+
+// optional values are stored as pointers, except for lists and maps
+// which use nil to tell that the value is absent
+func (g *fileGenerator) fieldTypeName(t ast.SmeType) (string, error) {
+	name, err := g.valueTypeName(t)
+	if err != nil {
+		return "", err
+	}
+	if t.IsOptional() && !isContainer(t) {
+		return "*" + name, nil
+	}
+	return name, nil
+}
+
+func isContainer(t ast.SmeType) bool {
+	switch t.(type) {
+	case *ast.SmeList, *ast.SmeMap:
+		return true
+	}
+	return false
+}
+
+func isPrimitive(t ast.SmeType) bool {
+	switch t.(type) {
+	case *ast.SmeList, *ast.SmeMap, *ast.UserDefinedStruct, *ast.SmeOneof:
+		return false
+	}
+	return true
+}
+
+func (g *fileGenerator) writeStruct(s *ast.AstStructNode) error {
+	name := exportedName(s.GetName())
+	fmt.Fprintf(&g.body, "type %s struct {\n", name)
+	for _, f := range s.GetFields() {
+		typeName, err := g.fieldTypeName(f.GetFieldType())
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.GetName(), err)
+		}
+		fmt.Fprintf(&g.body, "\t%s %s\n", exportedName(f.GetName()), typeName)
+	}
+	g.body.WriteString("}\n\n")
+
+	for _, f := range s.GetFields() {
+		if o, ok := f.GetFieldType().(*ast.SmeOneof); ok {
+			if err := g.writeOneof(o.GetImplNode()); err != nil {
+				return fmt.Errorf("oneof %s: %w", f.GetName(), err)
+			}
+		}
+	}
+
+	if err := g.writeConstructor(s); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(&g.body, "// MarshalBinary encodes %s using the sme wire layout\n", name)
+	fmt.Fprintf(&g.body, "func (m *%s) MarshalBinary() ([]byte, error) {\n", name)
+	g.body.WriteString("\tvar b bytes.Buffer\n\tm.EncodeSme(&b)\n\treturn b.Bytes(), nil\n}\n\n")
+
+	fmt.Fprintf(&g.body, "// UnmarshalBinary decodes %s from the sme wire layout\n", name)
+	fmt.Fprintf(&g.body, "func (m *%s) UnmarshalBinary(data []byte) error {\n", name)
+	g.body.WriteString("\treturn m.DecodeSme(bytes.NewReader(data))\n}\n\n")
+
+	fmt.Fprintf(&g.body, "func (m *%s) EncodeSme(b *bytes.Buffer) {\n", name)
+	if s.IsTagged() {
+		if err := g.writeTaggedEncode(s); err != nil {
+			return err
+		}
+	} else {
+		for _, f := range s.GetFields() {
+			if err := g.writeEncode("m."+exportedName(f.GetName()), f.GetFieldType(), true); err != nil {
+				return fmt.Errorf("field %s: %w", f.GetName(), err)
+			}
+		}
+	}
+	g.body.WriteString("}\n\n")
+
+	fmt.Fprintf(&g.body, "func (m *%s) DecodeSme(r *bytes.Reader) error {\n", name)
+	if s.IsTagged() {
+		if err := g.writeTaggedDecode(s); err != nil {
+			return err
+		}
+	} else {
+		for _, f := range s.GetFields() {
+			if err := g.writeDecode("m."+exportedName(f.GetName()), f.GetFieldType(), true); err != nil {
+				return fmt.Errorf("field %s: %w", f.GetName(), err)
+			}
+		}
+	}
+	g.body.WriteString("\treturn nil\n}\n\n")
+	return nil
+}
+
+// the tagged struct is written as the number of the fields followed by
+// the fields prefixed with their tags and lengths
+func (g *fileGenerator) writeTaggedEncode(s *ast.AstStructNode) error {
+	fmt.Fprintf(&g.body, "smePutUint32(b, %d)\n", len(s.GetFields()))
+	for _, f := range s.GetFields() {
+		start := g.tmp("start")
+		fmt.Fprintf(&g.body, "%s := smeBeginField(b, %d)\n", start, f.GetTag())
+		if err := g.writeEncode("m."+exportedName(f.GetName()), f.GetFieldType(), true); err != nil {
+			return fmt.Errorf("field %s: %w", f.GetName(), err)
+		}
+		fmt.Fprintf(&g.body, "smeEndField(b, %s)\n", start)
+	}
+	return nil
+}
+
+// the fields missing on the wire keep their default values,
+// the fields with unknown tags are skipped
+func (g *fileGenerator) writeTaggedDecode(s *ast.AstStructNode) error {
+	size, idx, tag := g.tmp("n"), g.tmp("i"), g.tmp("tag")
+	fmt.Fprintf(&g.body, "*m = *New%s()\n", exportedName(s.GetName()))
+	fmt.Fprintf(&g.body, "%s, err := smeGetUint32(r)\nif err != nil {\nreturn err\n}\n", size)
+	fmt.Fprintf(&g.body, "for %s := uint32(0); %s < %s; %s++ {\n", idx, idx, size, idx)
+	fmt.Fprintf(&g.body, "%s, r, err := smeGetField(r)\nif err != nil {\nreturn err\n}\n", tag)
+	fmt.Fprintf(&g.body, "switch %s {\n", tag)
+	for _, f := range s.GetFields() {
+		fmt.Fprintf(&g.body, "case %d:\n", f.GetTag())
+		if err := g.writeDecode("m."+exportedName(f.GetName()), f.GetFieldType(), true); err != nil {
+			return fmt.Errorf("field %s: %w", f.GetName(), err)
+		}
+	}
+	g.body.WriteString("}\n}\n")
+	return nil
+}
+
+func (g *fileGenerator) writeConstructor(s *ast.AstStructNode) error {
+	name := exportedName(s.GetName())
+	fmt.Fprintf(&g.body, "// New%s returns %s with the default values set\n", name, name)
+	fmt.Fprintf(&g.body, "func New%s() *%s {\n\tm := &%s{}\n", name, name, name)
+	for _, f := range s.GetFields() {
+		t := f.GetFieldType()
+		v, err := t.DefaultValue()
+		if err != nil {
+			continue
+		}
+		literal, err := g.defaultValueLiteral(t, v)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.GetName(), err)
+		}
+		target := "m." + exportedName(f.GetName())
+		if t.IsOptional() {
+			typeName, err := g.valueTypeName(t)
+			if err != nil {
+				return err
+			}
+			tmp := g.tmp("v")
+			fmt.Fprintf(&g.body, "\t%s := %s(%s)\n\t%s = &%s\n", tmp, typeName, literal, target, tmp)
+		} else {
+			fmt.Fprintf(&g.body, "\t%s = %s\n", target, literal)
+		}
+	}
+	g.body.WriteString("\treturn m\n}\n\n")
+	return nil
+}
+
+func (g *fileGenerator) defaultValueLiteral(t ast.SmeType, v string) (string, error) {
+	switch e := t.(type) {
+	case *ast.SmeEnum:
+		return g.enumValueName(e.GetImplNode(), v)
+	case *ast.SmeString:
+		return strconv.Quote(v), nil
+	case *ast.SmeChar:
+		return strconv.QuoteRune(rune(v[0])), nil
+	case *ast.SmeList, *ast.SmeMap, *ast.UserDefinedStruct:
+		return "", ErrUnsupportedType
+	}
+	return v, nil
+}
+
+// primitive types are written with the helpers from runtimeHelpers,
+// the returned values are the name of the helper suffix and the type
+// the value should be converted to before calling it
+func primitiveCodec(t ast.SmeType) (helper string, wireType string) {
+	switch v := t.(type) {
+	case *ast.SmeEnum:
+		return primitiveCodec(v.GetImplNode().GetUnderlyingType())
+	case *ast.SmeInt8, *ast.SmeUint8, *ast.SmeChar:
+		return "Uint8", "uint8"
+	case *ast.SmeInt16, *ast.SmeUint16:
+		return "Uint16", "uint16"
+	case *ast.SmeInt32, *ast.SmeUint32:
+		return "Uint32", "uint32"
+	case *ast.SmeInt64, *ast.SmeUint64:
+		return "Uint64", "uint64"
+	case *ast.SmeFloat:
+		return "Float32", "float32"
+	case *ast.SmeDouble:
+		return "Float64", "float64"
+	case *ast.SmeBool:
+		return "Bool", "bool"
+	case *ast.SmeString:
+		return "String", "string"
+	}
+	return "", ""
+}
+
+func (g *fileGenerator) writeEncode(expr string, t ast.SmeType, isField bool) error {
+	if isField && t.IsOptional() {
+		fmt.Fprintf(&g.body, "if %s != nil {\nsmePutBool(b, true)\n", expr)
+		valueExpr := expr
+		if !isContainer(t) {
+			valueExpr = "(*" + expr + ")"
+		}
+		if err := g.writeEncode(valueExpr, t, false); err != nil {
+			return err
+		}
+		g.body.WriteString("} else {\nsmePutBool(b, false)\n}\n")
+		return nil
+	}
+	switch v := t.(type) {
+	case *ast.SmeList:
+		elem := g.tmp("v")
+		fmt.Fprintf(&g.body, "smePutUint32(b, uint32(len(%s)))\n", expr)
+		fmt.Fprintf(&g.body, "for _, %s := range %s {\n", elem, expr)
+		if err := g.writeEncode(elem, v.ValueType(), false); err != nil {
+			return err
+		}
+		g.body.WriteString("}\n")
+		return nil
+	case *ast.SmeMap:
+		key, value := g.tmp("k"), g.tmp("v")
+		fmt.Fprintf(&g.body, "smePutUint32(b, uint32(len(%s)))\n", expr)
+		fmt.Fprintf(&g.body, "for %s, %s := range %s {\n", key, value, expr)
+		if err := g.writeEncode(key, v.KeyType(), false); err != nil {
+			return err
+		}
+		if err := g.writeEncode(value, v.ValueType(), false); err != nil {
+			return err
+		}
+		g.body.WriteString("}\n")
+		return nil
+	case *ast.UserDefinedStruct:
+		fmt.Fprintf(&g.body, "%s.EncodeSme(b)\n", expr)
+		return nil
+	case *ast.SmeOneof:
+		n := v.GetImplNode()
+		value := g.tmp("v")
+		fmt.Fprintf(&g.body, "switch %s := %s.(type) {\n", value, expr)
+		for i, m := range n.GetMembers() {
+			fmt.Fprintf(&g.body, "case *%s:\nsmePutUint8(b, %d)\n", oneofMemberTypeName(n, m), i+1)
+			if err := g.writeEncode(value+"."+exportedName(m.GetName()), m.GetFieldType(), false); err != nil {
+				return err
+			}
+		}
+		g.body.WriteString("default:\nsmePutUint8(b, 0)\n}\n")
+		return nil
+	}
+	helper, wireType := primitiveCodec(t)
+	if helper == "" {
+		return ErrUnsupportedType
+	}
+	fmt.Fprintf(&g.body, "smePut%s(b, %s(%s))\n", helper, wireType, expr)
+	return nil
+}
+
+func (g *fileGenerator) writeDecode(target string, t ast.SmeType, isField bool) error {
+	if isField && t.IsOptional() {
+		present := g.tmp("present")
+		fmt.Fprintf(&g.body, "if %s, err := smeGetBool(r); err != nil {\nreturn err\n} else if %s {\n", present, present)
+		if isContainer(t) {
+			if err := g.writeDecode(target, t, false); err != nil {
+				return err
+			}
+		} else {
+			typeName, err := g.valueTypeName(t)
+			if err != nil {
+				return err
+			}
+			value := g.tmp("v")
+			fmt.Fprintf(&g.body, "var %s %s\n", value, typeName)
+			if err := g.writeDecode(value, t, false); err != nil {
+				return err
+			}
+			fmt.Fprintf(&g.body, "%s = &%s\n", target, value)
+		}
+		fmt.Fprintf(&g.body, "} else {\n%s = nil\n}\n", target)
+		return nil
+	}
+	switch v := t.(type) {
+	case *ast.SmeList:
+		typeName, err := g.valueTypeName(t)
+		if err != nil {
+			return err
+		}
+		elemType, err := g.valueTypeName(v.ValueType())
+		if err != nil {
+			return err
+		}
+		size, idx, elem := g.tmp("n"), g.tmp("i"), g.tmp("v")
+		fmt.Fprintf(&g.body, "%s, err := smeGetUint32(r)\nif err != nil {\nreturn err\n}\n", size)
+		fmt.Fprintf(&g.body, "%s = make(%s, 0, smeCapacity(r, %s))\n", target, typeName, size)
+		fmt.Fprintf(&g.body, "for %s := uint32(0); %s < %s; %s++ {\n", idx, idx, size, idx)
+		fmt.Fprintf(&g.body, "var %s %s\n", elem, elemType)
+		if err := g.writeDecode(elem, v.ValueType(), false); err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.body, "%s = append(%s, %s)\n}\n", target, target, elem)
+		return nil
+	case *ast.SmeMap:
+		typeName, err := g.valueTypeName(t)
+		if err != nil {
+			return err
+		}
+		keyType, err := g.valueTypeName(v.KeyType())
+		if err != nil {
+			return err
+		}
+		valueType, err := g.valueTypeName(v.ValueType())
+		if err != nil {
+			return err
+		}
+		size, idx, key, value := g.tmp("n"), g.tmp("i"), g.tmp("k"), g.tmp("v")
+		fmt.Fprintf(&g.body, "%s, err := smeGetUint32(r)\nif err != nil {\nreturn err\n}\n", size)
+		fmt.Fprintf(&g.body, "%s = make(%s, smeCapacity(r, %s))\n", target, typeName, size)
+		fmt.Fprintf(&g.body, "for %s := uint32(0); %s < %s; %s++ {\n", idx, idx, size, idx)
+		fmt.Fprintf(&g.body, "var %s %s\nvar %s %s\n", key, keyType, value, valueType)
+		if err := g.writeDecode(key, v.KeyType(), false); err != nil {
+			return err
+		}
+		if err := g.writeDecode(value, v.ValueType(), false); err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.body, "%s[%s] = %s\n}\n", target, key, value)
+		return nil
+	case *ast.UserDefinedStruct:
+		fmt.Fprintf(&g.body, "if err := %s.DecodeSme(r); err != nil {\nreturn err\n}\n", target)
+		return nil
+	case *ast.SmeOneof:
+		n := v.GetImplNode()
+		number := g.tmp("n")
+		fmt.Fprintf(&g.body, "if %s, err := smeGetUint8(r); err != nil {\nreturn err\n} else {\nswitch %s {\n", number, number)
+		fmt.Fprintf(&g.body, "case 0:\n%s = nil\n", target)
+		for i, m := range n.GetMembers() {
+			wrapper := g.tmp("v")
+			fmt.Fprintf(&g.body, "case %d:\nvar %s %s\n", i+1, wrapper, oneofMemberTypeName(n, m))
+			if err := g.writeDecode(wrapper+"."+exportedName(m.GetName()), m.GetFieldType(), false); err != nil {
+				return err
+			}
+			fmt.Fprintf(&g.body, "%s = &%s\n", target, wrapper)
+		}
+		g.body.WriteString("default:\nreturn errSmeUnknownOneofMember\n}\n}\n")
+		return nil
+	}
+	helper, _ := primitiveCodec(t)
+	if helper == "" {
+		return ErrUnsupportedType
+	}
+	typeName, err := g.valueTypeName(t)
+	if err != nil {
+		return err
+	}
+	value := g.tmp("v")
+	fmt.Fprintf(&g.body, "if %s, err := smeGet%s(r); err != nil {\nreturn err\n} else {\n%s = %s(%s)\n}\n", value, helper, target, typeName, value)
+	return nil
+}
+
+// helpers are written into every generated file, so the generated
+// packages do not depend on any runtime library.
+// All the numbers are written in little endian byte order,
+// strings, lists and maps are prefixed with uint32 length.
+const runtimeHelpers = `var errSmeUnknownOneofMember = errors.New("sme: unknown member of oneof")
+
+func smePutUint8(b *bytes.Buffer, v uint8) {
+	b.WriteByte(v)
+}
+
+func smePutUint16(b *bytes.Buffer, v uint16) {
+	var buf [2]byte
+	binary.LittleEndian.PutUint16(buf[:], v)
+	b.Write(buf[:])
+}
+
+func smePutUint32(b *bytes.Buffer, v uint32) {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	b.Write(buf[:])
+}
+
+func smePutUint64(b *bytes.Buffer, v uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	b.Write(buf[:])
+}
+
+func smePutFloat32(b *bytes.Buffer, v float32) {
+	smePutUint32(b, math.Float32bits(v))
+}
+
+func smePutFloat64(b *bytes.Buffer, v float64) {
+	smePutUint64(b, math.Float64bits(v))
+}
+
+func smePutBool(b *bytes.Buffer, v bool) {
+	if v {
+		b.WriteByte(1)
+	} else {
+		b.WriteByte(0)
+	}
+}
+
+func smePutString(b *bytes.Buffer, v string) {
+	smePutUint32(b, uint32(len(v)))
+	b.WriteString(v)
+}
+
+func smeGetUint8(r *bytes.Reader) (uint8, error) {
+	v, err := r.ReadByte()
+	if err == io.EOF {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+func smeGetUint16(r *bytes.Reader) (uint16, error) {
+	var buf [2]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return binary.LittleEndian.Uint16(buf[:]), nil
+}
+
+func smeGetUint32(r *bytes.Reader) (uint32, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
+
+func smeGetUint64(r *bytes.Reader) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
+func smeGetFloat32(r *bytes.Reader) (float32, error) {
+	v, err := smeGetUint32(r)
+	return math.Float32frombits(v), err
+}
+
+func smeGetFloat64(r *bytes.Reader) (float64, error) {
+	v, err := smeGetUint64(r)
+	return math.Float64frombits(v), err
+}
+
+func smeGetBool(r *bytes.Reader) (bool, error) {
+	v, err := smeGetUint8(r)
+	return v != 0, err
+}
+
+// the length of the tagged field is not known before the field is written,
+// so it is reserved and patched by smeEndField
+func smeBeginField(b *bytes.Buffer, tag uint16) int {
+	smePutUint16(b, tag)
+	smePutUint32(b, 0)
+	return b.Len()
+}
+
+func smeEndField(b *bytes.Buffer, start int) {
+	binary.LittleEndian.PutUint32(b.Bytes()[start-4:start], uint32(b.Len()-start))
+}
+
+// returns the tag of the field and the reader of its value
+func smeGetField(r *bytes.Reader) (uint16, *bytes.Reader, error) {
+	tag, err := smeGetUint16(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	n, err := smeGetUint32(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if int64(n) > int64(r.Len()) {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	return tag, bytes.NewReader(buf), nil
+}
+
+// the elements of an empty struct take no bytes, so the length of a list
+// or a map is not checked against the rest of the input, the elements are
+// read until the input ends, but no more is preallocated than the input holds
+func smeCapacity(r *bytes.Reader, n uint32) int {
+	if int64(n) > int64(r.Len()) {
+		return r.Len()
+	}
+	return int(n)
+}
+
+func smeGetString(r *bytes.Reader) (string, error) {
+	n, err := smeGetUint32(r)
+	if err != nil {
+		return "", err
+	}
+	if int64(n) > int64(r.Len()) {
+		return "", io.ErrUnexpectedEOF
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", io.ErrUnexpectedEOF
+	}
+	return string(buf), nil
+}
+
+`
@@ -0,0 +1,172 @@
+package golang
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Ghytro/sme/codegen"
+	"github.com/Ghytro/sme/parser"
+)
+
+// generates the schema into the module "gt" and runs the main program
+// importing the generated packages with the arguments, the program panics
+// if the values do not round-trip. Returns the output of the program
+func runRoundTrip(t *testing.T, schema map[string]string, program string, args ...string) string {
+	t.Helper()
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain is not found")
+	}
+	schemaDir, outDir := t.TempDir(), t.TempDir()
+	for name, content := range schema {
+		if err := os.WriteFile(filepath.Join(schemaDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree, diagnostics := parser.Parse([]string{schemaDir}, parser.DefaultExtensions, 1)
+	if diagnostics.HasErrors() {
+		t.Fatalf("schema has errors: %v", diagnostics.Sorted())
+	}
+	opts := codegen.Options{Params: map[string]string{"package": "gt"}}
+	if err := (Generator{}).Generate(tree, outDir, opts); err != nil {
+		t.Fatalf("Generate: %s", err)
+	}
+	files := map[string]string{
+		"go.mod":      "module gt\n\ngo 1.17\n",
+		"cmd/main.go": program,
+	}
+	for name, content := range files {
+		fileName := filepath.Join(outDir, name)
+		if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(goTool, "vet", "./...")
+	cmd.Dir = outDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go vet: %s\n%s", err, output)
+	}
+	cmd = exec.Command(goTool, append([]string{"run", "./cmd"}, args...)...)
+	cmd.Dir = outDir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %s\n%s", err, output)
+	}
+	return string(output)
+}
+
+// returns the schema shared by the round-trip tests of all the backends
+// and the hex of the bytes of the value every backend encodes
+func loadRoundTripSchema(t *testing.T) (map[string]string, string) {
+	t.Helper()
+	const dir = "../testdata/roundtrip"
+	schema := make(map[string]string)
+	fileNames, err := filepath.Glob(filepath.Join(dir, "*.sme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fileName := range fileNames {
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schema[filepath.Base(fileName)] = string(content)
+	}
+	golden, err := os.ReadFile(filepath.Join(dir, "rt.hex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema, strings.TrimSpace(string(golden))
+}
+
+func TestRoundTrip(t *testing.T) {
+	schema, golden := loadRoundTripSchema(t)
+	output := runRoundTrip(t, schema, `package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"reflect"
+
+	"gt/rt"
+)
+
+func main() {
+	maybe, login := int32(7), "root"
+	value := rt.All{
+		I8: -5, U16: 65535, I64: -1234567890123, U64: 18446744073709551615,
+		F: 1.5, D: -2.25, B: true, C: 'z', S: "h\u00e9llo",
+		Maybe:   &maybe,
+		Origin:  &rt.Point{X: 1, Y: 2},
+		Points:  []rt.Point{{X: 3, Y: 4}, {X: 5, Y: 6}},
+		Empties: []rt.Empty{{}, {}, {}},
+		Counts:  map[string]int32{"a": 1},
+		Color:   rt.Color_BLUE,
+		Colors:  []rt.Color{rt.Color_RED, rt.Color_GREEN},
+		Tagged: rt.Tagged{
+			Id:     42,
+			Login:  &login,
+			Scores: []int32{1, 2, 3},
+		},
+	}
+	value.SetLocation(rt.Point{X: 7, Y: 8})
+	value.Tagged.SetCompany("acme")
+
+	data, err := value.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	golden, err := hex.DecodeString(os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+	var decoded rt.All
+	if err := decoded.UnmarshalBinary(golden); err != nil {
+		panic(err)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		panic(fmt.Sprintf("decoded value differs:\n%+v\nwant:\n%+v", decoded, value))
+	}
+	fmt.Print(hex.EncodeToString(data))
+}
+`, golden)
+	if output != golden {
+		t.Errorf("encoded value differs from rt.hex:\n%s\nwant:\n%s", output, golden)
+	}
+}
+
+func TestRoundTripListOfEmptyStructs(t *testing.T) {
+	schema := map[string]string{
+		"p.sme": "syntax 0.0.1\n\npackage p\n\nstruct Empty {\n}\n\nstruct C {\n    map[int32, Empty] byId\n    list[Empty] items\n}\n",
+	}
+	runRoundTrip(t, schema, `package main
+
+import (
+	"reflect"
+
+	"gt/p"
+)
+
+func main() {
+	in := p.C{Items: make([]p.Empty, 10), ById: map[int32]p.Empty{1: {}, 2: {}}}
+	data, err := in.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	var out p.C
+	if err := out.UnmarshalBinary(data); err != nil {
+		panic(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		panic("values differ")
+	}
+}
+`)
+}
@@ -0,0 +1,1017 @@
+// Package java generates Java 8+ classes for the parsed sme schemas.
+// Every sme package becomes a Java package, every struct becomes
+// a class with a builder, toByteArray and parseFrom methods,
+// every enum becomes a Java enum with getNumber and forNumber methods,
+// every oneof becomes a field holding the value of the member set
+// and a nested <Oneof>Case enum telling which member it is.
+// The serialization is done with ByteBuffer in little endian byte order
+// by the classes of sme.runtime package, which is generated as well.
+//
+// Java has no unsigned integers, so the unsigned sme types are mapped
+// to the signed types wide enough to hold all of their values:
+// uint8 to short, uint16 to int and uint32 to long.
+// uint64 is mapped to long holding the same 64 bits, so the values
+// above Long.MAX_VALUE are negative and should be handled with
+// Long.toUnsignedString, Long.compareUnsigned and Long.divideUnsigned.
+package java
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Ghytro/sme/ast"
+	"github.com/Ghytro/sme/codegen"
+)
+
+var ErrUnsupportedType = errors.New("type is not supported by the java generator")
+
+const runtimePackage = "sme.runtime"
+
+func init() {
+	codegen.Register("java", Generator{})
+}
+
+type Generator struct{}
+
+// Generate writes the sme.runtime package and one <Struct name>.java
+// per struct and one <Enum name>.java per enum of the tree into outDir/<java package>/,
+// the "package" parameter is the java package the packages are put in, like "com.acme",
+// without it the package is put into its java_package option
+func (Generator) Generate(tree *ast.AstTree, outDir string, opts codegen.Options) error {
+	if tree == nil {
+		return codegen.ErrNoAstTree
+	}
+	root := tree.GetRoot()
+	javaPackages := javaPackageNames(root, opts.Param("package"))
+	runtimeDir := filepath.Join(outDir, filepath.FromSlash(strings.ReplaceAll(runtimePackage, ".", "/")))
+	if err := os.MkdirAll(runtimeDir, os.ModePerm); err != nil {
+		return err
+	}
+	for name, src := range runtimeClasses {
+		if err := os.WriteFile(filepath.Join(runtimeDir, name+".java"), []byte(src), 0644); err != nil {
+			return err
+		}
+	}
+	for _, p := range root.GetPackages() {
+		pkgDir := filepath.Join(outDir, packageDir(javaPackages[p.GetName()]))
+		if err := os.MkdirAll(pkgDir, os.ModePerm); err != nil {
+			return err
+		}
+		for _, e := range p.GetEnums() {
+			src, err := generateEnum(root, e, javaPackages)
+			if err != nil {
+				return fmt.Errorf("package %s, enum %s: %w", p.GetName(), e.GetName(), err)
+			}
+			if err := os.WriteFile(filepath.Join(pkgDir, e.GetName()+".java"), src, 0644); err != nil {
+				return err
+			}
+		}
+		for _, s := range p.GetStructs() {
+			src, err := generateClass(root, s, javaPackages)
+			if err != nil {
+				return fmt.Errorf("package %s, struct %s: %w", p.GetName(), s.GetName(), err)
+			}
+			if err := os.WriteFile(filepath.Join(pkgDir, s.GetName()+".java"), src, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var javaKeywords = map[string]bool{
+	"abstract": true, "assert": true, "boolean": true, "break": true, "byte": true,
+	"case": true, "catch": true, "char": true, "class": true, "const": true,
+	"continue": true, "default": true, "do": true, "double": true, "else": true,
+	"enum": true, "extends": true, "final": true, "finally": true, "float": true,
+	"for": true, "goto": true, "if": true, "implements": true, "import": true,
+	"instanceof": true, "int": true, "interface": true, "long": true, "native": true,
+	"new": true, "package": true, "private": true, "protected": true, "public": true,
+	"return": true, "short": true, "static": true, "strictfp": true, "super": true,
+	"switch": true, "synchronized": true, "this": true, "throw": true, "throws": true,
+	"transient": true, "try": true, "void": true, "volatile": true, "while": true,
+	"true": true, "false": true, "null": true,
+}
+
+// java package of the sme package is its name in lower case
+func javaPackageName(packageName string) string {
+	name := strings.ToLower(packageName)
+	if javaKeywords[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// the java package of the sme package put into the package with prefix
+func qualifiedPackageName(prefix string, packageName string) string {
+	if prefix == "" {
+		return javaPackageName(packageName)
+	}
+	return prefix + "." + javaPackageName(packageName)
+}
+
+// returns the java packages of the sme packages by their names: the package
+// named after the sme package inside the one given by the parameter, or the
+// java_package option of the package, or the package named after it
+func javaPackageNames(root *ast.AstModuleNode, packagePrefix string) map[string]string {
+	result := make(map[string]string)
+	for _, p := range root.GetPackages() {
+		if packagePrefix == "" && p.GetJavaPackageName() != nil {
+			result[p.GetName()] = *p.GetJavaPackageName()
+		} else {
+			result[p.GetName()] = qualifiedPackageName(packagePrefix, p.GetName())
+		}
+	}
+	return result
+}
+
+func packageDir(javaPackage string) string {
+	return filepath.FromSlash(strings.ReplaceAll(javaPackage, ".", "/"))
+}
+
+func memberName(fieldName string) string {
+	if javaKeywords[fieldName] {
+		return fieldName + "_"
+	}
+	return fieldName
+}
+
+func upperFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// the final methods of Object, the class can not declare them
+var objectFinalMethods = map[string]bool{
+	"getClass": true, "notify": true, "notifyAll": true, "wait": true,
+}
+
+// returns the name the accessors of the field are made of: get<Name>,
+// set<Name>, has<Name> and clear<Name>, the name is suffixed with _
+// if one of the accessors would be the final method of Object,
+// like getClass of the field named class
+func accessorName(fieldName string) string {
+	name := upperFirst(fieldName)
+	for _, prefix := range []string{"get", "set", "has", "clear"} {
+		if objectFinalMethods[prefix+name] {
+			return name + "_"
+		}
+	}
+	return name
+}
+
+type classGenerator struct {
+	body        bytes.Buffer
+	packageName string
+	// the java packages of the sme packages by their names
+	javaPackages map[string]string
+	lambdaDepth  int
+}
+
+func (g *classGenerator) structTypeName(n *ast.AstStructNode) string {
+	if n.GetPackageName() == g.packageName {
+		return n.GetName()
+	}
+	return g.javaPackages[n.GetPackageName()] + "." + n.GetName()
+}
+
+func (g *classGenerator) enumTypeName(n *ast.AstEnumNode) string {
+	if n.GetPackageName() == g.packageName {
+		return n.GetName()
+	}
+	return g.javaPackages[n.GetPackageName()] + "." + n.GetName()
+}
+
+// returns the java type of the value and its boxed version
+func (g *classGenerator) typeNames(t ast.SmeType) (unboxed string, boxed string, err error) {
+	switch v := t.(type) {
+	case *ast.SmeInt8:
+		return "byte", "Byte", nil
+	case *ast.SmeInt16, *ast.SmeUint8:
+		return "short", "Short", nil
+	case *ast.SmeInt32, *ast.SmeUint16:
+		return "int", "Integer", nil
+	case *ast.SmeInt64, *ast.SmeUint32, *ast.SmeUint64:
+		return "long", "Long", nil
+	case *ast.SmeFloat:
+		return "float", "Float", nil
+	case *ast.SmeDouble:
+		return "double", "Double", nil
+	case *ast.SmeBool:
+		return "boolean", "Boolean", nil
+	case *ast.SmeChar:
+		return "char", "Character", nil
+	case *ast.SmeString:
+		return "String", "String", nil
+	case *ast.SmeList:
+		_, elem, err := g.typeNames(v.ValueType())
+		if err != nil {
+			return "", "", err
+		}
+		name := "java.util.List<" + elem + ">"
+		return name, name, nil
+	case *ast.SmeMap:
+		_, key, err := g.typeNames(v.KeyType())
+		if err != nil {
+			return "", "", err
+		}
+		_, value, err := g.typeNames(v.ValueType())
+		if err != nil {
+			return "", "", err
+		}
+		name := "java.util.Map<" + key + ", " + value + ">"
+		return name, name, nil
+	case *ast.UserDefinedStruct:
+		name := g.structTypeName(v.GetImplNode())
+		return name, name, nil
+	case *ast.SmeEnum:
+		name := g.enumTypeName(v.GetImplNode())
+		return name, name, nil
+	}
+	return "", "", ErrUnsupportedType
+}
+
+// optional fields use boxed types, so null tells that the value is absent
+func (g *classGenerator) fieldTypeName(t ast.SmeType) (string, error) {
+	unboxed, boxed, err := g.typeNames(t)
+	if t.IsOptional() {
+		return boxed, err
+	}
+	return unboxed, err
+}
+
+// the name of SmeWriter and SmeReader methods for the primitive types
+func primitiveCodecName(t ast.SmeType) string {
+	switch t.(type) {
+	case *ast.SmeInt8:
+		return "Int8"
+	case *ast.SmeInt16:
+		return "Int16"
+	case *ast.SmeInt32:
+		return "Int32"
+	case *ast.SmeInt64:
+		return "Int64"
+	case *ast.SmeUint8:
+		return "Uint8"
+	case *ast.SmeUint16:
+		return "Uint16"
+	case *ast.SmeUint32:
+		return "Uint32"
+	case *ast.SmeUint64:
+		return "Uint64"
+	case *ast.SmeFloat:
+		return "Float"
+	case *ast.SmeDouble:
+		return "Double"
+	case *ast.SmeBool:
+		return "Bool"
+	case *ast.SmeChar:
+		return "Char"
+	case *ast.SmeString:
+		return "String"
+	}
+	return ""
+}
+
+// returns SmeWriter.ValueWriter expression for the type
+func (g *classGenerator) writerExpr(t ast.SmeType) (string, error) {
+	switch v := t.(type) {
+	case *ast.SmeList:
+		g.lambdaDepth++
+		w, val := fmt.Sprintf("w%d", g.lambdaDepth), fmt.Sprintf("v%d", g.lambdaDepth)
+		elem, err := g.writerExpr(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%s, %s) -> %s.writeList(%s, %s)", w, val, w, val, elem), nil
+	case *ast.SmeMap:
+		g.lambdaDepth++
+		w, val := fmt.Sprintf("w%d", g.lambdaDepth), fmt.Sprintf("v%d", g.lambdaDepth)
+		key, err := g.writerExpr(v.KeyType())
+		if err != nil {
+			return "", err
+		}
+		value, err := g.writerExpr(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%s, %s) -> %s.writeMap(%s, %s, %s)", w, val, w, val, key, value), nil
+	case *ast.UserDefinedStruct:
+		g.lambdaDepth++
+		w, val := fmt.Sprintf("w%d", g.lambdaDepth), fmt.Sprintf("v%d", g.lambdaDepth)
+		return fmt.Sprintf("(%s, %s) -> %s.writeTo(%s)", w, val, val, w), nil
+	case *ast.SmeEnum:
+		return g.enumTypeName(v.GetImplNode()) + "::writeTo", nil
+	}
+	if name := primitiveCodecName(t); name != "" {
+		return "SmeWriter::write" + name, nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// returns SmeReader.ValueReader expression for the type
+func (g *classGenerator) readerExpr(t ast.SmeType) (string, error) {
+	switch v := t.(type) {
+	case *ast.SmeList:
+		g.lambdaDepth++
+		r := fmt.Sprintf("r%d", g.lambdaDepth)
+		elem, err := g.readerExpr(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s -> %s.readList(%s)", r, r, elem), nil
+	case *ast.SmeMap:
+		g.lambdaDepth++
+		r := fmt.Sprintf("r%d", g.lambdaDepth)
+		key, err := g.readerExpr(v.KeyType())
+		if err != nil {
+			return "", err
+		}
+		value, err := g.readerExpr(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s -> %s.readMap(%s, %s)", r, r, key, value), nil
+	case *ast.UserDefinedStruct:
+		return g.structTypeName(v.GetImplNode()) + "::readFrom", nil
+	case *ast.SmeEnum:
+		return g.enumTypeName(v.GetImplNode()) + "::readFrom", nil
+	}
+	if name := primitiveCodecName(t); name != "" {
+		return "SmeReader::read" + name, nil
+	}
+	return "", ErrUnsupportedType
+}
+
+func (g *classGenerator) initialValue(t ast.SmeType) (string, error) {
+	if v, err := t.DefaultValue(); err == nil {
+		if e, ok := t.(*ast.SmeEnum); ok {
+			return g.enumTypeName(e.GetImplNode()) + "." + memberName(v), nil
+		}
+		return defaultValueLiteral(t, v)
+	}
+	if t.IsOptional() {
+		return "", nil
+	}
+	switch v := t.(type) {
+	case *ast.SmeString:
+		return `""`, nil
+	case *ast.SmeList:
+		return "new java.util.ArrayList<>()", nil
+	case *ast.SmeMap:
+		return "new java.util.LinkedHashMap<>()", nil
+	case *ast.UserDefinedStruct:
+		return "new " + g.structTypeName(v.GetImplNode()) + "()", nil
+	case *ast.SmeEnum:
+		// java enum can not hold the number that is not declared,
+		// so the first value is used if there is no value with zero number
+		values := v.GetImplNode().GetValues()
+		if len(values) == 0 {
+			return "", nil
+		}
+		initial := values[0]
+		for _, ev := range values {
+			if ev.GetNumber() == "0" {
+				initial = ev
+				break
+			}
+		}
+		return g.enumTypeName(v.GetImplNode()) + "." + memberName(initial.GetName()), nil
+	}
+	// the rest of the fields are initialized by java with zeros and nulls
+	return "", nil
+}
+
+func defaultValueLiteral(t ast.SmeType, v string) (string, error) {
+	switch t.(type) {
+	case *ast.SmeInt8:
+		return "(byte) " + v, nil
+	case *ast.SmeInt16, *ast.SmeUint8:
+		return "(short) " + v, nil
+	case *ast.SmeInt64, *ast.SmeUint32:
+		return v + "L", nil
+	case *ast.SmeUint64:
+		return "Long.parseUnsignedLong(\"" + v + "\")", nil
+	case *ast.SmeFloat:
+		return v + "f", nil
+	case *ast.SmeDouble:
+		return v + "d", nil
+	case *ast.SmeChar:
+		return javaCharLiteral(v[0]), nil
+	case *ast.SmeString:
+		return javaStringLiteral(v), nil
+	case *ast.SmeList, *ast.SmeMap, *ast.UserDefinedStruct:
+		return "", ErrUnsupportedType
+	}
+	return v, nil
+}
+
+func javaCharLiteral(c byte) string {
+	switch {
+	case c == '\'' || c == '\\':
+		return `'\` + string(c) + `'`
+	case c >= ' ' && c <= '~':
+		return "'" + string(c) + "'"
+	}
+	return fmt.Sprintf("(char) %d", c)
+}
+
+func javaStringLiteral(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ' || r == 0x7f:
+			// unicode escapes are not used, because java processes them before the lexer
+			fmt.Fprintf(&b, `\%03o`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func generateClass(root *ast.AstModuleNode, s *ast.AstStructNode, javaPackages map[string]string) ([]byte, error) {
+	g := &classGenerator{packageName: s.GetPackageName(), javaPackages: javaPackages}
+	name := s.GetName()
+
+	fmt.Fprintf(&g.body, "// Code generated by sme from syntax %s. DO NOT EDIT.\n\n", root.GetSyntaxVer())
+	fmt.Fprintf(&g.body, "package %s;\n\n", javaPackages[s.GetPackageName()])
+	fmt.Fprintf(&g.body, "import %s.SmeParseException;\n", runtimePackage)
+	fmt.Fprintf(&g.body, "import %s.SmeReader;\n", runtimePackage)
+	fmt.Fprintf(&g.body, "import %s.SmeWriter;\n\n", runtimePackage)
+	fmt.Fprintf(&g.body, "public final class %s {\n", name)
+
+	var (
+		constructor bytes.Buffer
+		accessors   bytes.Buffer
+		builder     bytes.Buffer
+		writeTo     bytes.Buffer
+		readFrom    bytes.Buffer
+	)
+	// the serialization code of every field is kept apart,
+	// so the fields of the tagged struct can be wrapped with their tags
+	var fieldWriteTos, fieldReadFroms []*bytes.Buffer
+	for _, f := range s.GetFields() {
+		t := f.GetFieldType()
+		fieldWriteTo, fieldReadFrom := new(bytes.Buffer), new(bytes.Buffer)
+		fieldWriteTos = append(fieldWriteTos, fieldWriteTo)
+		fieldReadFroms = append(fieldReadFroms, fieldReadFrom)
+		if o, ok := t.(*ast.SmeOneof); ok {
+			err := g.writeOneof(o.GetImplNode(), &constructor, &accessors, &builder, fieldWriteTo, fieldReadFrom)
+			if err != nil {
+				return nil, fmt.Errorf("oneof %s: %w", f.GetName(), err)
+			}
+			continue
+		}
+		typeName, err := g.fieldTypeName(t)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.GetName(), err)
+		}
+		member := memberName(f.GetName())
+		accessor := accessorName(f.GetName())
+		fmt.Fprintf(&g.body, "    private %s %s;\n", typeName, member)
+
+		initial, err := g.initialValue(t)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.GetName(), err)
+		}
+		if initial != "" {
+			fmt.Fprintf(&constructor, "        %s = %s;\n", member, initial)
+		}
+
+		fmt.Fprintf(&accessors, "    public %s get%s() {\n        return %s;\n    }\n\n", typeName, accessor, member)
+		fmt.Fprintf(&accessors, "    public void set%s(%s value) {\n        %s = value;\n    }\n\n", accessor, typeName, member)
+		if t.IsOptional() {
+			fmt.Fprintf(&accessors, "    public boolean has%s() {\n        return %s != null;\n    }\n\n", accessor, member)
+			fmt.Fprintf(&accessors, "    public void clear%s() {\n        %s = null;\n    }\n\n", accessor, member)
+		}
+		fmt.Fprintf(&builder, "        public Builder set%s(%s value) {\n            message.%s = value;\n            return this;\n        }\n\n", accessor, typeName, member)
+
+		writer, err := g.writerExpr(t)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.GetName(), err)
+		}
+		reader, err := g.readerExpr(t)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.GetName(), err)
+		}
+		if t.IsOptional() {
+			fmt.Fprintf(fieldWriteTo, "        writer.writeOptional(%s, %s);\n", member, writer)
+			fmt.Fprintf(fieldReadFrom, "        result.%s = reader.readOptional(%s);\n", member, reader)
+			continue
+		}
+		switch t.(type) {
+		case *ast.SmeList, *ast.SmeMap, *ast.UserDefinedStruct, *ast.SmeEnum:
+			fmt.Fprintf(fieldWriteTo, "        writer.write(%s, %s);\n", member, writer)
+			fmt.Fprintf(fieldReadFrom, "        result.%s = reader.read(%s);\n", member, reader)
+		default:
+			codec := primitiveCodecName(t)
+			fmt.Fprintf(fieldWriteTo, "        writer.write%s(%s);\n", codec, member)
+			fmt.Fprintf(fieldReadFrom, "        result.%s = reader.read%s();\n", member, codec)
+		}
+	}
+
+	if s.IsTagged() {
+		g.writeTaggedSerialization(s, fieldWriteTos, fieldReadFroms, &writeTo, &readFrom)
+	} else {
+		for i := range fieldWriteTos {
+			writeTo.Write(fieldWriteTos[i].Bytes())
+			readFrom.Write(fieldReadFroms[i].Bytes())
+		}
+	}
+
+	fmt.Fprintf(&g.body, "\n    public %s() {\n", name)
+	g.body.Write(constructor.Bytes())
+	g.body.WriteString("    }\n\n")
+	g.body.Write(accessors.Bytes())
+
+	g.body.WriteString("    public static Builder newBuilder() {\n        return new Builder();\n    }\n\n")
+
+	g.body.WriteString("    public byte[] toByteArray() {\n")
+	g.body.WriteString("        SmeWriter writer = new SmeWriter();\n        writeTo(writer);\n        return writer.toByteArray();\n    }\n\n")
+
+	fmt.Fprintf(&g.body, "    public static %s parseFrom(byte[] data) throws SmeParseException {\n", name)
+	g.body.WriteString("        return readFrom(new SmeReader(data));\n    }\n\n")
+
+	g.body.WriteString("    public void writeTo(SmeWriter writer) {\n")
+	g.body.Write(writeTo.Bytes())
+	g.body.WriteString("    }\n\n")
+
+	fmt.Fprintf(&g.body, "    public static %s readFrom(SmeReader reader) throws SmeParseException {\n", name)
+	fmt.Fprintf(&g.body, "        %s result = new %s();\n", name, name)
+	if s.IsTagged() {
+		g.body.WriteString("        long count = reader.readUint32();\n")
+		g.body.WriteString("        for (long i = 0; i < count; i++) {\n")
+		g.body.WriteString("            int tag = reader.readUint16();\n")
+		g.body.WriteString("            readField(result, tag, reader.readField());\n")
+		g.body.WriteString("        }\n")
+		g.body.WriteString("        return result;\n    }\n\n")
+		fmt.Fprintf(&g.body, "    private static void readField(%s result, int tag, SmeReader reader) throws SmeParseException {\n", name)
+		g.body.Write(readFrom.Bytes())
+		g.body.WriteString("    }\n\n")
+	} else {
+		g.body.Write(readFrom.Bytes())
+		g.body.WriteString("        return result;\n    }\n\n")
+	}
+
+	g.body.WriteString("    public static final class Builder {\n")
+	fmt.Fprintf(&g.body, "        private %s message = new %s();\n\n", name, name)
+	g.body.WriteString("        private Builder() {\n        }\n\n")
+	g.body.Write(builder.Bytes())
+	fmt.Fprintf(&g.body, "        public %s build() {\n", name)
+	fmt.Fprintf(&g.body, "            %s result = message;\n            message = new %s();\n            return result;\n        }\n", name, name)
+	g.body.WriteString("    }\n}\n")
+	return g.body.Bytes(), nil
+}
+
+// the fields of the tagged struct are written between beginField and endField,
+// every field is read from its own reader by readField, so the fields with
+// unknown tags are skipped and the missing fields keep their initial values
+func (g *classGenerator) writeTaggedSerialization(s *ast.AstStructNode, fieldWriteTos, fieldReadFroms []*bytes.Buffer, writeTo, readFrom *bytes.Buffer) {
+	fmt.Fprintf(writeTo, "        writer.writeUint32(%d);\n", len(s.GetFields()))
+	readFrom.WriteString("        switch (tag) {\n")
+	for i, f := range s.GetFields() {
+		fmt.Fprintf(writeTo, "        writer.beginField(%d);\n", f.GetTag())
+		writeTo.Write(fieldWriteTos[i].Bytes())
+		writeTo.WriteString("        writer.endField();\n")
+
+		fmt.Fprintf(readFrom, "        case %d:\n", f.GetTag())
+		for _, line := range strings.SplitAfter(fieldReadFroms[i].String(), "\n") {
+			if line != "" {
+				readFrom.WriteString("    " + line)
+			}
+		}
+		readFrom.WriteString("            break;\n")
+	}
+	readFrom.WriteString("        default:\n            break;\n        }\n")
+}
+
+// converts camelCase name to CAMEL_CASE
+func constantName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if i > 0 && c >= 'A' && c <= 'Z' && name[i-1] != '_' && !(name[i-1] >= 'A' && name[i-1] <= 'Z') {
+			b.WriteByte('_')
+		}
+		b.WriteByte(c)
+	}
+	return strings.ToUpper(b.String())
+}
+
+// the value of the member set is kept in the field of Object type,
+// the members are accessed by the getters returning the boxed values,
+// which are null if the other member is set
+func (g *classGenerator) writeOneof(n *ast.AstOneofNode, constructor, accessors, builder, writeTo, readFrom *bytes.Buffer) error {
+	member := memberName(n.GetName())
+	accessor := accessorName(n.GetName())
+	caseType := accessor + "Case"
+	caseMember := n.GetName() + "Case"
+	notSet := constantName(n.GetName()) + "_NOT_SET"
+
+	fmt.Fprintf(&g.body, "    private Object %s;\n", member)
+	fmt.Fprintf(&g.body, "    private %s %s;\n", caseType, caseMember)
+	fmt.Fprintf(constructor, "        %s = %s.%s;\n", caseMember, caseType, notSet)
+
+	fmt.Fprintf(accessors, "    public enum %s {\n", caseType)
+	for _, m := range n.GetMembers() {
+		fmt.Fprintf(accessors, "        %s,\n", constantName(m.GetName()))
+	}
+	fmt.Fprintf(accessors, "        %s\n    }\n\n", notSet)
+	fmt.Fprintf(accessors, "    public %s get%s() {\n        return %s;\n    }\n\n", caseType, caseType, caseMember)
+	fmt.Fprintf(accessors, "    public void clear%s() {\n        %s = null;\n        %s = %s.%s;\n    }\n\n", accessor, member, caseMember, caseType, notSet)
+
+	fmt.Fprintf(writeTo, "        switch (%s) {\n", caseMember)
+	fmt.Fprintf(readFrom, "        switch (reader.readUint8()) {\n        case 0:\n            break;\n")
+	for i, m := range n.GetMembers() {
+		t := m.GetFieldType()
+		_, boxed, err := g.typeNames(t)
+		if err != nil {
+			return fmt.Errorf("member %s: %w", m.GetName(), err)
+		}
+		name := accessorName(m.GetName())
+		constant := constantName(m.GetName())
+
+		fmt.Fprintf(accessors, "    public boolean has%s() {\n        return %s == %s.%s;\n    }\n\n", name, caseMember, caseType, constant)
+		switch t.(type) {
+		case *ast.SmeList, *ast.SmeMap:
+			accessors.WriteString("    @SuppressWarnings(\"unchecked\")\n")
+		}
+		fmt.Fprintf(accessors, "    public %s get%s() {\n        return has%s() ? (%s) %s : null;\n    }\n\n", boxed, name, name, boxed, member)
+		fmt.Fprintf(accessors, "    public void set%s(%s value) {\n        %s = value;\n        %s = %s.%s;\n    }\n\n", name, boxed, member, caseMember, caseType, constant)
+		fmt.Fprintf(builder, "        public Builder set%s(%s value) {\n            message.set%s(value);\n            return this;\n        }\n\n", name, boxed, name)
+
+		fmt.Fprintf(writeTo, "        case %s:\n            writer.writeUint8((short) %d);\n", constant, i+1)
+		fmt.Fprintf(readFrom, "        case %d:\n", i+1)
+		switch t.(type) {
+		case *ast.SmeList, *ast.SmeMap, *ast.UserDefinedStruct, *ast.SmeEnum:
+			writer, err := g.writerExpr(t)
+			if err != nil {
+				return fmt.Errorf("member %s: %w", m.GetName(), err)
+			}
+			reader, err := g.readerExpr(t)
+			if err != nil {
+				return fmt.Errorf("member %s: %w", m.GetName(), err)
+			}
+			fmt.Fprintf(writeTo, "            writer.write(get%s(), %s);\n", name, writer)
+			fmt.Fprintf(readFrom, "            result.set%s(reader.read(%s));\n", name, reader)
+		default:
+			codec := primitiveCodecName(t)
+			fmt.Fprintf(writeTo, "            writer.write%s(get%s());\n", codec, name)
+			fmt.Fprintf(readFrom, "            result.set%s(reader.read%s());\n", name, codec)
+		}
+		writeTo.WriteString("            break;\n")
+		readFrom.WriteString("            break;\n")
+	}
+	writeTo.WriteString("        default:\n            writer.writeUint8((short) 0);\n        }\n")
+	fmt.Fprintf(readFrom, "        default:\n            throw new SmeParseException(\"unknown member of oneof %s\");\n        }\n", n.GetName())
+	return nil
+}
+
+// the enum is written as its underlying integer type, the numbers
+// that are not declared in the enum are rejected by forNumber
+func generateEnum(root *ast.AstModuleNode, e *ast.AstEnumNode, javaPackages map[string]string) ([]byte, error) {
+	g := &classGenerator{packageName: e.GetPackageName(), javaPackages: javaPackages}
+	name := e.GetName()
+	underlying := e.GetUnderlyingType()
+	numberType, _, err := g.typeNames(underlying)
+	if err != nil {
+		return nil, err
+	}
+	codec := primitiveCodecName(underlying)
+
+	fmt.Fprintf(&g.body, "// Code generated by sme from syntax %s. DO NOT EDIT.\n\n", root.GetSyntaxVer())
+	fmt.Fprintf(&g.body, "package %s;\n\n", javaPackages[e.GetPackageName()])
+	fmt.Fprintf(&g.body, "import %s.SmeParseException;\n", runtimePackage)
+	fmt.Fprintf(&g.body, "import %s.SmeReader;\n", runtimePackage)
+	fmt.Fprintf(&g.body, "import %s.SmeWriter;\n\n", runtimePackage)
+	fmt.Fprintf(&g.body, "public enum %s {\n", name)
+	for i, v := range e.GetValues() {
+		number, err := defaultValueLiteral(underlying, v.GetNumber())
+		if err != nil {
+			return nil, err
+		}
+		separator := ","
+		if i == len(e.GetValues())-1 {
+			separator = ";"
+		}
+		fmt.Fprintf(&g.body, "    %s(%s)%s\n", memberName(v.GetName()), number, separator)
+	}
+	if len(e.GetValues()) == 0 {
+		g.body.WriteString("    ;\n")
+	}
+
+	fmt.Fprintf(&g.body, "\n    private final %s number;\n\n", numberType)
+	fmt.Fprintf(&g.body, "    %s(%s number) {\n        this.number = number;\n    }\n\n", name, numberType)
+	fmt.Fprintf(&g.body, "    public %s getNumber() {\n        return number;\n    }\n\n", numberType)
+
+	fmt.Fprintf(&g.body, "    public static %s forNumber(%s number) throws SmeParseException {\n", name, numberType)
+	fmt.Fprintf(&g.body, "        for (%s value : values()) {\n", name)
+	g.body.WriteString("            if (value.number == number) {\n                return value;\n            }\n        }\n")
+	fmt.Fprintf(&g.body, "        throw new SmeParseException(\"unknown number of %s: \" + number);\n    }\n\n", name)
+
+	fmt.Fprintf(&g.body, "    public static void writeTo(SmeWriter writer, %s value) {\n", name)
+	fmt.Fprintf(&g.body, "        writer.write%s(value.number);\n    }\n\n", codec)
+	fmt.Fprintf(&g.body, "    public static %s readFrom(SmeReader reader) throws SmeParseException {\n", name)
+	fmt.Fprintf(&g.body, "        return forNumber(reader.read%s());\n    }\n}\n", codec)
+	return g.body.Bytes(), nil
+}
+
+// the classes of sme.runtime package shared by all the generated classes.
+// All the numbers are written in little endian byte order,
+// strings, lists and maps are prefixed with uint32 length,
+// optional values are prefixed with the byte telling if the value is present.
+var runtimeClasses = map[string]string{
+	"SmeParseException": `// Code generated by sme. DO NOT EDIT.
+
+package sme.runtime;
+
+public class SmeParseException extends Exception {
+    public SmeParseException(String message) {
+        super(message);
+    }
+}
+`,
+	"SmeWriter": `// Code generated by sme. DO NOT EDIT.
+
+package sme.runtime;
+
+import java.nio.ByteBuffer;
+import java.nio.ByteOrder;
+import java.nio.charset.StandardCharsets;
+import java.util.ArrayDeque;
+import java.util.Arrays;
+import java.util.Deque;
+import java.util.List;
+import java.util.Map;
+
+public final class SmeWriter {
+    public interface ValueWriter<T> {
+        void write(SmeWriter writer, T value);
+    }
+
+    private ByteBuffer buffer = ByteBuffer.allocate(64).order(ByteOrder.LITTLE_ENDIAN);
+
+    // the positions of the values of the fields being written
+    private final Deque<Integer> fieldStarts = new ArrayDeque<>();
+
+    private void ensureRemaining(int size) {
+        if (buffer.remaining() >= size) {
+            return;
+        }
+        int capacity = Math.max(buffer.capacity() * 2, buffer.position() + size);
+        ByteBuffer grown = ByteBuffer.allocate(capacity).order(ByteOrder.LITTLE_ENDIAN);
+        buffer.flip();
+        grown.put(buffer);
+        buffer = grown;
+    }
+
+    public void writeInt8(byte value) {
+        ensureRemaining(1);
+        buffer.put(value);
+    }
+
+    public void writeInt16(short value) {
+        ensureRemaining(2);
+        buffer.putShort(value);
+    }
+
+    public void writeInt32(int value) {
+        ensureRemaining(4);
+        buffer.putInt(value);
+    }
+
+    public void writeInt64(long value) {
+        ensureRemaining(8);
+        buffer.putLong(value);
+    }
+
+    public void writeUint8(short value) {
+        writeInt8((byte) value);
+    }
+
+    public void writeUint16(int value) {
+        writeInt16((short) value);
+    }
+
+    public void writeUint32(long value) {
+        writeInt32((int) value);
+    }
+
+    public void writeUint64(long value) {
+        writeInt64(value);
+    }
+
+    public void writeFloat(float value) {
+        ensureRemaining(4);
+        buffer.putFloat(value);
+    }
+
+    public void writeDouble(double value) {
+        ensureRemaining(8);
+        buffer.putDouble(value);
+    }
+
+    public void writeBool(boolean value) {
+        writeInt8((byte) (value ? 1 : 0));
+    }
+
+    public void writeChar(char value) {
+        writeInt8((byte) value);
+    }
+
+    public void writeString(String value) {
+        byte[] bytes = value.getBytes(StandardCharsets.UTF_8);
+        writeInt32(bytes.length);
+        ensureRemaining(bytes.length);
+        buffer.put(bytes);
+    }
+
+    public <T> void write(T value, ValueWriter<T> valueWriter) {
+        valueWriter.write(this, value);
+    }
+
+    public <T> void writeOptional(T value, ValueWriter<T> valueWriter) {
+        writeBool(value != null);
+        if (value != null) {
+            valueWriter.write(this, value);
+        }
+    }
+
+    public <T> void writeList(List<T> value, ValueWriter<T> valueWriter) {
+        writeInt32(value.size());
+        for (T x : value) {
+            valueWriter.write(this, x);
+        }
+    }
+
+    public <K, V> void writeMap(Map<K, V> value, ValueWriter<K> keyWriter, ValueWriter<V> valueWriter) {
+        writeInt32(value.size());
+        for (Map.Entry<K, V> e : value.entrySet()) {
+            keyWriter.write(this, e.getKey());
+            valueWriter.write(this, e.getValue());
+        }
+    }
+
+    // the field of the tagged struct is prefixed with its tag and length,
+    // the length is patched by endField when the value is written
+    public void beginField(int tag) {
+        writeUint16(tag);
+        writeInt32(0);
+        fieldStarts.push(buffer.position());
+    }
+
+    public void endField() {
+        int start = fieldStarts.pop();
+        buffer.putInt(start - 4, buffer.position() - start);
+    }
+
+    public byte[] toByteArray() {
+        return Arrays.copyOf(buffer.array(), buffer.position());
+    }
+}
+`,
+	"SmeReader": `// Code generated by sme. DO NOT EDIT.
+
+package sme.runtime;
+
+import java.nio.ByteBuffer;
+import java.nio.ByteOrder;
+import java.nio.charset.StandardCharsets;
+import java.util.ArrayList;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+
+public final class SmeReader {
+    public interface ValueReader<T> {
+        T read(SmeReader reader) throws SmeParseException;
+    }
+
+    private final ByteBuffer buffer;
+
+    public SmeReader(byte[] data) {
+        buffer = ByteBuffer.wrap(data).order(ByteOrder.LITTLE_ENDIAN);
+    }
+
+    private void requireRemaining(long size) throws SmeParseException {
+        if (buffer.remaining() < size) {
+            throw new SmeParseException("unexpected end of data");
+        }
+    }
+
+    public byte readInt8() throws SmeParseException {
+        requireRemaining(1);
+        return buffer.get();
+    }
+
+    public short readInt16() throws SmeParseException {
+        requireRemaining(2);
+        return buffer.getShort();
+    }
+
+    public int readInt32() throws SmeParseException {
+        requireRemaining(4);
+        return buffer.getInt();
+    }
+
+    public long readInt64() throws SmeParseException {
+        requireRemaining(8);
+        return buffer.getLong();
+    }
+
+    public short readUint8() throws SmeParseException {
+        return (short) (readInt8() & 0xFF);
+    }
+
+    public int readUint16() throws SmeParseException {
+        return readInt16() & 0xFFFF;
+    }
+
+    public long readUint32() throws SmeParseException {
+        return readInt32() & 0xFFFFFFFFL;
+    }
+
+    public long readUint64() throws SmeParseException {
+        return readInt64();
+    }
+
+    public float readFloat() throws SmeParseException {
+        requireRemaining(4);
+        return buffer.getFloat();
+    }
+
+    public double readDouble() throws SmeParseException {
+        requireRemaining(8);
+        return buffer.getDouble();
+    }
+
+    public boolean readBool() throws SmeParseException {
+        return readInt8() != 0;
+    }
+
+    public char readChar() throws SmeParseException {
+        return (char) (readInt8() & 0xFF);
+    }
+
+    public String readString() throws SmeParseException {
+        long size = readUint32();
+        requireRemaining(size);
+        byte[] bytes = new byte[(int) size];
+        buffer.get(bytes);
+        return new String(bytes, StandardCharsets.UTF_8);
+    }
+
+    // returns the reader of the value of the tagged field,
+    // the value is skipped if it is not read
+    public SmeReader readField() throws SmeParseException {
+        long size = readUint32();
+        requireRemaining(size);
+        byte[] bytes = new byte[(int) size];
+        buffer.get(bytes);
+        return new SmeReader(bytes);
+    }
+
+    public <T> T read(ValueReader<T> valueReader) throws SmeParseException {
+        return valueReader.read(this);
+    }
+
+    public <T> T readOptional(ValueReader<T> valueReader) throws SmeParseException {
+        if (readBool()) {
+            return valueReader.read(this);
+        }
+        return null;
+    }
+
+    public <T> List<T> readList(ValueReader<T> valueReader) throws SmeParseException {
+        long size = readUint32();
+        List<T> result = new ArrayList<>();
+        for (long i = 0; i < size; i++) {
+            result.add(valueReader.read(this));
+        }
+        return result;
+    }
+
+    public <K, V> Map<K, V> readMap(ValueReader<K> keyReader, ValueReader<V> valueReader) throws SmeParseException {
+        long size = readUint32();
+        Map<K, V> result = new LinkedHashMap<>();
+        for (long i = 0; i < size; i++) {
+            K key = keyReader.read(this);
+            result.put(key, valueReader.read(this));
+        }
+        return result;
+    }
+}
+`,
+}
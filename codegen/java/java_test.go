@@ -0,0 +1,209 @@
+package java
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Ghytro/sme/codegen"
+	"github.com/Ghytro/sme/parser"
+)
+
+// parses the schema and generates the java classes of it into the returned directory
+func generate(t *testing.T, schema map[string]string, opts codegen.Options) string {
+	t.Helper()
+	schemaDir, outDir := t.TempDir(), t.TempDir()
+	for name, content := range schema {
+		if err := os.WriteFile(filepath.Join(schemaDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree, diagnostics := parser.Parse([]string{schemaDir}, parser.DefaultExtensions, 1)
+	if diagnostics.HasErrors() {
+		t.Fatalf("schema has errors: %v", diagnostics.Sorted())
+	}
+	if err := (Generator{}).Generate(tree, outDir, opts); err != nil {
+		t.Fatalf("Generate: %s", err)
+	}
+	return outDir
+}
+
+// generates the schema, compiles the Main class of the program together
+// with the generated classes and runs it with the arguments, the program exits
+// with non zero code if the values do not round-trip. Returns the output of the program
+func runRoundTrip(t *testing.T, schema map[string]string, program string, args ...string) string {
+	t.Helper()
+	javac, err := exec.LookPath("javac")
+	if err != nil {
+		t.Skip("javac is not found")
+	}
+	java, err := exec.LookPath("java")
+	if err != nil {
+		t.Skip("java is not found")
+	}
+	outDir := generate(t, schema, codegen.Options{})
+	if err := os.WriteFile(filepath.Join(outDir, "Main.java"), []byte(program), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var sources []string
+	err = filepath.Walk(outDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(path, ".java") {
+			sources = append(sources, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	classesDir := t.TempDir()
+	cmd := exec.Command(javac, append([]string{"-encoding", "UTF-8", "-d", classesDir}, sources...)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("compile: %s\n%s", err, output)
+	}
+	output, err := exec.Command(java, append([]string{"-cp", classesDir, "Main"}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("run: %s\n%s", err, output)
+	}
+	return string(output)
+}
+
+// returns the schema shared by the round-trip tests of all the backends
+// and the hex of the bytes of the value every backend encodes
+func loadRoundTripSchema(t *testing.T) (map[string]string, string) {
+	t.Helper()
+	const dir = "../testdata/roundtrip"
+	schema := make(map[string]string)
+	fileNames, err := filepath.Glob(filepath.Join(dir, "*.sme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fileName := range fileNames {
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schema[filepath.Base(fileName)] = string(content)
+	}
+	golden, err := os.ReadFile(filepath.Join(dir, "rt.hex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema, strings.TrimSpace(string(golden))
+}
+
+func TestRoundTrip(t *testing.T) {
+	schema, golden := loadRoundTripSchema(t)
+	output := runRoundTrip(t, schema, `import java.util.Arrays;
+
+import rt.All;
+import rt.Color;
+import rt.Empty;
+import rt.Point;
+import rt.Tagged;
+
+public class Main {
+    private static String toHex(byte[] bytes) {
+        StringBuilder result = new StringBuilder();
+        for (byte b : bytes) {
+            result.append(String.format("%02x", b & 0xFF));
+        }
+        return result.toString();
+    }
+
+    private static byte[] fromHex(String hex) {
+        byte[] result = new byte[hex.length() / 2];
+        for (int i = 0; i < result.length; i++) {
+            result[i] = (byte) Integer.parseInt(hex.substring(2 * i, 2 * i + 2), 16);
+        }
+        return result;
+    }
+
+    private static Point point(int x, int y) {
+        return Point.newBuilder().setX(x).setY(y).build();
+    }
+
+    private static void check(boolean ok, String message) {
+        if (!ok) {
+            System.err.println(message);
+            System.exit(1);
+        }
+    }
+
+    public static void main(String[] args) throws Exception {
+        Tagged tagged = Tagged.newBuilder()
+            .setId(42)
+            .setLogin("root")
+            .setCompany("acme")
+            .setScores(Arrays.asList(1, 2, 3))
+            .build();
+        All value = All.newBuilder()
+            .setI8((byte) -5)
+            .setU16(65535)
+            .setI64(-1234567890123L)
+            .setU64(-1L)
+            .setF(1.5f)
+            .setD(-2.25)
+            .setB(true)
+            .setC('z')
+            .setS("h\u00e9llo")
+            .setMaybe(7)
+            .setOrigin(point(1, 2))
+            .setPoints(Arrays.asList(point(3, 4), point(5, 6)))
+            .setEmpties(Arrays.asList(new Empty(), new Empty(), new Empty()))
+            .setColor(Color.BLUE)
+            .setColors(Arrays.asList(Color.RED, Color.GREEN))
+            .setLocation(point(7, 8))
+            .setTagged(tagged)
+            .build();
+        value.getCounts().put("a", 1);
+
+        byte[] golden = fromHex(args[0]);
+        All decoded = All.parseFrom(golden);
+        check(Arrays.equals(decoded.toByteArray(), golden), "decoded value is encoded differently");
+        check(decoded.getS().equals(value.getS()), "s is " + decoded.getS());
+        check(decoded.getMaybe() == 7 && !decoded.hasMissing(), "wrong optional fields");
+        check(decoded.getOrigin().getY() == 2 && decoded.getPoints().size() == 2, "wrong points");
+        check(decoded.getEmpties().size() == 3, "wrong empties");
+        check(decoded.getCounts().get("a") == 1, "wrong counts");
+        check(decoded.getColor() == Color.BLUE && decoded.getColors().get(1) == Color.GREEN, "wrong colors");
+        check(decoded.getContactCase() == All.ContactCase.LOCATION && decoded.getLocation().getX() == 7, "wrong contact");
+        check(decoded.getTagged().getOwnerCase() == Tagged.OwnerCase.COMPANY, "wrong owner");
+        check(decoded.getTagged().getLogin().equals("root") && decoded.getTagged().getScores().size() == 3, "wrong tagged");
+        System.out.print(toHex(value.toByteArray()));
+    }
+}
+`, golden)
+	if output != golden {
+		t.Errorf("encoded value differs from rt.hex:\n%s\nwant:\n%s", output, golden)
+	}
+}
+
+func TestAccessorsDoNotOverrideObjectMethods(t *testing.T) {
+	schema := map[string]string{
+		"p.sme": "syntax 0.0.1\n\npackage p\n\nstruct S {\n    int32 class\n    optional int32 notify\n" +
+			"    oneof wait {\n        string getClass\n        int32 x\n    }\n}\n",
+	}
+	outDir := generate(t, schema, codegen.Options{})
+	src, err := os.ReadFile(filepath.Join(outDir, "p", "S.java"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"private int class_;",
+		"public int getClass_()",
+		"public void setClass_(int value)",
+		"public boolean hasNotify()",
+		"public void clearWait()",
+		"public String getGetClass()",
+		"public Builder setClass_(int value)",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("S.java does not contain %q", want)
+		}
+	}
+	if strings.Contains(string(src), "getClass()") {
+		t.Errorf("S.java declares getClass():\n%s", src)
+	}
+}
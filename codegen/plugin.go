@@ -0,0 +1,304 @@
+package codegen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Ghytro/sme/ast"
+)
+
+// The languages that are not built in are generated by the external
+// executables named sme-gen-<lang> found in PATH, the same way protoc
+// runs its plugins. The plugin gets PluginRequest encoded in JSON on
+// stdin and writes PluginResponse encoded in JSON to stdout, the
+// diagnostic output of the plugin should go to stderr.
+// PluginProtocolVersion is increased on every incompatible change of
+// the request or response, the plugin should refuse the requests with
+// the version it does not know.
+const PluginProtocolVersion = 1
+
+const PluginExecutablePrefix = "sme-gen-"
+
+var ErrPluginVersionMismatch = errors.New("plugin responded with unsupported protocol version")
+var ErrPluginFileOutsideOutDir = errors.New("plugin tried to write the file outside of the output directory")
+
+type PluginRequest struct {
+	Version       int               `json:"version"`
+	Language      string            `json:"language"`
+	SyntaxVersion string            `json:"syntaxVersion"`
+	Params        map[string]string `json:"params,omitempty"`
+	Packages      []PluginPackage   `json:"packages"`
+}
+
+// the options are set only if the files of the package set them
+type PluginPackage struct {
+	Name         string         `json:"name"`
+	CppNamespace *string        `json:"cppNamespace,omitempty"`
+	GoPackage    *string        `json:"goPackage,omitempty"`
+	JavaPackage  *string        `json:"javaPackage,omitempty"`
+	PythonModule *string        `json:"pythonModule,omitempty"`
+	Enums        []PluginEnum   `json:"enums,omitempty"`
+	Structs      []PluginStruct `json:"structs"`
+}
+
+// Type is the underlying integer type of the enum, the numbers
+// of the values are written in decimal form
+type PluginEnum struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"`
+	Values []PluginEnumValue `json:"values"`
+}
+
+type PluginEnumValue struct {
+	Name   string `json:"name"`
+	Number string `json:"number"`
+}
+
+type PluginStruct struct {
+	Name   string        `json:"name"`
+	Fields []PluginField `json:"fields"`
+}
+
+// Tag is set for the fields of the tagged structs only
+type PluginField struct {
+	Name string     `json:"name"`
+	Tag  int        `json:"tag,omitempty"`
+	Type PluginType `json:"type"`
+}
+
+// Kind is one of the primitive type names ("int8", "uint64", "string", ...),
+// "list", "map", "struct", "enum" or "oneof". Key is set for maps only, Value
+// is set for lists and maps, Package and Struct are set for structs only, Package
+// and Enum are set for enums only, Members are set for oneofs only.
+type PluginType struct {
+	Kind         string      `json:"kind"`
+	Optional     bool        `json:"optional,omitempty"`
+	DefaultValue *string     `json:"defaultValue,omitempty"`
+	Key          *PluginType `json:"key,omitempty"`
+	Value        *PluginType `json:"value,omitempty"`
+	Package      string      `json:"package,omitempty"`
+	Struct       string      `json:"struct,omitempty"`
+	Enum         string      `json:"enum,omitempty"`
+	// the number of the member on the wire is its index plus one
+	Members []PluginField `json:"members,omitempty"`
+}
+
+type PluginResponse struct {
+	Version int `json:"version"`
+	// non empty error tells that the plugin failed to generate the code
+	Error string       `json:"error,omitempty"`
+	Files []PluginFile `json:"files"`
+}
+
+type PluginFile struct {
+	// path relative to the output directory, using forward slashes
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+type pluginGenerator struct {
+	lang string
+	path string
+}
+
+// returns the generator running sme-gen-<lang> from PATH
+func lookupPlugin(lang string) (Generator, bool) {
+	if lang == "" {
+		return nil, false
+	}
+	path, err := exec.LookPath(PluginExecutablePrefix + lang)
+	if err != nil {
+		return nil, false
+	}
+	return &pluginGenerator{lang: lang, path: path}, true
+}
+
+func (pg *pluginGenerator) Generate(tree *ast.AstTree, outDir string, opts Options) error {
+	if tree == nil {
+		return ErrNoAstTree
+	}
+	request, err := NewPluginRequest(tree, pg.lang, opts)
+	if err != nil {
+		return err
+	}
+	input, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	var output bytes.Buffer
+	cmd := exec.Command(pg.path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &output
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("plugin %s: %w", pg.path, err)
+	}
+
+	var response PluginResponse
+	if err := json.Unmarshal(output.Bytes(), &response); err != nil {
+		return fmt.Errorf("plugin %s: incorrect response: %w", pg.path, err)
+	}
+	if response.Version != PluginProtocolVersion {
+		return fmt.Errorf("plugin %s: %w: %d", pg.path, ErrPluginVersionMismatch, response.Version)
+	}
+	if response.Error != "" {
+		return fmt.Errorf("plugin %s: %s", pg.path, response.Error)
+	}
+	return writePluginFiles(outDir, response.Files)
+}
+
+// the names of the files are relative to outDir and can not lead out of it,
+// the names starting with dots like "..x" are the names of the files in it
+func writePluginFiles(outDir string, files []PluginFile) error {
+	for _, f := range files {
+		name := filepath.FromSlash(f.Name)
+		cleaned := filepath.Clean(name)
+		if name == "" || filepath.IsAbs(name) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%w: %s", ErrPluginFileOutsideOutDir, f.Name)
+		}
+		path := filepath.Join(outDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(f.Content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewPluginRequest describes the tree in the form sent to the plugins
+func NewPluginRequest(tree *ast.AstTree, lang string, opts Options) (*PluginRequest, error) {
+	root := tree.GetRoot()
+	request := &PluginRequest{
+		Version:       PluginProtocolVersion,
+		Language:      lang,
+		SyntaxVersion: root.GetSyntaxVer(),
+		Params:        opts.Params,
+		Packages:      make([]PluginPackage, 0, len(root.GetPackages())),
+	}
+	for _, p := range root.GetPackages() {
+		pkg := PluginPackage{
+			Name:         p.GetName(),
+			CppNamespace: p.GetCppNamespaceName(),
+			GoPackage:    p.GetGoPackageName(),
+			JavaPackage:  p.GetJavaPackageName(),
+			PythonModule: p.GetPythonModuleName(),
+			Structs:      make([]PluginStruct, 0, len(p.GetStructs())),
+		}
+		for _, e := range p.GetEnums() {
+			underlying, err := describeType(e.GetUnderlyingType())
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", p.GetName(), e.GetName(), err)
+			}
+			en := PluginEnum{
+				Name:   e.GetName(),
+				Type:   underlying.Kind,
+				Values: make([]PluginEnumValue, 0, len(e.GetValues())),
+			}
+			for _, v := range e.GetValues() {
+				en.Values = append(en.Values, PluginEnumValue{Name: v.GetName(), Number: v.GetNumber()})
+			}
+			pkg.Enums = append(pkg.Enums, en)
+		}
+		for _, s := range p.GetStructs() {
+			st := PluginStruct{
+				Name:   s.GetName(),
+				Fields: make([]PluginField, 0, len(s.GetFields())),
+			}
+			for _, f := range s.GetFields() {
+				t, err := describeType(f.GetFieldType())
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s.%s: %w", p.GetName(), s.GetName(), f.GetName(), err)
+				}
+				st.Fields = append(st.Fields, PluginField{Name: f.GetName(), Tag: f.GetTag(), Type: *t})
+			}
+			pkg.Structs = append(pkg.Structs, st)
+		}
+		request.Packages = append(request.Packages, pkg)
+	}
+	return request, nil
+}
+
+var errUnknownType = errors.New("type can not be described for the plugin")
+
+func describeType(t ast.SmeType) (*PluginType, error) {
+	result := &PluginType{Optional: t.IsOptional()}
+	if v, err := t.DefaultValue(); err == nil {
+		result.DefaultValue = &v
+	}
+	switch v := t.(type) {
+	case *ast.SmeInt8:
+		result.Kind = "int8"
+	case *ast.SmeInt16:
+		result.Kind = "int16"
+	case *ast.SmeInt32:
+		result.Kind = "int32"
+	case *ast.SmeInt64:
+		result.Kind = "int64"
+	case *ast.SmeUint8:
+		result.Kind = "uint8"
+	case *ast.SmeUint16:
+		result.Kind = "uint16"
+	case *ast.SmeUint32:
+		result.Kind = "uint32"
+	case *ast.SmeUint64:
+		result.Kind = "uint64"
+	case *ast.SmeFloat:
+		result.Kind = "float"
+	case *ast.SmeDouble:
+		result.Kind = "double"
+	case *ast.SmeString:
+		result.Kind = "string"
+	case *ast.SmeChar:
+		result.Kind = "char"
+	case *ast.SmeBool:
+		result.Kind = "bool"
+	case *ast.SmeList:
+		value, err := describeType(v.ValueType())
+		if err != nil {
+			return nil, err
+		}
+		result.Kind = "list"
+		result.Value = value
+	case *ast.SmeMap:
+		key, err := describeType(v.KeyType())
+		if err != nil {
+			return nil, err
+		}
+		value, err := describeType(v.ValueType())
+		if err != nil {
+			return nil, err
+		}
+		result.Kind = "map"
+		result.Key = key
+		result.Value = value
+	case *ast.UserDefinedStruct:
+		result.Kind = "struct"
+		result.Package = v.GetImplNode().GetPackageName()
+		result.Struct = v.GetImplNode().GetName()
+	case *ast.SmeEnum:
+		result.Kind = "enum"
+		result.Package = v.GetImplNode().GetPackageName()
+		result.Enum = v.GetImplNode().GetName()
+	case *ast.SmeOneof:
+		result.Kind = "oneof"
+		result.Members = make([]PluginField, 0, len(v.GetImplNode().GetMembers()))
+		for _, m := range v.GetImplNode().GetMembers() {
+			member, err := describeType(m.GetFieldType())
+			if err != nil {
+				return nil, err
+			}
+			result.Members = append(result.Members, PluginField{Name: m.GetName(), Type: *member})
+		}
+	default:
+		return nil, errUnknownType
+	}
+	return result, nil
+}
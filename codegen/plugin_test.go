@@ -0,0 +1,126 @@
+package codegen
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Ghytro/sme/parser"
+)
+
+func TestWritePluginFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		outside bool
+	}{
+		{"mod.rs", false},
+		{"gen/foo.rs", false},
+		{"..x", false},
+		{"...txt", false},
+		{"..gen/foo.rs", false},
+		{"gen/../foo.rs", false},
+		{"", true},
+		{"..", true},
+		{"../x", true},
+		{"gen/../../x", true},
+		{filepath.ToSlash(filepath.Join(os.TempDir(), "x")), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outDir := t.TempDir()
+			err := writePluginFiles(outDir, []PluginFile{{Name: tt.name, Content: "content"}})
+			if tt.outside {
+				if !errors.Is(err, ErrPluginFileOutsideOutDir) {
+					t.Errorf("got %v, want %v", err, ErrPluginFileOutsideOutDir)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(tt.name)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != "content" {
+				t.Errorf("file has %q, want %q", content, "content")
+			}
+		})
+	}
+}
+
+// installs sme-gen-<lang> script printing the response into PATH,
+// the script saves the request it got into request.json of its directory
+func installPlugin(t *testing.T, lang string, response string) string {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not found")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncat > \"$(dirname \"$0\")/request.json\"\ncat <<'EOF'\n" + response + "\nEOF\n"
+	if err := os.WriteFile(filepath.Join(dir, PluginExecutablePrefix+lang), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(filepath.ListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func TestPluginGenerate(t *testing.T) {
+	tree, diagnostics := parser.ParseFiles([]string{"a.sme"}, map[string][]byte{
+		"a.sme": []byte("syntax 0.0.1\n\npackage p\noption go_package = \"example.com/p\"\n\nstruct S {\n    list[int32] x = @1\n}\n"),
+	})
+	if diagnostics.HasErrors() {
+		t.Fatalf("schema has errors: %v", diagnostics.Sorted())
+	}
+	tests := []struct {
+		name     string
+		response string
+		wantErr  error
+	}{
+		{"files", `{"version": 1, "files": [{"name": "p/s.rs", "content": "struct S;"}]}`, nil},
+		{"version mismatch", `{"version": 2, "files": []}`, ErrPluginVersionMismatch},
+		{"file outside", `{"version": 1, "files": [{"name": "../s.rs", "content": ""}]}`, ErrPluginFileOutsideOutDir},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pluginDir := installPlugin(t, "testlang", tt.response)
+			g, ok := Lookup("testlang")
+			if !ok {
+				t.Fatal("plugin is not found")
+			}
+			outDir := t.TempDir()
+			err := g.Generate(tree, outDir, Options{Params: map[string]string{"edition": "2021"}})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if content, err := os.ReadFile(filepath.Join(outDir, "p", "s.rs")); err != nil || string(content) != "struct S;" {
+				t.Errorf("p/s.rs has %q, %v", content, err)
+			}
+
+			var request PluginRequest
+			content, err := os.ReadFile(filepath.Join(pluginDir, "request.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(content, &request); err != nil {
+				t.Fatal(err)
+			}
+			if request.Version != PluginProtocolVersion || request.Language != "testlang" || request.Params["edition"] != "2021" {
+				t.Errorf("unexpected request %+v", request)
+			}
+			if len(request.Packages) != 1 || request.Packages[0].GoPackage == nil || *request.Packages[0].GoPackage != "example.com/p" {
+				t.Fatalf("unexpected packages %+v", request.Packages)
+			}
+			structs := request.Packages[0].Structs
+			if len(structs) != 1 || len(structs[0].Fields) != 1 || structs[0].Fields[0].Tag != 1 || structs[0].Fields[0].Type.Kind != "list" {
+				t.Errorf("unexpected structs %+v", structs)
+			}
+		})
+	}
+}
@@ -0,0 +1,758 @@
+// Package python generates python 3.7+ modules for the parsed sme schemas.
+// Every sme package becomes a module, every struct becomes a dataclass
+// with to_bytes and from_bytes methods implemented with the struct module,
+// every enum becomes enum.IntEnum, the members of every oneof become
+// the optional attributes of the dataclass set with the set_ methods.
+package python
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Ghytro/sme/ast"
+	"github.com/Ghytro/sme/codegen"
+)
+
+var ErrUnsupportedType = errors.New("type is not supported by the python generator")
+
+func init() {
+	codegen.Register("python", Generator{})
+}
+
+type Generator struct{}
+
+// Generate writes one <package name>.py module per package of the tree into outDir,
+// the "module" parameter is the python package of outDir, like "acme.schemas",
+// the modules import each other from it. Without the parameter the module
+// is imported by its python_module option
+func (Generator) Generate(tree *ast.AstTree, outDir string, opts codegen.Options) error {
+	if tree == nil {
+		return codegen.ErrNoAstTree
+	}
+	root := tree.GetRoot()
+	modules := moduleNames(root, opts.Param("module"))
+	for _, p := range root.GetPackages() {
+		src, err := generatePackage(root, p, modules)
+		if err != nil {
+			return fmt.Errorf("package %s: %w", p.GetName(), err)
+		}
+		if err := os.WriteFile(filepath.Join(outDir, p.GetName()+".py"), src, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type moduleGenerator struct {
+	body        bytes.Buffer
+	packageName string
+	// the python modules of the packages by their names
+	modules map[string]string
+	// the import statements
+	imports map[string]bool
+}
+
+// returns the python modules of the packages by their names: the module
+// named after the package inside the package given by the parameter,
+// or the python_module option of the package, or the top level module
+// named after the package
+func moduleNames(root *ast.AstModuleNode, modulePrefix string) map[string]string {
+	result := make(map[string]string)
+	for _, p := range root.GetPackages() {
+		switch {
+		case modulePrefix != "":
+			result[p.GetName()] = modulePrefix + "." + p.GetName()
+		case p.GetPythonModuleName() != nil:
+			result[p.GetName()] = *p.GetPythonModuleName()
+		default:
+			result[p.GetName()] = p.GetName()
+		}
+	}
+	return result
+}
+
+func generatePackage(root *ast.AstModuleNode, p *ast.AstPackageNode, modules map[string]string) ([]byte, error) {
+	g := &moduleGenerator{
+		packageName: p.GetName(),
+		modules:     modules,
+		imports:     make(map[string]bool),
+	}
+	// the enums go first, their values are used as the defaults of the fields
+	for _, e := range p.GetEnums() {
+		g.writeEnum(e)
+	}
+	for _, s := range p.GetStructs() {
+		if err := g.writeClass(s); err != nil {
+			return nil, fmt.Errorf("struct %s: %w", s.GetName(), err)
+		}
+	}
+
+	var file bytes.Buffer
+	fmt.Fprintf(&file, "# Code generated by sme from syntax %s. DO NOT EDIT.\n\n", root.GetSyntaxVer())
+	file.WriteString("from __future__ import annotations\n\n")
+	file.WriteString("import struct\nfrom dataclasses import dataclass, field\nfrom typing import Dict, List, Optional\n")
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	if len(imports) != 0 {
+		file.WriteString("\n")
+	}
+	for _, imp := range imports {
+		file.WriteString(imp + "\n")
+	}
+	file.WriteString("\n")
+	file.WriteString(runtimeHelpers)
+	file.Write(g.body.Bytes())
+	return append(bytes.TrimRight(file.Bytes(), "\n"), '\n'), nil
+}
+
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true,
+	"assert": true, "async": true, "await": true, "break": true, "class": true,
+	"continue": true, "def": true, "del": true, "elif": true, "else": true,
+	"except": true, "finally": true, "for": true, "from": true, "global": true,
+	"if": true, "import": true, "in": true, "is": true, "lambda": true,
+	"nonlocal": true, "not": true, "or": true, "pass": true, "raise": true,
+	"return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+func attributeName(fieldName string) string {
+	if pythonKeywords[fieldName] {
+		return fieldName + "_"
+	}
+	return fieldName
+}
+
+// the module of the other package is imported under the package name,
+// so the types are referenced the same way whatever the module is
+func (g *moduleGenerator) importPackage(packageName string) {
+	module := g.modules[packageName]
+	dot := strings.LastIndex(module, ".")
+	if dot == -1 {
+		if module == packageName {
+			g.imports["import "+packageName] = true
+		} else {
+			g.imports["import "+module+" as "+packageName] = true
+		}
+		return
+	}
+	statement := "from " + module[:dot] + " import " + module[dot+1:]
+	if module[dot+1:] != packageName {
+		statement += " as " + packageName
+	}
+	g.imports[statement] = true
+}
+
+func (g *moduleGenerator) structTypeName(n *ast.AstStructNode) string {
+	if n.GetPackageName() == g.packageName {
+		return n.GetName()
+	}
+	g.importPackage(n.GetPackageName())
+	return n.GetPackageName() + "." + n.GetName()
+}
+
+func (g *moduleGenerator) enumTypeName(n *ast.AstEnumNode) string {
+	if n.GetPackageName() == g.packageName {
+		return n.GetName()
+	}
+	g.importPackage(n.GetPackageName())
+	return n.GetPackageName() + "." + n.GetName()
+}
+
+func (g *moduleGenerator) writeEnum(e *ast.AstEnumNode) {
+	g.imports["import enum"] = true
+	fmt.Fprintf(&g.body, "class %s(enum.IntEnum):\n", e.GetName())
+	if len(e.GetValues()) == 0 {
+		g.body.WriteString("    pass\n")
+	}
+	for _, v := range e.GetValues() {
+		fmt.Fprintf(&g.body, "    %s = %s\n", attributeName(v.GetName()), v.GetNumber())
+	}
+	g.body.WriteString("\n\n")
+}
+
+// primitive types are serialized with the codecs from runtimeHelpers
+func primitiveCodecName(t ast.SmeType) string {
+	switch v := t.(type) {
+	case *ast.SmeInt8:
+		return "int8"
+	case *ast.SmeInt16:
+		return "int16"
+	case *ast.SmeInt32:
+		return "int32"
+	case *ast.SmeInt64:
+		return "int64"
+	case *ast.SmeUint8:
+		return "uint8"
+	case *ast.SmeUint16:
+		return "uint16"
+	case *ast.SmeUint32:
+		return "uint32"
+	case *ast.SmeUint64:
+		return "uint64"
+	case *ast.SmeFloat:
+		return "float"
+	case *ast.SmeDouble:
+		return "double"
+	case *ast.SmeString:
+		return "string"
+	case *ast.SmeChar:
+		return "char"
+	case *ast.SmeBool:
+		return "bool"
+	case *ast.SmeEnum:
+		return primitiveCodecName(v.GetImplNode().GetUnderlyingType())
+	}
+	return ""
+}
+
+// returns the type hint of the value, ignoring the optionality
+func (g *moduleGenerator) valueTypeHint(t ast.SmeType) (string, error) {
+	switch v := t.(type) {
+	case *ast.SmeInt8, *ast.SmeInt16, *ast.SmeInt32, *ast.SmeInt64,
+		*ast.SmeUint8, *ast.SmeUint16, *ast.SmeUint32, *ast.SmeUint64:
+		return "int", nil
+	case *ast.SmeFloat, *ast.SmeDouble:
+		return "float", nil
+	case *ast.SmeString, *ast.SmeChar:
+		return "str", nil
+	case *ast.SmeBool:
+		return "bool", nil
+	case *ast.SmeList:
+		elem, err := g.valueTypeHint(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return "List[" + elem + "]", nil
+	case *ast.SmeMap:
+		key, err := g.valueTypeHint(v.KeyType())
+		if err != nil {
+			return "", err
+		}
+		value, err := g.valueTypeHint(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return "Dict[" + key + ", " + value + "]", nil
+	case *ast.UserDefinedStruct:
+		return g.structTypeName(v.GetImplNode()), nil
+	case *ast.SmeEnum:
+		return g.enumTypeName(v.GetImplNode()), nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// returns the expression of the function with (buf, value) arguments
+// that writes the value of the type into bytearray
+func (g *moduleGenerator) writerExpr(t ast.SmeType) (string, error) {
+	switch v := t.(type) {
+	case *ast.SmeList:
+		elem, err := g.writerExpr(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return "lambda buf, value: _sme_write_list(buf, value, " + elem + ")", nil
+	case *ast.SmeMap:
+		key, err := g.writerExpr(v.KeyType())
+		if err != nil {
+			return "", err
+		}
+		value, err := g.writerExpr(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return "lambda buf, value: _sme_write_map(buf, value, " + key + ", " + value + ")", nil
+	case *ast.UserDefinedStruct:
+		return "_sme_write_struct", nil
+	}
+	if name := primitiveCodecName(t); name != "" {
+		return "_sme_write_" + name, nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// returns the expression of the function with (reader) argument
+// that reads the value of the type from _SmeReader
+func (g *moduleGenerator) readerExpr(t ast.SmeType) (string, error) {
+	switch v := t.(type) {
+	case *ast.SmeList:
+		elem, err := g.readerExpr(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return "lambda r: _sme_read_list(r, " + elem + ")", nil
+	case *ast.SmeMap:
+		key, err := g.readerExpr(v.KeyType())
+		if err != nil {
+			return "", err
+		}
+		value, err := g.readerExpr(v.ValueType())
+		if err != nil {
+			return "", err
+		}
+		return "lambda r: _sme_read_map(r, " + key + ", " + value + ")", nil
+	case *ast.UserDefinedStruct:
+		return g.structTypeName(v.GetImplNode()) + "._read", nil
+	case *ast.SmeEnum:
+		return "_sme_enum_reader(" + g.enumTypeName(v.GetImplNode()) + ", _sme_read_" + primitiveCodecName(v) + ")", nil
+	}
+	if name := primitiveCodecName(t); name != "" {
+		return "_sme_read_" + name, nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// lambdas have to be wrapped in parentheses to be called in place
+func callable(expr string) string {
+	if strings.HasPrefix(expr, "lambda") {
+		return "(" + expr + ")"
+	}
+	return expr
+}
+
+func (g *moduleGenerator) defaultExpr(t ast.SmeType) (string, error) {
+	if v, err := t.DefaultValue(); err == nil {
+		if e, ok := t.(*ast.SmeEnum); ok {
+			return g.enumTypeName(e.GetImplNode()) + "." + attributeName(v), nil
+		}
+		return defaultValueLiteral(t, v)
+	}
+	if t.IsOptional() {
+		return "None", nil
+	}
+	switch v := t.(type) {
+	case *ast.SmeInt8, *ast.SmeInt16, *ast.SmeInt32, *ast.SmeInt64,
+		*ast.SmeUint8, *ast.SmeUint16, *ast.SmeUint32, *ast.SmeUint64:
+		return "0", nil
+	case *ast.SmeFloat, *ast.SmeDouble:
+		return "0.0", nil
+	case *ast.SmeString:
+		return `""`, nil
+	case *ast.SmeChar:
+		return `"\x00"`, nil
+	case *ast.SmeBool:
+		return "False", nil
+	case *ast.SmeList:
+		return "field(default_factory=list)", nil
+	case *ast.SmeMap:
+		return "field(default_factory=dict)", nil
+	case *ast.UserDefinedStruct:
+		// the lambda defers the lookup of the class, so it may be declared later
+		return "field(default_factory=lambda: " + g.structTypeName(v.GetImplNode()) + "())", nil
+	case *ast.SmeEnum:
+		// the value is zero like in the other languages, even if the enum has no such value
+		for _, ev := range v.GetImplNode().GetValues() {
+			if ev.GetNumber() == "0" {
+				return g.enumTypeName(v.GetImplNode()) + "." + attributeName(ev.GetName()), nil
+			}
+		}
+		return "0", nil
+	}
+	return "", ErrUnsupportedType
+}
+
+func defaultValueLiteral(t ast.SmeType, v string) (string, error) {
+	switch t.(type) {
+	case *ast.SmeString, *ast.SmeChar:
+		return strconv.Quote(v), nil
+	case *ast.SmeBool:
+		if v == "true" {
+			return "True", nil
+		}
+		return "False", nil
+	case *ast.SmeList, *ast.SmeMap, *ast.UserDefinedStruct:
+		return "", ErrUnsupportedType
+	}
+	return v, nil
+}
+
+func (g *moduleGenerator) writeClass(s *ast.AstStructNode) error {
+	name := s.GetName()
+	fmt.Fprintf(&g.body, "@dataclass\nclass %s:\n", name)
+	if len(s.GetFields()) == 0 {
+		g.body.WriteString("    pass\n")
+	}
+	for _, f := range s.GetFields() {
+		t := f.GetFieldType()
+		if o, ok := t.(*ast.SmeOneof); ok {
+			if err := g.writeOneofAttributes(o.GetImplNode()); err != nil {
+				return fmt.Errorf("oneof %s: %w", f.GetName(), err)
+			}
+			continue
+		}
+		hint, err := g.valueTypeHint(t)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.GetName(), err)
+		}
+		if t.IsOptional() {
+			hint = "Optional[" + hint + "]"
+		}
+		def, err := g.defaultExpr(t)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.GetName(), err)
+		}
+		fmt.Fprintf(&g.body, "    %s: %s = %s\n", attributeName(f.GetName()), hint, def)
+	}
+
+	g.body.WriteString("\n    def to_bytes(self) -> bytes:\n")
+	g.body.WriteString("        buf = bytearray()\n        self._write(buf)\n        return bytes(buf)\n")
+
+	g.body.WriteString("\n    @classmethod\n")
+	fmt.Fprintf(&g.body, "    def from_bytes(cls, data: bytes) -> %s:\n", name)
+	g.body.WriteString("        return cls._read(_SmeReader(data))\n")
+
+	for _, f := range s.GetFields() {
+		if o, ok := f.GetFieldType().(*ast.SmeOneof); ok {
+			if err := g.writeOneofMethods(o.GetImplNode()); err != nil {
+				return fmt.Errorf("oneof %s: %w", f.GetName(), err)
+			}
+		}
+	}
+
+	g.body.WriteString("\n    def _write(self, buf: bytearray) -> None:\n")
+	if len(s.GetFields()) == 0 {
+		g.body.WriteString("        pass\n")
+	}
+	if s.IsTagged() {
+		fmt.Fprintf(&g.body, "        _sme_write_uint32(buf, %d)\n", len(s.GetFields()))
+	}
+	for _, f := range s.GetFields() {
+		if s.IsTagged() {
+			fmt.Fprintf(&g.body, "        start = _sme_begin_field(buf, %d)\n", f.GetTag())
+		}
+		if err := g.writeFieldWrite(f); err != nil {
+			return err
+		}
+		if s.IsTagged() {
+			g.body.WriteString("        _sme_end_field(buf, start)\n")
+		}
+	}
+
+	g.body.WriteString("\n    @classmethod\n")
+	fmt.Fprintf(&g.body, "    def _read(cls, r: _SmeReader) -> %s:\n", name)
+	g.body.WriteString("        obj = cls()\n")
+	if s.IsTagged() {
+		if err := g.writeTaggedRead(s); err != nil {
+			return err
+		}
+	} else {
+		for _, f := range s.GetFields() {
+			if err := g.writeFieldRead(f); err != nil {
+				return err
+			}
+		}
+	}
+	g.body.WriteString("        return obj\n\n\n")
+	return nil
+}
+
+func (g *moduleGenerator) writeFieldWrite(f *ast.AstStructFieldNode) error {
+	t := f.GetFieldType()
+	if o, ok := t.(*ast.SmeOneof); ok {
+		if err := g.writeOneofWrite(o.GetImplNode()); err != nil {
+			return fmt.Errorf("oneof %s: %w", f.GetName(), err)
+		}
+		return nil
+	}
+	writer, err := g.writerExpr(t)
+	if err != nil {
+		return fmt.Errorf("field %s: %w", f.GetName(), err)
+	}
+	value := "self." + attributeName(f.GetName())
+	if t.IsOptional() {
+		fmt.Fprintf(&g.body, "        _sme_write_optional(buf, %s, %s)\n", value, writer)
+	} else {
+		fmt.Fprintf(&g.body, "        %s(buf, %s)\n", callable(writer), value)
+	}
+	return nil
+}
+
+func (g *moduleGenerator) writeFieldRead(f *ast.AstStructFieldNode) error {
+	t := f.GetFieldType()
+	if o, ok := t.(*ast.SmeOneof); ok {
+		if err := g.writeOneofRead(o.GetImplNode()); err != nil {
+			return fmt.Errorf("oneof %s: %w", f.GetName(), err)
+		}
+		return nil
+	}
+	reader, err := g.readerExpr(t)
+	if err != nil {
+		return fmt.Errorf("field %s: %w", f.GetName(), err)
+	}
+	target := "obj." + attributeName(f.GetName())
+	if t.IsOptional() {
+		fmt.Fprintf(&g.body, "        %s = _sme_read_optional(r, %s)\n", target, reader)
+	} else {
+		fmt.Fprintf(&g.body, "        %s = %s(r)\n", target, callable(reader))
+	}
+	return nil
+}
+
+// every field of the tagged struct is read from its own reader,
+// the fields with unknown tags are skipped and the missing fields
+// keep their default values
+func (g *moduleGenerator) writeTaggedRead(s *ast.AstStructNode) error {
+	g.body.WriteString("        data = r\n")
+	g.body.WriteString("        for _ in range(_sme_read_uint32(data)):\n")
+	g.body.WriteString("            tag, r = _sme_read_field(data)\n")
+	for i, f := range s.GetFields() {
+		keyword := "elif"
+		if i == 0 {
+			keyword = "if"
+		}
+		fmt.Fprintf(&g.body, "            %s tag == %d:\n", keyword, f.GetTag())
+		// the code of the field is written with the indentation of the method
+		// body and then moved into the branch
+		start := g.body.Len()
+		if err := g.writeFieldRead(f); err != nil {
+			return err
+		}
+		code := string(g.body.Bytes()[start:])
+		g.body.Truncate(start)
+		for _, line := range strings.SplitAfter(code, "\n") {
+			if line != "" {
+				g.body.WriteString("        " + line)
+			}
+		}
+	}
+	return nil
+}
+
+// every member of the oneof is the attribute that is None unless
+// the member is set, the set_ methods keep only one of them set
+func (g *moduleGenerator) writeOneofAttributes(n *ast.AstOneofNode) error {
+	for _, m := range n.GetMembers() {
+		hint, err := g.valueTypeHint(m.GetFieldType())
+		if err != nil {
+			return fmt.Errorf("member %s: %w", m.GetName(), err)
+		}
+		fmt.Fprintf(&g.body, "    %s: Optional[%s] = None\n", attributeName(m.GetName()), hint)
+	}
+	return nil
+}
+
+func (g *moduleGenerator) writeOneofMethods(n *ast.AstOneofNode) error {
+	fmt.Fprintf(&g.body, "\n    def which_%s(self) -> Optional[str]:\n", n.GetName())
+	for _, m := range n.GetMembers() {
+		fmt.Fprintf(&g.body, "        if self.%s is not None:\n            return %q\n", attributeName(m.GetName()), m.GetName())
+	}
+	g.body.WriteString("        return None\n")
+
+	fmt.Fprintf(&g.body, "\n    def clear_%s(self) -> None:\n", n.GetName())
+	if len(n.GetMembers()) == 0 {
+		g.body.WriteString("        pass\n")
+	}
+	for _, m := range n.GetMembers() {
+		fmt.Fprintf(&g.body, "        self.%s = None\n", attributeName(m.GetName()))
+	}
+
+	for _, m := range n.GetMembers() {
+		hint, err := g.valueTypeHint(m.GetFieldType())
+		if err != nil {
+			return fmt.Errorf("member %s: %w", m.GetName(), err)
+		}
+		fmt.Fprintf(&g.body, "\n    def set_%s(self, value: %s) -> None:\n", m.GetName(), hint)
+		fmt.Fprintf(&g.body, "        self.clear_%s()\n        self.%s = value\n", n.GetName(), attributeName(m.GetName()))
+	}
+	return nil
+}
+
+// the number of the first member that is not None is written,
+// then the value of this member
+func (g *moduleGenerator) writeOneofWrite(n *ast.AstOneofNode) error {
+	for i, m := range n.GetMembers() {
+		writer, err := g.writerExpr(m.GetFieldType())
+		if err != nil {
+			return fmt.Errorf("member %s: %w", m.GetName(), err)
+		}
+		keyword := "elif"
+		if i == 0 {
+			keyword = "if"
+		}
+		value := "self." + attributeName(m.GetName())
+		fmt.Fprintf(&g.body, "        %s %s is not None:\n", keyword, value)
+		fmt.Fprintf(&g.body, "            _sme_write_uint8(buf, %d)\n            %s(buf, %s)\n", i+1, callable(writer), value)
+	}
+	if len(n.GetMembers()) == 0 {
+		g.body.WriteString("        _sme_write_uint8(buf, 0)\n")
+		return nil
+	}
+	g.body.WriteString("        else:\n            _sme_write_uint8(buf, 0)\n")
+	return nil
+}
+
+func (g *moduleGenerator) writeOneofRead(n *ast.AstOneofNode) error {
+	number := "number_" + n.GetName()
+	fmt.Fprintf(&g.body, "        %s = _sme_read_uint8(r)\n", number)
+	for i, m := range n.GetMembers() {
+		reader, err := g.readerExpr(m.GetFieldType())
+		if err != nil {
+			return fmt.Errorf("member %s: %w", m.GetName(), err)
+		}
+		keyword := "elif"
+		if i == 0 {
+			keyword = "if"
+		}
+		fmt.Fprintf(&g.body, "        %s %s == %d:\n", keyword, number, i+1)
+		fmt.Fprintf(&g.body, "            obj.%s = %s(r)\n", attributeName(m.GetName()), callable(reader))
+	}
+	keyword := "elif"
+	if len(n.GetMembers()) == 0 {
+		keyword = "if"
+	}
+	fmt.Fprintf(&g.body, "        %s %s != 0:\n", keyword, number)
+	fmt.Fprintf(&g.body, "            raise SmeParseError(\"unknown member of oneof %s\")\n", n.GetName())
+	return nil
+}
+
+// helpers are written into every generated module, so the generated
+// code does not depend on any runtime library.
+// All the numbers are written in little endian byte order,
+// strings, lists and maps are prefixed with uint32 length,
+// optional values are prefixed with the byte telling if the value is present.
+const runtimeHelpers = `class SmeParseError(Exception):
+    pass
+
+
+class _SmeReader:
+    def __init__(self, data: bytes) -> None:
+        self.data = data
+        self.offset = 0
+
+    def unpack(self, codec: struct.Struct):
+        if self.offset + codec.size > len(self.data):
+            raise SmeParseError("unexpected end of data")
+        value = codec.unpack_from(self.data, self.offset)[0]
+        self.offset += codec.size
+        return value
+
+    def read_bytes(self, size: int) -> bytes:
+        if self.offset + size > len(self.data):
+            raise SmeParseError("unexpected end of data")
+        value = bytes(self.data[self.offset:self.offset + size])
+        self.offset += size
+        return value
+
+
+def _sme_codec(fmt: str):
+    codec = struct.Struct(fmt)
+
+    def write(buf: bytearray, value) -> None:
+        buf += codec.pack(value)
+
+    def read(r: _SmeReader):
+        return r.unpack(codec)
+
+    return write, read
+
+
+_sme_write_int8, _sme_read_int8 = _sme_codec("<b")
+_sme_write_int16, _sme_read_int16 = _sme_codec("<h")
+_sme_write_int32, _sme_read_int32 = _sme_codec("<i")
+_sme_write_int64, _sme_read_int64 = _sme_codec("<q")
+_sme_write_uint8, _sme_read_uint8 = _sme_codec("<B")
+_sme_write_uint16, _sme_read_uint16 = _sme_codec("<H")
+_sme_write_uint32, _sme_read_uint32 = _sme_codec("<I")
+_sme_write_uint64, _sme_read_uint64 = _sme_codec("<Q")
+_sme_write_float, _sme_read_float = _sme_codec("<f")
+_sme_write_double, _sme_read_double = _sme_codec("<d")
+_sme_write_bool, _sme_read_bool = _sme_codec("<?")
+
+
+def _sme_write_char(buf: bytearray, value: str) -> None:
+    buf += value.encode("latin-1")[:1]
+
+
+def _sme_read_char(r: _SmeReader) -> str:
+    return r.read_bytes(1).decode("latin-1")
+
+
+def _sme_write_string(buf: bytearray, value: str) -> None:
+    encoded = value.encode("utf-8")
+    _sme_write_uint32(buf, len(encoded))
+    buf += encoded
+
+
+def _sme_read_string(r: _SmeReader) -> str:
+    return r.read_bytes(_sme_read_uint32(r)).decode("utf-8")
+
+
+def _sme_write_struct(buf: bytearray, value) -> None:
+    value._write(buf)
+
+
+def _sme_enum_reader(enum_class, read_value):
+    # the numbers unknown to the enum are kept as int
+    def read(r: _SmeReader):
+        value = read_value(r)
+        try:
+            return enum_class(value)
+        except ValueError:
+            return value
+
+    return read
+
+
+def _sme_write_optional(buf: bytearray, value, write_value) -> None:
+    _sme_write_bool(buf, value is not None)
+    if value is not None:
+        write_value(buf, value)
+
+
+def _sme_read_optional(r: _SmeReader, read_value):
+    if _sme_read_bool(r):
+        return read_value(r)
+    return None
+
+
+def _sme_write_list(buf: bytearray, value, write_value) -> None:
+    _sme_write_uint32(buf, len(value))
+    for x in value:
+        write_value(buf, x)
+
+
+def _sme_read_list(r: _SmeReader, read_value) -> list:
+    return [read_value(r) for _ in range(_sme_read_uint32(r))]
+
+
+def _sme_write_map(buf: bytearray, value, write_key, write_value) -> None:
+    _sme_write_uint32(buf, len(value))
+    for k, v in value.items():
+        write_key(buf, k)
+        write_value(buf, v)
+
+
+def _sme_read_map(r: _SmeReader, read_key, read_value) -> dict:
+    result = {}
+    for _ in range(_sme_read_uint32(r)):
+        k = read_key(r)
+        result[k] = read_value(r)
+    return result
+
+
+# the field of the tagged struct is prefixed with its tag and length,
+# the length is patched by _sme_end_field when the value is written
+def _sme_begin_field(buf: bytearray, tag: int) -> int:
+    _sme_write_uint16(buf, tag)
+    _sme_write_uint32(buf, 0)
+    return len(buf)
+
+
+def _sme_end_field(buf: bytearray, start: int) -> None:
+    buf[start - 4:start] = struct.pack("<I", len(buf) - start)
+
+
+def _sme_read_field(r: _SmeReader):
+    tag = _sme_read_uint16(r)
+    return tag, _SmeReader(r.read_bytes(_sme_read_uint32(r)))
+
+
+`
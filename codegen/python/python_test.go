@@ -0,0 +1,115 @@
+package python
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Ghytro/sme/codegen"
+	"github.com/Ghytro/sme/parser"
+)
+
+// generates the schema, runs the program next to the generated modules
+// with the arguments and returns its output, the program exits with
+// non zero code if the values do not round-trip
+func runRoundTrip(t *testing.T, schema map[string]string, program string, args ...string) string {
+	t.Helper()
+	interpreter, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not found")
+	}
+	schemaDir, outDir := t.TempDir(), t.TempDir()
+	for name, content := range schema {
+		if err := os.WriteFile(filepath.Join(schemaDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree, diagnostics := parser.Parse([]string{schemaDir}, parser.DefaultExtensions, 1)
+	if diagnostics.HasErrors() {
+		t.Fatalf("schema has errors: %v", diagnostics.Sorted())
+	}
+	if err := (Generator{}).Generate(tree, outDir, codegen.Options{}); err != nil {
+		t.Fatalf("Generate: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "main.py"), []byte(program), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(interpreter, append([]string{"main.py"}, args...)...)
+	cmd.Dir = outDir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("run: %s\n%s", err, output)
+	}
+	return string(output)
+}
+
+// returns the schema shared by the round-trip tests of all the backends
+// and the hex of the bytes of the value every backend encodes
+func loadRoundTripSchema(t *testing.T) (map[string]string, string) {
+	t.Helper()
+	const dir = "../testdata/roundtrip"
+	schema := make(map[string]string)
+	fileNames, err := filepath.Glob(filepath.Join(dir, "*.sme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fileName := range fileNames {
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schema[filepath.Base(fileName)] = string(content)
+	}
+	golden, err := os.ReadFile(filepath.Join(dir, "rt.hex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema, strings.TrimSpace(string(golden))
+}
+
+func TestRoundTrip(t *testing.T) {
+	schema, golden := loadRoundTripSchema(t)
+	output := runRoundTrip(t, schema, `import sys
+
+from rt import All, Color, Empty, Point, Tagged
+
+value = All(
+    i8=-5,
+    u16=65535,
+    i64=-1234567890123,
+    u64=2**64 - 1,
+    f=1.5,
+    d=-2.25,
+    b=True,
+    c="z",
+    s="héllo",
+    maybe=7,
+    origin=Point(1, 2),
+    points=[Point(3, 4), Point(5, 6)],
+    empties=[Empty(), Empty(), Empty()],
+    counts={"a": 1},
+    color=Color.BLUE,
+    colors=[Color.RED, Color.GREEN],
+    tagged=Tagged(id=42, login="root", scores=[1, 2, 3]),
+)
+value.set_location(Point(7, 8))
+value.tagged.set_company("acme")
+
+golden = bytes.fromhex(sys.argv[1])
+decoded = All.from_bytes(golden)
+if decoded != value:
+    sys.exit("decoded %r, want %r" % (decoded, value))
+if decoded.which_contact() != "location" or decoded.tagged.which_owner() != "company":
+    sys.exit("wrong oneof members are set")
+if decoded.missing is not None or not isinstance(decoded.color, Color):
+    sys.exit("decoded %r" % decoded)
+if decoded.to_bytes() != golden:
+    sys.exit("decoded value is encoded differently")
+sys.stdout.write(value.to_bytes().hex())
+`, golden)
+	if output != golden {
+		t.Errorf("encoded value differs from rt.hex:\n%s\nwant:\n%s", output, golden)
+	}
+}
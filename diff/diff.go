@@ -0,0 +1,204 @@
+// Package diff finds the changes between two versions of the schema
+// that break the readers of the messages written with the other version.
+package diff
+
+import (
+	"fmt"
+
+	"github.com/Ghytro/sme/ast"
+	"github.com/Ghytro/sme/parser"
+)
+
+// Compare reports the breaking changes from oldSchema to newSchema, the removed
+// declarations are reported at their position in the old schema, the changed
+// ones at their position in the new schema
+func Compare(oldSchema, newSchema *Schema, diagnostics *parser.Diagnostics) {
+	for _, oldPackage := range oldSchema.packages {
+		newPackage := newSchema.findPackage(oldPackage.name)
+		if newPackage == nil {
+			if renamed := findRenamedPackage(oldPackage, oldSchema, newSchema); renamed != nil {
+				report(diagnostics, parser.CodePackageRenamed, ast.Position{File: renamed.file},
+					"package %s is renamed to %s", oldPackage.name, renamed.name)
+			} else {
+				report(diagnostics, parser.CodePackageRemoved, ast.Position{File: oldPackage.file},
+					"package %s is removed", oldPackage.name)
+			}
+			continue
+		}
+		for _, oldStruct := range oldPackage.structs {
+			newStruct := newPackage.findStruct(oldStruct.name)
+			if newStruct == nil {
+				report(diagnostics, parser.CodeStructRemoved, oldStruct.position,
+					"struct %s.%s is removed", oldPackage.name, oldStruct.name)
+				continue
+			}
+			compareStructs(oldStruct, newStruct, diagnostics)
+		}
+		for _, oldEnum := range oldPackage.enums {
+			newEnum := newPackage.findEnum(oldEnum.name)
+			if newEnum == nil {
+				report(diagnostics, parser.CodeEnumRemoved, oldEnum.position,
+					"enum %s.%s is removed", oldPackage.name, oldEnum.name)
+				continue
+			}
+			compareEnums(oldPackage.name, oldEnum, newEnum, diagnostics)
+		}
+	}
+}
+
+// the package is renamed if the new package that was not in the old schema
+// declares all the structs and the enums of the removed one
+func findRenamedPackage(oldPackage *packageInfo, oldSchema, newSchema *Schema) *packageInfo {
+	if len(oldPackage.structs) == 0 && len(oldPackage.enums) == 0 {
+		return nil
+	}
+	for _, p := range newSchema.packages {
+		if oldSchema.findPackage(p.name) != nil {
+			continue
+		}
+		renamed := true
+		for _, s := range oldPackage.structs {
+			if p.findStruct(s.name) == nil {
+				renamed = false
+				break
+			}
+		}
+		for _, e := range oldPackage.enums {
+			if p.findEnum(e.name) == nil {
+				renamed = false
+				break
+			}
+		}
+		if renamed {
+			return p
+		}
+	}
+	return nil
+}
+
+// the enum is written as its underlying type, so the readers break if
+// the type changes or if the number they know means the other value now
+func compareEnums(packageName string, oldEnum, newEnum *enumInfo, diagnostics *parser.Diagnostics) {
+	if oldEnum.underlyingType != newEnum.underlyingType {
+		report(diagnostics, parser.CodeEnumTypeChanged, newEnum.position,
+			"underlying type of enum %s.%s is changed from %s to %s", packageName, newEnum.name, oldEnum.underlyingType, newEnum.underlyingType)
+	}
+	for _, oldValue := range oldEnum.values {
+		newValue := newEnum.findValue(oldValue.name)
+		if newValue == nil {
+			report(diagnostics, parser.CodeEnumValueRemoved, oldValue.position,
+				"value %s of enum %s.%s is removed", oldValue.name, packageName, oldEnum.name)
+			continue
+		}
+		if oldValue.number != newValue.number {
+			report(diagnostics, parser.CodeEnumValueRenumbered, newValue.position,
+				"number of value %s of enum %s.%s is changed from %s to %s", newValue.name, packageName, newEnum.name, oldValue.number, newValue.number)
+		}
+	}
+}
+
+func compareStructs(oldStruct, newStruct *structInfo, diagnostics *parser.Diagnostics) {
+	if oldStruct.tagged != newStruct.tagged {
+		layout := "positional"
+		if newStruct.tagged {
+			layout = "tagged"
+		}
+		report(diagnostics, parser.CodeStructLayoutChanged, newStruct.position,
+			"struct %s is %s now", newStruct.name, layout)
+		return
+	}
+	if oldStruct.tagged {
+		compareTaggedFields(oldStruct, newStruct, diagnostics)
+		return
+	}
+	comparePositionalFields(oldStruct.fields, newStruct.fields, "field", diagnostics)
+	// the positional struct has no length on the wire, so the new field
+	// shifts the bytes of everything written after the struct
+	for _, newField := range newStruct.fields {
+		if oldStruct.findField(newField.name) == nil {
+			report(diagnostics, parser.CodeFieldAdded, newField.position,
+				"field %s is added to positional struct %s", newField.name, newStruct.name)
+		}
+	}
+}
+
+// the fields of the tagged struct are matched by the tags, the removed field is
+// skipped by the readers, but its tag must be reserved to not be reused
+func compareTaggedFields(oldStruct, newStruct *structInfo, diagnostics *parser.Diagnostics) {
+	for _, oldField := range oldStruct.fields {
+		var newField *fieldInfo
+		for _, f := range newStruct.fields {
+			if f.tag == oldField.tag {
+				newField = f
+				break
+			}
+		}
+		if newField == nil {
+			if !newStruct.isTagReserved(oldField.tag) {
+				report(diagnostics, parser.CodeFieldRemoved, oldField.position,
+					"field %s with tag @%d is removed and its tag is not reserved", oldField.name, oldField.tag)
+			}
+			continue
+		}
+		compareFields(oldField, newField, "field", diagnostics)
+	}
+}
+
+// the fields of the positional struct and the members of the oneof are
+// matched by the names, they are written in the order of declaration,
+// so moving them breaks the readers too
+func comparePositionalFields(oldFields, newFields []*fieldInfo, what string, diagnostics *parser.Diagnostics) {
+	for i, oldField := range oldFields {
+		j := -1
+		for k, f := range newFields {
+			if f.name == oldField.name {
+				j = k
+				break
+			}
+		}
+		if j == -1 {
+			report(diagnostics, parser.CodeFieldRemoved, oldField.position,
+				"%s %s is removed", what, oldField.name)
+			continue
+		}
+		newField := newFields[j]
+		if i != j {
+			report(diagnostics, parser.CodeFieldMoved, newField.position,
+				"%s %s is moved from position %d to %d", what, oldField.name, i+1, j+1)
+		}
+		compareFields(oldField, newField, what, diagnostics)
+	}
+}
+
+func compareFields(oldField, newField *fieldInfo, what string, diagnostics *parser.Diagnostics) {
+	switch {
+	case oldField.optional != newField.optional:
+		change := "is optional now"
+		if !newField.optional {
+			change = "is not optional anymore"
+		}
+		report(diagnostics, parser.CodeFieldOptionalityChanged, newField.position,
+			"%s %s %s", what, newField.name, change)
+	case oldField.typeName == "oneof" && newField.typeName == "oneof":
+		// the id of the oneof depends on its name, the members tell about its layout
+		comparePositionalFields(oldField.members, newField.members, "oneof member", diagnostics)
+	case oldField.typeId != newField.typeId && oldField.typeName == newField.typeName:
+		// the enum with the changed underlying type keeps its name
+		report(diagnostics, parser.CodeFieldTypeChanged, newField.position,
+			"layout of type %s of %s %s is changed", newField.typeName, what, newField.name)
+	case oldField.typeId != newField.typeId:
+		report(diagnostics, parser.CodeFieldTypeChanged, newField.position,
+			"type of %s %s is changed from %s to %s", what, newField.name, oldField.typeName, newField.typeName)
+	}
+}
+
+func report(diagnostics *parser.Diagnostics, code string, pos ast.Position, format string, args ...interface{}) {
+	diagnostics.Add(parser.Diagnostic{
+		Severity: parser.SeverityError,
+		Code:     code,
+		File:     pos.File,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
@@ -0,0 +1,172 @@
+package diff
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Ghytro/sme/parser"
+)
+
+func loadSchema(t *testing.T, files map[string]string) *Schema {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	schema, diagnostics := Load(dir, 1)
+	if diagnostics.HasErrors() {
+		t.Fatalf("schema has errors: %v", diagnostics.Sorted())
+	}
+	return schema
+}
+
+func TestCompare(t *testing.T) {
+	const header = "syntax 0.0.1\n\npackage p\n\n"
+	tests := []struct {
+		name     string
+		old, new map[string]string
+		want     []string
+	}{
+		{
+			name: "no changes",
+			old:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n}\n"},
+		},
+		{
+			name: "package removed",
+			old: map[string]string{
+				"a.sme": header + "struct S {\n    int32 x\n}\n",
+				"b.sme": "syntax 0.0.1\n\npackage q\n\nstruct T {\n    int32 y\n}\n",
+			},
+			new:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n}\n"},
+			want: []string{parser.CodePackageRemoved},
+		},
+		{
+			name: "package renamed",
+			old:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n}\n"},
+			new:  map[string]string{"a.sme": "syntax 0.0.1\n\npackage q\n\nstruct S {\n    int32 x\n}\n"},
+			want: []string{parser.CodePackageRenamed},
+		},
+		{
+			name: "struct removed",
+			old:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n}\n\nstruct T {\n    int32 y\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n}\n"},
+			want: []string{parser.CodeStructRemoved},
+		},
+		{
+			name: "struct layout changed",
+			old:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    int32 x = @1\n}\n"},
+			want: []string{parser.CodeStructLayoutChanged},
+		},
+		{
+			name: "positional field removed",
+			old:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n    int32 y\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n}\n"},
+			want: []string{parser.CodeFieldRemoved},
+		},
+		{
+			name: "tagged field removed without reserving its tag",
+			old:  map[string]string{"a.sme": header + "struct S {\n    int32 x = @1\n    int32 y = @2\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    int32 x = @1\n}\n"},
+			want: []string{parser.CodeFieldRemoved},
+		},
+		{
+			name: "tagged field removed with its tag reserved",
+			old:  map[string]string{"a.sme": header + "struct S {\n    int32 x = @1\n    int32 y = @2\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    reserved 2;\n    int32 x = @1\n}\n"},
+		},
+		{
+			name: "tagged fields reordered and renamed",
+			old:  map[string]string{"a.sme": header + "struct S {\n    int32 x = @1\n    string y = @2\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    string z = @2\n    int32 x = @1\n}\n"},
+		},
+		{
+			name: "field appended to positional struct embedded in other struct",
+			old:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n}\n\nstruct T {\n    S s\n    int32 y\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n    int32 z\n}\n\nstruct T {\n    S s\n    int32 y\n}\n"},
+			want: []string{parser.CodeFieldAdded},
+		},
+		{
+			name: "field added to tagged struct",
+			old:  map[string]string{"a.sme": header + "struct S {\n    int32 x = @1\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    int32 x = @1\n    int32 z = @2\n}\n"},
+		},
+		{
+			name: "positional field moved",
+			old:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n    int32 y\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    int32 y\n    int32 x\n}\n"},
+			want: []string{parser.CodeFieldMoved, parser.CodeFieldMoved},
+		},
+		{
+			name: "field type changed",
+			old:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    int64 x\n}\n"},
+			want: []string{parser.CodeFieldTypeChanged},
+		},
+		{
+			name: "field optionality changed",
+			old:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    optional int32 x\n}\n"},
+			want: []string{parser.CodeFieldOptionalityChanged},
+		},
+		{
+			name: "oneof member moved",
+			old:  map[string]string{"a.sme": header + "struct S {\n    oneof o {\n        int32 a\n        string b\n    }\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    oneof o {\n        string b\n        int32 a\n    }\n}\n"},
+			want: []string{parser.CodeFieldMoved, parser.CodeFieldMoved},
+		},
+		{
+			name: "enum removed",
+			old:  map[string]string{"a.sme": header + "enum E {\n    A\n}\n"},
+			new:  map[string]string{"a.sme": header + "struct S {\n    int32 x\n}\n"},
+			want: []string{parser.CodeEnumRemoved},
+		},
+		{
+			name: "enum value added",
+			old:  map[string]string{"a.sme": header + "enum E {\n    A\n}\n"},
+			new:  map[string]string{"a.sme": header + "enum E {\n    A,\n    B\n}\n"},
+		},
+		{
+			name: "enum value removed",
+			old:  map[string]string{"a.sme": header + "enum E {\n    A,\n    B\n}\n"},
+			new:  map[string]string{"a.sme": header + "enum E {\n    A\n}\n"},
+			want: []string{parser.CodeEnumValueRemoved},
+		},
+		{
+			name: "enum value renumbered",
+			old:  map[string]string{"a.sme": header + "enum E {\n    A,\n    B\n}\n"},
+			new:  map[string]string{"a.sme": header + "enum E {\n    A,\n    B = 5\n}\n"},
+			want: []string{parser.CodeEnumValueRenumbered},
+		},
+		{
+			name: "enum underlying type changed",
+			old:  map[string]string{"a.sme": header + "enum E : uint8 {\n    A\n}\n\nstruct S {\n    E e\n}\n"},
+			new:  map[string]string{"a.sme": header + "enum E : int64 {\n    A\n}\n\nstruct S {\n    E e\n}\n"},
+			want: []string{parser.CodeEnumTypeChanged, parser.CodeFieldTypeChanged},
+		},
+		{
+			name: "enum type changed and value removed",
+			old:  map[string]string{"a.sme": header + "enum E : uint8 {\n    A,\n    B\n}\n"},
+			new:  map[string]string{"a.sme": header + "enum E : int64 {\n    B = 1\n}\n"},
+			want: []string{parser.CodeEnumValueRemoved, parser.CodeEnumTypeChanged},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnostics := new(parser.Diagnostics)
+			Compare(loadSchema(t, tt.old), loadSchema(t, tt.new), diagnostics)
+			var got []string
+			for _, d := range diagnostics.Sorted() {
+				got = append(got, d.Code)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v: %v", got, tt.want, diagnostics.Sorted())
+			}
+		})
+	}
+}
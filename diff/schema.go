@@ -0,0 +1,187 @@
+package diff
+
+import (
+	"github.com/Ghytro/sme/ast"
+	"github.com/Ghytro/sme/parser"
+)
+
+// Schema is the version of the schema reduced to what matters on the wire
+type Schema struct {
+	packages []*packageInfo
+}
+
+type packageInfo struct {
+	name string
+	// the first file declaring the package
+	file    string
+	structs []*structInfo
+	enums   []*enumInfo
+}
+
+type structInfo struct {
+	name         string
+	position     ast.Position
+	tagged       bool
+	reservedTags [][2]int
+	fields       []*fieldInfo
+}
+
+type enumInfo struct {
+	name           string
+	position       ast.Position
+	underlyingType string
+	values         []*enumValueInfo
+}
+
+type enumValueInfo struct {
+	name     string
+	number   string
+	position ast.Position
+}
+
+type fieldInfo struct {
+	name     string
+	tag      int
+	typeId   uint32
+	typeName string
+	optional bool
+	position ast.Position
+	// the members of the oneof
+	members []*fieldInfo
+}
+
+// Load parses the schema in smeFilesDir with up to jobs files at a time,
+// the schema is nil if the files have errors
+func Load(smeFilesDir string, jobs int) (*Schema, *parser.Diagnostics) {
+	tree, diagnostics := parser.Parse([]string{smeFilesDir}, parser.DefaultExtensions, jobs)
+	if diagnostics.HasErrors() {
+		return nil, diagnostics
+	}
+	schema := new(Schema)
+	if tree == nil {
+		return schema, diagnostics
+	}
+	files := make(map[string]string)
+	for _, f := range tree.GetRoot().GetFiles() {
+		if _, ok := files[f.GetPackageName()]; !ok {
+			files[f.GetPackageName()] = f.GetName()
+		}
+	}
+	for _, p := range tree.GetRoot().GetPackages() {
+		pkg := &packageInfo{name: p.GetName(), file: files[p.GetName()]}
+		for _, s := range p.GetStructs() {
+			pkg.structs = append(pkg.structs, newStructInfo(s))
+		}
+		for _, e := range p.GetEnums() {
+			pkg.enums = append(pkg.enums, newEnumInfo(e))
+		}
+		schema.packages = append(schema.packages, pkg)
+	}
+	return schema, diagnostics
+}
+
+func newStructInfo(s *ast.AstStructNode) *structInfo {
+	result := &structInfo{
+		name:     s.GetName(),
+		position: s.GetPosition(),
+		tagged:   s.IsTagged(),
+	}
+	for _, r := range s.GetReserved() {
+		if !r.IsName() {
+			from, to := r.GetRange()
+			result.reservedTags = append(result.reservedTags, [2]int{from, to})
+		}
+	}
+	for _, f := range s.GetFields() {
+		result.fields = append(result.fields, newFieldInfo(f))
+	}
+	return result
+}
+
+func newEnumInfo(e *ast.AstEnumNode) *enumInfo {
+	result := &enumInfo{
+		name:           e.GetName(),
+		position:       e.GetPosition(),
+		underlyingType: ast.TypeName(e.GetUnderlyingType()),
+	}
+	for _, v := range e.GetValues() {
+		result.values = append(result.values, &enumValueInfo{
+			name:     v.GetName(),
+			number:   v.GetNumber(),
+			position: v.GetPosition(),
+		})
+	}
+	return result
+}
+
+func newFieldInfo(f *ast.AstStructFieldNode) *fieldInfo {
+	t := f.GetFieldType()
+	result := &fieldInfo{
+		name:     f.GetName(),
+		tag:      f.GetTag(),
+		typeId:   t.Id(),
+		typeName: ast.TypeName(t),
+		optional: t.IsOptional(),
+		position: f.GetPosition(),
+	}
+	if o, ok := t.(*ast.SmeOneof); ok {
+		for _, m := range o.GetImplNode().GetMembers() {
+			result.members = append(result.members, newFieldInfo(m))
+		}
+	}
+	return result
+}
+
+func (p *packageInfo) findStruct(name string) *structInfo {
+	for _, s := range p.structs {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func (p *packageInfo) findEnum(name string) *enumInfo {
+	for _, e := range p.enums {
+		if e.name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+func (e *enumInfo) findValue(name string) *enumValueInfo {
+	for _, v := range e.values {
+		if v.name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+func (s *structInfo) findField(name string) *fieldInfo {
+	for _, f := range s.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func (s *structInfo) isTagReserved(tag int) bool {
+	for _, r := range s.reservedTags {
+		if r[0] <= tag && tag <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) findPackage(name string) *packageInfo {
+	for _, p := range s.packages {
+		if p.name == name {
+			return p
+		}
+	}
+	return nil
+}
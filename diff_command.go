@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"runtime"
+
+	"github.com/Ghytro/sme/diff"
+	"github.com/Ghytro/sme/helpers"
+	"github.com/Ghytro/sme/parser"
+)
+
+// runDiff compares two versions of the schema and fails if the new one
+// breaks the readers of the old one, so it can gate the merges
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	oldDir := flags.String("old", "", "Directory with the old version of sme files")
+	newDir := flags.String("new", "", "Directory with the new version of sme files")
+	diagnosticsFormat := flags.String("diagnostics-format", parser.DiagnosticsFormatText, "Format of the reported changes: text, json or sarif")
+	jobs := flags.Int("j", runtime.NumCPU(), "Number of files parsed at a time")
+	flags.Parse(args)
+
+	helpers.HandleSchemaDirArgumentErrors("-old", oldDir)
+	helpers.HandleSchemaDirArgumentErrors("-new", newDir)
+	helpers.HandleDiagnosticsFormatArgumentErrors(diagnosticsFormat, parser.DiagnosticsFormats)
+	helpers.HandleJobsArgumentErrors(jobs)
+
+	// the changes are reported only if both versions are correct
+	oldSchema, diagnostics := diff.Load(*oldDir, *jobs)
+	if !diagnostics.HasErrors() {
+		var newSchema *diff.Schema
+		newSchema, diagnostics = diff.Load(*newDir, *jobs)
+		if !diagnostics.HasErrors() {
+			diff.Compare(oldSchema, newSchema, diagnostics)
+		}
+	}
+	exitWithDiagnostics(diagnostics, *diagnosticsFormat)
+}
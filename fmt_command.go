@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Ghytro/sme/format"
+	"github.com/Ghytro/sme/helpers"
+	"github.com/Ghytro/sme/parser"
+)
+
+// runFmt prints the sme files in the canonical layout, the directories are
+// walked for the sme files by parser.Discover, the files with errors are left as they are
+func runFmt(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "Write the result to the files instead of stdout")
+	check := flags.Bool("check", false, "List the files that are not formatted and fail if there are any")
+	extensions := flags.String("ext", strings.Join(parser.DefaultExtensions, ","), "Comma separated extensions of the sme files taken from the directories")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: sme fmt [-w] [-check] [-ext list] <files or directories>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		helpers.PrintError("no files or directories specified to format")
+	}
+
+	diagnostics := new(parser.Diagnostics)
+	unformatted := false
+	for _, fileName := range parser.Discover(flags.Args(), helpers.SplitExtensions(*extensions), diagnostics) {
+		src, err := os.ReadFile(fileName)
+		if err != nil {
+			diagnostics.AddError(fileName, err)
+			continue
+		}
+		formatted, err := format.Source(fileName, src)
+		if err != nil {
+			diagnostics.AddError(fileName, err)
+			continue
+		}
+		changed := !bytes.Equal(src, formatted)
+		if *check && changed {
+			unformatted = true
+			fmt.Println(fileName)
+		}
+		switch {
+		case *write && changed:
+			info, err := os.Stat(fileName)
+			if err == nil {
+				err = os.WriteFile(fileName, formatted, info.Mode().Perm())
+			}
+			if err != nil {
+				diagnostics.AddError(fileName, err)
+			}
+		case !*write && !*check:
+			os.Stdout.Write(formatted)
+		}
+	}
+	exitWithDiagnostics(diagnostics, parser.DiagnosticsFormatText)
+	if unformatted {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,301 @@
+// Package format prints the sme files in the canonical layout: one field
+// per line, four spaces of indentation, no semicolons, the quoted default
+// values written with the escapes known to the lexer and the comments kept
+// at the declarations they were written at.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Ghytro/sme/ast"
+	"github.com/Ghytro/sme/parser"
+)
+
+// Source returns the canonical form of the sme file. The file is parsed with
+// parser.ParseFileContent into the new tree, the returned error is
+// parser.ErrorList if the file has errors
+func Source(fileName string, src []byte) ([]byte, error) {
+	tree := ast.NewAstTree()
+	if err := parser.ParseFileContent(tree, fileName, bytes.NewReader(src)); err != nil {
+		return nil, err
+	}
+	fileNode, err := tree.GetFileNode(fileName)
+	if err != nil {
+		return nil, err
+	}
+	p := newPrinter(string(src), fileNode.GetPackageName())
+	p.printHeader(tree.GetRoot().GetSyntaxVer(), fileNode)
+	for _, decl := range declarations(tree, fileNode.GetPackageName()) {
+		p.unit++
+		switch d := decl.(type) {
+		case *ast.AstStructNode:
+			p.printStruct(d)
+		case *ast.AstEnumNode:
+			p.printEnum(d)
+		}
+	}
+	return p.layout(parser.Comments(string(src))), nil
+}
+
+type declaration interface {
+	GetPosition() ast.Position
+}
+
+// returns the structs and the enums of the package in the order of declaration,
+// the structs only referenced by the field types are skipped
+func declarations(tree *ast.AstTree, packageName string) []declaration {
+	var result []declaration
+	for _, p := range tree.GetRoot().GetPackages() {
+		if p.GetName() != packageName {
+			continue
+		}
+		for _, s := range p.GetStructs() {
+			if s.IsDeclared() {
+				result = append(result, s)
+			}
+		}
+		for _, e := range p.GetEnums() {
+			result = append(result, e)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return before(result[i].GetPosition(), result[j].GetPosition())
+	})
+	return result
+}
+
+func before(a, b ast.Position) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+// the syntax version is the first token of the file, so its line is
+// the first line that is neither empty nor the comment
+func (p *printer) printHeader(syntaxVer string, fileNode *ast.AstFileNode) {
+	syntaxLine := 1
+	for i, l := range p.srcLines {
+		if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, "//") {
+			syntaxLine = i + 1
+			break
+		}
+	}
+	p.add(0, syntaxLine, "syntax "+syntaxVer)
+	if imports := fileNode.GetImports(); len(imports) != 0 {
+		p.unit++
+		for _, imp := range imports {
+			p.add(0, imp.GetPosition().Line, "import "+quote(imp.GetPath(), '"'))
+		}
+	}
+	p.unit++
+	p.add(0, fileNode.GetPackagePosition().Line, "package "+fileNode.GetPackageName())
+	if options := fileNode.GetOptions(); len(options) != 0 {
+		p.unit++
+		for _, o := range options {
+			p.add(0, o.GetPosition().Line, "option "+o.GetName()+" = "+quote(o.GetValue(), '"'))
+		}
+	}
+}
+
+func (p *printer) printStruct(s *ast.AstStructNode) {
+	p.add(0, s.GetPosition().Line, "struct "+s.GetName()+" {")
+	fields := s.GetFields()
+	reserved := s.GetReserved()
+	for len(fields) != 0 || len(reserved) != 0 {
+		if len(reserved) == 0 || len(fields) != 0 && before(fields[0].GetPosition(), reserved[0].GetPosition()) {
+			p.printField(1, fields[0])
+			fields = fields[1:]
+			continue
+		}
+		// the items of the single reserved statement are on the same line
+		n := 1
+		for n < len(reserved) && reserved[n].GetPosition().Line == reserved[0].GetPosition().Line {
+			n++
+		}
+		p.printReserved(1, reserved[:n])
+		reserved = reserved[n:]
+	}
+	p.addClosing(0, s.GetEndPosition().Line)
+}
+
+func (p *printer) printField(indent int, f *ast.AstStructFieldNode) {
+	line := f.GetPosition().Line
+	var b strings.Builder
+	if oneof, ok := f.GetFieldType().(*ast.SmeOneof); ok {
+		b.WriteString("oneof " + f.GetName())
+		writeTag(&b, f.GetTag())
+		b.WriteString(" {")
+		p.add(indent, line, b.String())
+		for _, m := range oneof.GetImplNode().GetMembers() {
+			p.add(indent+1, m.GetPosition().Line, p.typeName(m.GetFieldType())+" "+m.GetName())
+		}
+		p.addClosing(indent, oneof.GetImplNode().GetEndPosition().Line)
+		return
+	}
+	t := f.GetFieldType()
+	if t.IsOptional() {
+		b.WriteString("optional ")
+	}
+	b.WriteString(p.typeName(t) + " " + f.GetName())
+	writeTag(&b, f.GetTag())
+	if v, err := t.DefaultValue(); err == nil {
+		b.WriteString(" = " + defaultValue(t, v))
+	}
+	p.add(indent, line, b.String())
+}
+
+func writeTag(b *strings.Builder, tag int) {
+	if tag != 0 {
+		fmt.Fprintf(b, " = @%d", tag)
+	}
+}
+
+func (p *printer) printReserved(indent int, reserved []*ast.AstReservedNode) {
+	items := make([]string, 0, len(reserved))
+	for _, r := range reserved {
+		from, to := r.GetRange()
+		switch {
+		case r.IsName():
+			items = append(items, quote(r.GetName(), '"'))
+		case from == to:
+			items = append(items, strconv.Itoa(from))
+		default:
+			items = append(items, fmt.Sprintf("%d to %d", from, to))
+		}
+	}
+	p.add(indent, reserved[0].GetPosition().Line, "reserved "+strings.Join(items, ", "))
+}
+
+// the number of the value is written only if it is not the number
+// of the previous value plus one, or zero for the first value
+func (p *printer) printEnum(e *ast.AstEnumNode) {
+	header := "enum " + e.GetName()
+	if underlyingType := p.typeName(e.GetUnderlyingType()); underlyingType != "int32" {
+		header += " : " + underlyingType
+	}
+	p.add(0, e.GetPosition().Line, header+" {")
+	values := e.GetValues()
+	implied := "0"
+	for i, v := range values {
+		text := v.GetName()
+		if v.GetNumber() != implied {
+			text += " = " + v.GetNumber()
+		}
+		if i != len(values)-1 {
+			text += ","
+		}
+		p.add(1, v.GetPosition().Line, text)
+		implied = nextEnumNumber(v.GetNumber(), e.GetUnderlyingType().IsUnsigned())
+	}
+	p.addClosing(0, e.GetEndPosition().Line)
+}
+
+// the last number of the type has no next number,
+// the empty string does not match any number then
+func nextEnumNumber(number string, unsigned bool) string {
+	if unsigned {
+		n, err := strconv.ParseUint(number, 10, 64)
+		if err != nil || n == ^uint64(0) {
+			return ""
+		}
+		return strconv.FormatUint(n+1, 10)
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n == int64(^uint64(0)>>1) {
+		return ""
+	}
+	return strconv.FormatInt(n+1, 10)
+}
+
+// returns the type as it is written in the schema, the structs and the
+// enums of the package of the file are written without the package name
+func (p *printer) typeName(t ast.SmeType) string {
+	switch v := t.(type) {
+	case *ast.SmeInt8:
+		return "int8"
+	case *ast.SmeInt16:
+		return "int16"
+	case *ast.SmeInt32:
+		return "int32"
+	case *ast.SmeInt64:
+		return "int64"
+	case *ast.SmeUint8:
+		return "uint8"
+	case *ast.SmeUint16:
+		return "uint16"
+	case *ast.SmeUint32:
+		return "uint32"
+	case *ast.SmeUint64:
+		return "uint64"
+	case *ast.SmeFloat:
+		return "float"
+	case *ast.SmeDouble:
+		return "double"
+	case *ast.SmeString:
+		return "string"
+	case *ast.SmeChar:
+		return "char"
+	case *ast.SmeBool:
+		return "bool"
+	case *ast.SmeList:
+		return "list[" + p.typeName(v.ValueType()) + "]"
+	case *ast.SmeMap:
+		return "map[" + p.typeName(v.KeyType()) + ", " + p.typeName(v.ValueType()) + "]"
+	case *ast.UserDefinedStruct:
+		return p.qualifiedName(v.GetImplNode().GetPackageName(), v.GetImplNode().GetName())
+	case *ast.SmeEnum:
+		return p.qualifiedName(v.GetImplNode().GetPackageName(), v.GetImplNode().GetName())
+	}
+	return "unknown"
+}
+
+func (p *printer) qualifiedName(packageName, name string) string {
+	if packageName == p.packageName {
+		return name
+	}
+	return packageName + "." + name
+}
+
+// the strings and the characters are quoted, the other values
+// are the numbers, the bools and the names of the enum values
+func defaultValue(t ast.SmeType, v string) string {
+	switch t.(type) {
+	case *ast.SmeString:
+		return quote(v, '"')
+	case *ast.SmeChar:
+		return quote(v, '\'')
+	}
+	return v
+}
+
+var escapes = map[byte]string{
+	'\n': `\n`,
+	'\t': `\t`,
+	'\r': `\r`,
+	0:    `\0`,
+	'\\': `\\`,
+}
+
+// quotes the literal with the escape sequences the lexer reads
+func quote(s string, quote byte) string {
+	var b strings.Builder
+	b.WriteByte(quote)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == quote:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case escapes[c] != "":
+			b.WriteString(escapes[c])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(quote)
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package format
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// the schema of the README, it uses every kind of the declaration
+const readmeSchema = `syntax 0.0.1
+import "common/geo.sme"
+
+package addr_book
+option go_package = "github.com/acme/app/gen/go/addr_book"
+
+// the person in the address book
+struct Person {
+    string phoneNumber = "12345", contactName;
+    optional int8 age // years
+    map[string, list[Person]] relatives
+    Status status = ACTIVE
+    geo.Point location
+    oneof contact {
+        string email
+        int64 telegramId
+    }
+}
+
+enum Status : uint8 {
+    ACTIVE = 1,
+    BLOCKED,
+    DELETED = 10
+}
+
+struct Account { reserved 3, 5 to 9; reserved "email"; int64 id = @1
+    optional string login = @2 = "gu\"est"
+    oneof owner = @4 {
+        Person person
+        string company
+    }
+}
+`
+
+func TestSourceIsIdempotent(t *testing.T) {
+	addressBook, err := os.ReadFile("../examples/address_book.stme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"examples/address_book.stme", string(addressBook)},
+		{"README schema", readmeSchema},
+		{"single line", "syntax 0.0.1\npackage p\nstruct A { int32 x; int32 y }\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted, err := Source("test.sme", []byte(tt.src))
+			if err != nil {
+				t.Fatalf("Source: %s", err)
+			}
+			again, err := Source("test.sme", formatted)
+			if err != nil {
+				t.Fatalf("Source of the formatted file: %s\n%s", err, formatted)
+			}
+			if !bytes.Equal(formatted, again) {
+				t.Errorf("formatting is not idempotent, first pass:\n%s\nsecond pass:\n%s", formatted, again)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package format
+
+import (
+	"strings"
+
+	"github.com/Ghytro/sme/parser"
+)
+
+const indentation = "    "
+
+// printer collects the lines of the canonical file, every line remembers
+// the line of the source it is printed for, so the comments and the empty
+// lines of the source are put back between the printed lines by layout
+type printer struct {
+	srcLines    []string
+	packageName string
+	lines       []printedLine
+	// the number of the current part of the file: the syntax, the imports, the
+	// package, the options or the declaration, the parts are separated with the empty line
+	unit int
+}
+
+type printedLine struct {
+	indent  int
+	srcLine int
+	text    string
+	unit    int
+	opening bool
+	closing bool
+}
+
+func newPrinter(src string, packageName string) *printer {
+	return &printer{srcLines: strings.Split(src, "\n"), packageName: packageName}
+}
+
+func (p *printer) add(indent int, srcLine int, text string) {
+	p.lines = append(p.lines, printedLine{
+		indent:  indent,
+		srcLine: srcLine,
+		text:    text,
+		unit:    p.unit,
+		opening: strings.HasSuffix(text, "{"),
+	})
+}
+
+func (p *printer) addClosing(indent int, srcLine int) {
+	p.add(indent, srcLine, "}")
+	p.lines[len(p.lines)-1].closing = true
+}
+
+// puts the comments between the printed lines and joins them. The comment
+// after the code stays at the end of the last line printed for its source
+// line, the comment on its own line goes before the first line printed for
+// the later source line with its indentation, the comments at the end of
+// the file stay at the end
+func (p *printer) layout(comments []parser.Comment) []byte {
+	var ownLine []parser.Comment
+	for _, c := range comments {
+		if p.isTrailing(c) {
+			if i := p.lastLineAt(c.Line); i != -1 {
+				p.lines[i].text += " " + c.Text
+				continue
+			}
+		}
+		ownLine = append(ownLine, c)
+	}
+
+	var result []printedLine
+	next := 0
+	for _, c := range ownLine {
+		for next < len(p.lines) && p.lines[next].srcLine <= c.Line {
+			result = append(result, p.lines[next])
+			next++
+		}
+		comment := printedLine{srcLine: c.Line, text: c.Text, unit: p.unit + 1}
+		if next < len(p.lines) {
+			comment.indent = p.lines[next].indent
+			comment.unit = p.lines[next].unit
+			if p.lines[next].closing {
+				comment.indent++
+			}
+		}
+		result = append(result, comment)
+	}
+	result = append(result, p.lines[next:]...)
+
+	var b strings.Builder
+	for i, l := range result {
+		if i != 0 && p.emptyLineBefore(result[i-1], l) {
+			b.WriteByte('\n')
+		}
+		b.WriteString(strings.Repeat(indentation, l.indent))
+		b.WriteString(l.text)
+		b.WriteByte('\n')
+	}
+	return []byte(b.String())
+}
+
+// tells if there is the code before the comment on its line
+func (p *printer) isTrailing(c parser.Comment) bool {
+	return strings.TrimSpace(p.srcLines[c.Line-1][:c.Column-1]) != ""
+}
+
+func (p *printer) lastLineAt(srcLine int) int {
+	result := -1
+	for i, l := range p.lines {
+		if l.srcLine == srcLine {
+			result = i
+		}
+	}
+	return result
+}
+
+// the parts of the file are always separated with the single empty line,
+// inside the part the empty lines of the source are kept, but not after
+// the opening brace and not before the closing one
+func (p *printer) emptyLineBefore(prev, l printedLine) bool {
+	switch {
+	case prev.opening, l.closing:
+		return false
+	case prev.unit != l.unit:
+		return true
+	}
+	for srcLine := prev.srcLine + 1; srcLine < l.srcLine; srcLine++ {
+		if strings.TrimSpace(p.srcLines[srcLine-1]) == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -9,8 +9,6 @@ import (
 
 const DefaultSmeDir = "./sme"
 
-var GeneratedLanguages = [...]string{"cpp", "java", "go", "python"}
-
 func PathExists(path string) (bool, error) {
 	if _, err := os.Stat(path); err != nil {
 		if os.IsNotExist(err) {
@@ -36,8 +34,8 @@ func PrintError(err string) {
 	os.Exit(1)
 }
 
-func IsGeneratedLanguage(lang string) bool {
-	for _, l := range GeneratedLanguages {
+func IsGeneratedLanguage(lang string, languages []string) bool {
+	for _, l := range languages {
 		if l == lang {
 			return true
 		}
@@ -45,40 +43,87 @@ func IsGeneratedLanguage(lang string) bool {
 	return false
 }
 
-func HandleSmeFilesDirArgumentErrors(smeFilesDir *string) {
-	if *smeFilesDir == "" {
+// PathList is the flag that may be given several times
+type PathList []string
+
+func (pl *PathList) String() string {
+	return strings.Join(*pl, ",")
+}
+
+func (pl *PathList) Set(path string) error {
+	*pl = append(*pl, path)
+	return nil
+}
+
+// the paths are the directories given with -smeFilesDir and
+// the files or the directories given as the arguments
+func HandleSmeFilesArgumentErrors(paths *[]string) {
+	if len(*paths) == 0 {
 		PrintWarning(
 			fmt.Sprintf(
 				"no path specified for .sme files, using default %s directory instead",
 				DefaultSmeDir,
 			),
 		)
-		*smeFilesDir = DefaultSmeDir
+		*paths = []string{DefaultSmeDir}
+	}
+	for _, path := range *paths {
+		exists, err := PathExists(path)
+		if err != nil {
+			log.Printf("Debug: error in helpers.HandleSmeFilesArgumentErrors %s\n", err)
+			PrintError(fmt.Sprintf("incorrect path for .sme files specified: %s", path))
+		}
+		if !exists {
+			PrintError(fmt.Sprintf("incorrect path specified for sme files: %s", path))
+		}
+	}
+}
+
+// returns the comma separated extensions of the sme files,
+// the leading dot may be omitted, like "sme,stme"
+func SplitExtensions(list string) []string {
+	var result []string
+	for _, ext := range strings.Split(list, ",") {
+		if ext = strings.TrimSpace(ext); ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		result = append(result, ext)
+	}
+	return result
+}
+
+// the directory is required, unlike -smeFilesDir it has no default
+func HandleSchemaDirArgumentErrors(flagName string, dir *string) {
+	if *dir == "" {
+		PrintError(fmt.Sprintf("no path specified for %s directory with sme files", flagName))
 	}
-	exists, err := PathExists(*smeFilesDir)
+	exists, err := PathExists(*dir)
 	if err != nil {
-		log.Printf("Debug: error in helpers.HandleSmeFilesDirArgumentsErrors %s\n", err)
-		PrintError("incorrect path for dir with .sme files specified")
+		log.Printf("Debug: error in helpers.HandleSchemaDirArgumentErrors %s\n", err)
+		PrintError(fmt.Sprintf("incorrect path for %s directory with sme files", flagName))
 	}
 	if !exists {
-		PrintError("incorrect path specified for directory with sme files")
+		PrintError(fmt.Sprintf("incorrect path specified for %s directory with sme files", flagName))
 	}
 }
 
-func HandleOutLangArgumentErrors(outLang *string) {
+func HandleOutLangArgumentErrors(outLang *string, languages []string) {
 	if *outLang == "" {
 		PrintError(
 			fmt.Sprintf(
 				"language not specified, the allowed values are: %s",
-				strings.Join(GeneratedLanguages[:], ", "),
+				strings.Join(languages, ", "),
 			),
 		)
 	}
-	if !IsGeneratedLanguage(*outLang) {
+	if !IsGeneratedLanguage(*outLang, languages) {
 		PrintError(
 			fmt.Sprintf(
-				"incorrect value of generated language, the allowed values are: %s",
-				strings.Join(GeneratedLanguages[:], ", "),
+				"incorrect value of generated language, the allowed values are: %s or the name of sme-gen-<lang> plugin in PATH",
+				strings.Join(languages, ", "),
 			),
 		)
 	}
@@ -94,9 +139,29 @@ func HandlerOutDirArgumentErrors(outDir *string) {
 		PrintError("incorrect path for dir with outgoing compiled files")
 	}
 	if !exists {
-		if err := os.Mkdir(*outDir, os.ModePerm); err != nil {
+		if err := os.MkdirAll(*outDir, os.ModePerm); err != nil {
 			log.Printf("Debug: error in helpers.HandleOutDirArgumentsErrors %s\n", err)
 			PrintError(fmt.Sprintf("unable to create directory %s", *outDir))
 		}
 	}
 }
+
+func HandleJobsArgumentErrors(jobs *int) {
+	if *jobs < 1 {
+		PrintError("the number of files parsed at a time must be at least 1")
+	}
+}
+
+func HandleDiagnosticsFormatArgumentErrors(format *string, formats []string) {
+	for _, f := range formats {
+		if f == *format {
+			return
+		}
+	}
+	PrintError(
+		fmt.Sprintf(
+			"incorrect diagnostics format, the allowed values are: %s",
+			strings.Join(formats, ", "),
+		),
+	)
+}
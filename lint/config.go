@@ -0,0 +1,60 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// DefaultConfigFile is used by sme lint if it exists and no other config is given
+const DefaultConfigFile = "sme-lint.json"
+
+// the levels of the rules, the rule with LevelOff is not checked
+const (
+	LevelOff     = "off"
+	LevelWarning = "warning"
+	LevelError   = "error"
+)
+
+// Config sets the levels of the rules, like
+//
+//	{"rules": {"char-field": "off", "empty-struct": "error"}}
+//
+// the rules missing in the config have their default levels
+type Config struct {
+	Rules map[string]string `json:"rules"`
+}
+
+// DefaultConfig checks all the rules with their default levels
+func DefaultConfig() *Config {
+	return &Config{Rules: make(map[string]string)}
+}
+
+// LoadConfig reads the config from the JSON file,
+// the unknown rules and levels are the errors
+func LoadConfig(fileName string) (*Config, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	config := DefaultConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("incorrect lint config %s: %w", fileName, err)
+	}
+	for name, level := range config.Rules {
+		if findRule(name) == nil {
+			return nil, fmt.Errorf("incorrect lint config %s: unknown rule %s", fileName, name)
+		}
+		if level != LevelOff && level != LevelWarning && level != LevelError {
+			return nil, fmt.Errorf("incorrect lint config %s: level of rule %s must be off, warning or error, but got: %s", fileName, name, level)
+		}
+	}
+	return config, nil
+}
+
+func (c *Config) level(r *rule) string {
+	if level, ok := c.Rules[r.name]; ok {
+		return level
+	}
+	return r.level
+}
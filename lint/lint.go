@@ -0,0 +1,173 @@
+// Package lint checks the conventions of the sme files on the parsed tree.
+// Config sets the levels of the rules, the problems on the line with the
+// "// sme:nolint" comment are not reported, the comment may list the rules
+// to suppress like "// sme:nolint field-name, char-field".
+package lint
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/Ghytro/sme/ast"
+	"github.com/Ghytro/sme/parser"
+)
+
+type rule struct {
+	name  string
+	code  string
+	level string
+}
+
+var rules = []*rule{
+	{name: "struct-name", code: parser.CodeLintStructName, level: LevelError},
+	{name: "field-name", code: parser.CodeLintFieldName, level: LevelError},
+	{name: "null-default", code: parser.CodeLintNullDefault, level: LevelError},
+	{name: "char-field", code: parser.CodeLintCharField, level: LevelWarning},
+	{name: "empty-struct", code: parser.CodeLintEmptyStruct, level: LevelWarning},
+}
+
+func findRule(name string) *rule {
+	for _, r := range rules {
+		if r.name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// the conventions are stricter than parser.NamePattern:
+// the case of the first letter is fixed and the underscores are not allowed
+func isPascalCase(name string) bool {
+	return parser.NamePattern.MatchString(name) && unicode.IsUpper(rune(name[0])) && !strings.Contains(name, "_")
+}
+
+func isCamelCase(name string) bool {
+	return parser.NamePattern.MatchString(name) && unicode.IsLower(rune(name[0])) && !strings.Contains(name, "_")
+}
+
+const nolintDirective = "sme:nolint"
+
+type linter struct {
+	config      *Config
+	diagnostics *parser.Diagnostics
+	// the rules suppressed on the lines of the files,
+	// the empty name suppresses all the rules
+	nolint map[string]map[int][]string
+}
+
+// Run checks the declarations of the tree and adds the problems
+// to diagnostics with the levels of the rules given by config
+func Run(tree *ast.AstTree, config *Config, diagnostics *parser.Diagnostics) {
+	if tree == nil {
+		return
+	}
+	l := &linter{config: config, diagnostics: diagnostics, nolint: make(map[string]map[int][]string)}
+	for _, f := range tree.GetRoot().GetFiles() {
+		l.readNolint(f.GetName())
+	}
+	for _, p := range tree.GetRoot().GetPackages() {
+		for _, s := range p.GetStructs() {
+			if s.IsDeclared() {
+				l.checkStruct(s)
+			}
+		}
+		for _, e := range p.GetEnums() {
+			if !isPascalCase(e.GetName()) {
+				l.report("struct-name", e.GetPosition(), "enum name %s is not PascalCase", e.GetName())
+			}
+		}
+	}
+}
+
+// the file that can not be read has no suppressed lines,
+// it is already parsed, so the error is not reported again
+func (l *linter) readNolint(fileName string) {
+	src, err := os.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+	lines := make(map[int][]string)
+	for _, c := range parser.Comments(string(src)) {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if !strings.HasPrefix(text, nolintDirective) {
+			continue
+		}
+		names := strings.TrimSpace(strings.TrimPrefix(text, nolintDirective))
+		if names == "" {
+			lines[c.Line] = []string{""}
+			continue
+		}
+		for _, name := range strings.Split(names, ",") {
+			lines[c.Line] = append(lines[c.Line], strings.TrimSpace(name))
+		}
+	}
+	l.nolint[fileName] = lines
+}
+
+func (l *linter) checkStruct(s *ast.AstStructNode) {
+	if !isPascalCase(s.GetName()) {
+		l.report("struct-name", s.GetPosition(), "struct name %s is not PascalCase", s.GetName())
+	}
+	if len(s.GetFields()) == 0 {
+		l.report("empty-struct", s.GetPosition(), "struct %s has no fields", s.GetName())
+	}
+	for _, f := range s.GetFields() {
+		if oneof, ok := f.GetFieldType().(*ast.SmeOneof); ok {
+			l.checkName(f, "oneof")
+			for _, m := range oneof.GetImplNode().GetMembers() {
+				l.checkField(m, "oneof member")
+			}
+			continue
+		}
+		l.checkField(f, "field")
+		if f.HasNullDefault() {
+			l.report("null-default", f.GetPosition(),
+				"optional field %s has null default value, the optional fields are null by default", f.GetName())
+		}
+	}
+}
+
+func (l *linter) checkField(f *ast.AstStructFieldNode, what string) {
+	l.checkName(f, what)
+	if _, ok := f.GetFieldType().(*ast.SmeChar); ok {
+		l.report("char-field", f.GetPosition(),
+			"%s %s has type char, it holds a single byte, use string or uint8 instead", what, f.GetName())
+	}
+}
+
+func (l *linter) checkName(f *ast.AstStructFieldNode, what string) {
+	if !isCamelCase(f.GetName()) {
+		l.report("field-name", f.GetPosition(), "%s name %s is not camelCase", what, f.GetName())
+	}
+}
+
+func (l *linter) report(ruleName string, pos ast.Position, format string, args ...interface{}) {
+	r := findRule(ruleName)
+	level := l.config.level(r)
+	if level == LevelOff || l.isSuppressed(r, pos) {
+		return
+	}
+	severity := parser.SeverityError
+	if level == LevelWarning {
+		severity = parser.SeverityWarning
+	}
+	l.diagnostics.Add(parser.Diagnostic{
+		Severity: severity,
+		Code:     r.code,
+		File:     pos.File,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Message:  fmt.Sprintf(format, args...) + " (" + r.name + ")",
+	})
+}
+
+func (l *linter) isSuppressed(r *rule, pos ast.Position) bool {
+	for _, name := range l.nolint[pos.File][pos.Line] {
+		if name == "" || name == r.name {
+			return true
+		}
+	}
+	return false
+}
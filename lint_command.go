@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"runtime"
+	"strings"
+
+	"github.com/Ghytro/sme/helpers"
+	"github.com/Ghytro/sme/lint"
+	"github.com/Ghytro/sme/parser"
+)
+
+// runLint checks the conventions of the sme files, the problems are
+// reported only if the files have no errors
+func runLint(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	var smeFiles helpers.PathList
+	flags.Var(&smeFiles, "smeFilesDir", "Directory with sme files, may be given several times, the files and the directories may also follow the flags")
+	extensions := flags.String("ext", strings.Join(parser.DefaultExtensions, ","), "Comma separated extensions of the sme files taken from the directories")
+	configFile := flags.String("config", "", "Lint config file, "+lint.DefaultConfigFile+" is used if it exists")
+	diagnosticsFormat := flags.String("diagnostics-format", parser.DiagnosticsFormatText, "Format of the reported problems: text, json or sarif")
+	jobs := flags.Int("j", runtime.NumCPU(), "Number of files parsed at a time")
+	flags.Parse(args)
+
+	paths := append([]string(smeFiles), flags.Args()...)
+	helpers.HandleSmeFilesArgumentErrors(&paths)
+	helpers.HandleDiagnosticsFormatArgumentErrors(diagnosticsFormat, parser.DiagnosticsFormats)
+	helpers.HandleJobsArgumentErrors(jobs)
+	config := lint.DefaultConfig()
+	if *configFile == "" {
+		if exists, _ := helpers.PathExists(lint.DefaultConfigFile); exists {
+			*configFile = lint.DefaultConfigFile
+		}
+	}
+	if *configFile != "" {
+		var err error
+		if config, err = lint.LoadConfig(*configFile); err != nil {
+			helpers.PrintError(err.Error())
+		}
+	}
+
+	tree, diagnostics := parser.Parse(paths, helpers.SplitExtensions(*extensions), *jobs)
+	if !diagnostics.HasErrors() {
+		lint.Run(tree, config, diagnostics)
+	}
+	exitWithDiagnostics(diagnostics, *diagnosticsFormat)
+}
@@ -0,0 +1,263 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Ghytro/sme/ast"
+)
+
+// symbol is the struct or the enum found at the position of the document
+type symbol struct {
+	packageName string
+	name        string
+	declaration ast.Position
+}
+
+func (s symbol) qualifiedName() string {
+	return s.packageName + "." + s.name
+}
+
+// returns the struct or the enum declared or referenced at the position,
+// nil if there is no such one
+func (ws *workspace) symbolAt(fileName string, pos position) *symbol {
+	tree := ws.tree
+	if tree == nil {
+		return nil
+	}
+	for _, p := range tree.GetRoot().GetPackages() {
+		for _, s := range p.GetStructs() {
+			if s.IsDeclared() && ws.contains(s.GetPosition(), fileName, pos) {
+				return &symbol{s.GetPackageName(), s.GetName(), s.GetPosition()}
+			}
+		}
+		for _, e := range p.GetEnums() {
+			if ws.contains(e.GetPosition(), fileName, pos) {
+				return &symbol{e.GetPackageName(), e.GetName(), e.GetPosition()}
+			}
+		}
+	}
+	for _, f := range ws.fields() {
+		for qualifiedName, refPos := range f.GetReferencePositions() {
+			if ws.contains(refPos, fileName, pos) {
+				return ws.findSymbol(qualifiedName)
+			}
+		}
+	}
+	return nil
+}
+
+// returns the declared struct or enum by the name like "package.Name"
+func (ws *workspace) findSymbol(qualifiedName string) *symbol {
+	if ws.tree == nil {
+		return nil
+	}
+	i := strings.LastIndex(qualifiedName, ".")
+	packageName, name := qualifiedName[:i], qualifiedName[i+1:]
+	if s, err := ws.tree.GetStructNode(packageName, name); err == nil && s.IsDeclared() {
+		return &symbol{packageName, name, s.GetPosition()}
+	}
+	if e, err := ws.tree.GetEnumNode(packageName, name); err == nil {
+		return &symbol{packageName, name, e.GetPosition()}
+	}
+	return nil
+}
+
+// tells if the word starting at start is under the cursor
+func (ws *workspace) contains(start ast.Position, fileName string, pos position) bool {
+	if start.File != fileName || start.Line != pos.Line+1 {
+		return false
+	}
+	r := ws.wordRange(start)
+	return r.Start.Character <= pos.Character && pos.Character < r.End.Character
+}
+
+// returns the fields and the oneof members of all the declared structs
+func (ws *workspace) fields() []*ast.AstStructFieldNode {
+	var result []*ast.AstStructFieldNode
+	tree := ws.tree
+	if tree == nil {
+		return result
+	}
+	for _, p := range tree.GetRoot().GetPackages() {
+		for _, s := range p.GetStructs() {
+			if !s.IsDeclared() {
+				continue
+			}
+			for _, f := range s.GetFields() {
+				result = append(result, f)
+				if oneof, ok := f.GetFieldType().(*ast.SmeOneof); ok {
+					result = append(result, oneof.GetImplNode().GetMembers()...)
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (ws *workspace) location(pos ast.Position) location {
+	return location{URI: fileNameToURI(pos.File), Range: ws.wordRange(pos)}
+}
+
+// the definition of the struct or the enum used in the field type
+func (ws *workspace) definition(params textDocumentPositionParams) interface{} {
+	s := ws.symbolAt(uriToFileName(params.TextDocument.URI), params.Position)
+	if s == nil {
+		return nil
+	}
+	return ws.location(s.declaration)
+}
+
+// the places where the struct or the enum is used in the field types
+func (ws *workspace) references(params referenceParams) []location {
+	result := []location{}
+	s := ws.symbolAt(uriToFileName(params.TextDocument.URI), params.Position)
+	if s == nil {
+		return result
+	}
+	var positions []ast.Position
+	if params.Context.IncludeDeclaration {
+		positions = append(positions, s.declaration)
+	}
+	for _, f := range ws.fields() {
+		if pos, ok := f.GetReferencePositions()[s.qualifiedName()]; ok {
+			positions = append(positions, pos)
+		}
+	}
+	sort.SliceStable(positions, func(i, j int) bool {
+		a, b := positions[i], positions[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	for _, pos := range positions {
+		result = append(result, ws.location(pos))
+	}
+	return result
+}
+
+// the field under the cursor is shown with its resolved type, the struct
+// or the enum under the cursor is shown with its declaration
+func (ws *workspace) hover(params textDocumentPositionParams) interface{} {
+	fileName := uriToFileName(params.TextDocument.URI)
+	for _, f := range ws.fields() {
+		if ws.contains(f.GetPosition(), fileName, params.Position) {
+			r := ws.wordRange(f.GetPosition())
+			return hover{Contents: sme(fieldDeclaration(f)), Range: &r}
+		}
+	}
+	s := ws.symbolAt(fileName, params.Position)
+	if s == nil {
+		return nil
+	}
+	if e, err := ws.tree.GetEnumNode(s.packageName, s.name); err == nil {
+		return hover{Contents: sme(fmt.Sprintf("enum %s : %s", s.qualifiedName(), ast.TypeName(e.GetUnderlyingType())))}
+	}
+	return hover{Contents: sme("struct " + s.qualifiedName())}
+}
+
+func sme(code string) markupContent {
+	return markupContent{Kind: "markdown", Value: "```sme\n" + code + "\n```"}
+}
+
+func fieldDeclaration(f *ast.AstStructFieldNode) string {
+	var b strings.Builder
+	t := f.GetFieldType()
+	if oneof, ok := t.(*ast.SmeOneof); ok {
+		b.WriteString("oneof " + f.GetName())
+		if f.GetTag() != 0 {
+			fmt.Fprintf(&b, " = @%d", f.GetTag())
+		}
+		b.WriteString(" {\n")
+		for _, m := range oneof.GetImplNode().GetMembers() {
+			b.WriteString("    " + ast.TypeName(m.GetFieldType()) + " " + m.GetName() + "\n")
+		}
+		b.WriteString("}")
+		return b.String()
+	}
+	if t.IsOptional() {
+		b.WriteString("optional ")
+	}
+	b.WriteString(ast.TypeName(t) + " " + f.GetName())
+	if f.GetTag() != 0 {
+		fmt.Fprintf(&b, " = @%d", f.GetTag())
+	}
+	if v, err := t.DefaultValue(); err == nil {
+		switch t.(type) {
+		case *ast.SmeString:
+			v = fmt.Sprintf("%q", v)
+		case *ast.SmeChar:
+			v = "'" + v + "'"
+		}
+		b.WriteString(" = " + v)
+	}
+	return b.String()
+}
+
+// the primitive types, the structs and the enums, the ones of the package
+// of the document are offered without the package name. After "package."
+// only the structs and the enums of that package are offered
+func (ws *workspace) completion(params textDocumentPositionParams) []completionItem {
+	fileName := uriToFileName(params.TextDocument.URI)
+	line := ws.line(fileName, params.Position.Line+1)
+	prefix := ""
+	if end := byteOffset(line, params.Position.Character); end <= len(line) {
+		prefix = line[wordStart(line, end):end]
+	}
+	packageName := ""
+	if ws.tree != nil {
+		if fileNode, err := ws.tree.GetFileNode(fileName); err == nil {
+			packageName = fileNode.GetPackageName()
+		}
+	}
+
+	result := []completionItem{}
+	qualifier := ""
+	if i := strings.LastIndex(prefix, "."); i != -1 {
+		qualifier = prefix[:i]
+	} else {
+		for _, name := range ast.PrimitiveTypeNames {
+			result = append(result, completionItem{Label: name, Kind: completionItemKindKeyword})
+		}
+		for _, name := range []string{"list", "map", "optional", "oneof", "reserved"} {
+			result = append(result, completionItem{Label: name, Kind: completionItemKindKeyword})
+		}
+	}
+	tree := ws.tree
+	if tree == nil {
+		return result
+	}
+	for _, p := range tree.GetRoot().GetPackages() {
+		if qualifier != "" && p.GetName() != qualifier {
+			continue
+		}
+		label := func(name string) string {
+			if qualifier != "" || p.GetName() == packageName {
+				return name
+			}
+			return p.GetName() + "." + name
+		}
+		for _, s := range p.GetStructs() {
+			if s.IsDeclared() {
+				result = append(result, completionItem{
+					Label:  label(s.GetName()),
+					Kind:   completionItemKindStruct,
+					Detail: "struct " + p.GetName() + "." + s.GetName(),
+				})
+			}
+		}
+		for _, e := range p.GetEnums() {
+			result = append(result, completionItem{
+				Label:  label(e.GetName()),
+				Kind:   completionItemKindEnum,
+				Detail: "enum " + p.GetName() + "." + e.GetName(),
+			})
+		}
+	}
+	return result
+}
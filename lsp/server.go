@@ -0,0 +1,197 @@
+// Package lsp is the Language Server Protocol server for the sme files,
+// it speaks JSON-RPC over stdio with the editors. The open documents and
+// the sme files of the workspace are parsed again on every change, the
+// parser diagnostics are published for every file and the features are
+// answered from the tree: the definitions and the references of the
+// structs and the enums, the hover with the field types and the completion
+// of the type names.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// ErrExitWithoutShutdown is returned by Serve if the client asked
+// to exit without asking to shut down first
+var ErrExitWithoutShutdown = errors.New("exit notification without shutdown request")
+
+// Server keeps the open documents and the tree parsed from them
+type Server struct {
+	r *bufio.Reader
+	w io.Writer
+
+	initialized bool
+	shutdown    bool
+	workspace   *workspace
+}
+
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{r: bufio.NewReader(r), w: w, workspace: newWorkspace()}
+}
+
+// Serve answers the messages of the client until the exit notification,
+// the error is returned if the connection is broken
+func (s *Server) Serve() error {
+	for {
+		body, err := s.readMessage()
+		if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.writeMessage(errorResponse{JSONRPC: "2.0", Error: &responseError{codeParseError, err.Error()}})
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+		result, respErr := s.handle(req)
+		if req.ID == nil {
+			// the notifications are not answered
+			continue
+		}
+		if respErr != nil {
+			s.writeMessage(errorResponse{JSONRPC: "2.0", ID: req.ID, Error: respErr})
+			continue
+		}
+		s.writeMessage(response{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}
+}
+
+func (s *Server) handle(req request) (interface{}, *responseError) {
+	if !s.initialized && req.Method != "initialize" {
+		return nil, &responseError{codeServerNotStarted, "server is not initialized"}
+	}
+	if s.shutdown {
+		return nil, &responseError{codeInvalidRequest, "server is shut down"}
+	}
+	switch req.Method {
+	case "initialize":
+		var params initializeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.initialized = true
+		s.workspace.setRoot(params.RootURI)
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync:   textDocumentSyncFull,
+				DefinitionProvider: true,
+				HoverProvider:      true,
+				CompletionProvider: completionOptions{TriggerCharacters: []string{"."}},
+				ReferencesProvider: true,
+			},
+			ServerInfo: serverInfo{Name: "sme"},
+		}, nil
+	case "initialized":
+		s.publishDiagnostics()
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.workspace.open(params.TextDocument.URI, params.TextDocument.Text)
+		s.publishDiagnostics()
+		return nil, nil
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if n := len(params.ContentChanges); n != 0 {
+			s.workspace.open(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+			s.publishDiagnostics()
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.workspace.close(params.TextDocument.URI)
+		s.publishDiagnostics()
+		return nil, nil
+	case "textDocument/didSave":
+		return nil, nil
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.workspace.definition(params), nil
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.workspace.hover(params), nil
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.workspace.completion(params), nil
+	case "textDocument/references":
+		var params referenceParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.workspace.references(params), nil
+	}
+	if req.ID == nil || strings.HasPrefix(req.Method, "$/") {
+		// the unknown notifications and the optional requests are ignored
+		return nil, nil
+	}
+	return nil, &responseError{codeMethodNotFound, "method is not supported: " + req.Method}
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{codeInvalidParams, err.Error()}
+}
+
+func (s *Server) publishDiagnostics() {
+	for _, params := range s.workspace.parse() {
+		s.writeMessage(notification{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: params})
+	}
+}
+
+// the message is the JSON body after the headers, the only required header
+// is Content-Length, the headers are separated from the body with the empty line
+func (s *Server) readMessage() ([]byte, error) {
+	headers, err := textproto.NewReader(s.r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(headers.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("incorrect Content-Length header: %w", err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (s *Server) writeMessage(message interface{}) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n", len(body))
+	s.w.Write(body)
+}
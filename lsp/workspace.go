@@ -0,0 +1,237 @@
+package lsp
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Ghytro/sme/ast"
+	"github.com/Ghytro/sme/helpers"
+	"github.com/Ghytro/sme/parser"
+	"github.com/Ghytro/sme/project"
+)
+
+// workspace is the root directory of the editor with the documents open in
+// it, the sme files of the root and the open documents are parsed together,
+// so the structs of the other files of the package are resolved
+type workspace struct {
+	root string
+	// the texts of the open documents by the file names
+	documents map[string]string
+	// the files the diagnostics were published for,
+	// they are cleared when the problems are fixed
+	published map[string]bool
+	// the tree of the last parse, nil if no file was parsed
+	tree *ast.AstTree
+}
+
+func newWorkspace() *workspace {
+	return &workspace{documents: make(map[string]string), published: make(map[string]bool)}
+}
+
+// the server works without the root too, then only the open documents are parsed
+func (ws *workspace) setRoot(rootURI string) {
+	if rootURI != "" {
+		ws.root = uriToFileName(rootURI)
+	}
+}
+
+func (ws *workspace) open(uri string, text string) {
+	ws.documents[uriToFileName(uri)] = text
+}
+
+func (ws *workspace) close(uri string) {
+	delete(ws.documents, uriToFileName(uri))
+}
+
+// parses the workspace into the new tree and returns the diagnostics of every
+// file, the files with no problems anymore get the empty list of diagnostics
+func (ws *workspace) parse() []publishDiagnosticsParams {
+	overlay := make(map[string][]byte, len(ws.documents))
+	fileNames := ws.smeFiles()
+	for fileName, text := range ws.documents {
+		overlay[fileName] = []byte(text)
+		if !containsString(fileNames, fileName) {
+			fileNames = append(fileNames, fileName)
+		}
+	}
+	sort.Strings(fileNames)
+	tree, diagnostics := parser.ParseFiles(fileNames, overlay)
+	ws.tree = tree
+
+	byFile := make(map[string][]diagnostic)
+	for _, d := range diagnostics.Sorted() {
+		if d.File == "" {
+			continue
+		}
+		severity := diagnosticSeverityError
+		if d.Severity == parser.SeverityWarning {
+			severity = diagnosticSeverityWarning
+		}
+		byFile[d.File] = append(byFile[d.File], diagnostic{
+			Range:    ws.wordRange(ast.Position{File: d.File, Line: d.Line, Column: d.Column}),
+			Severity: severity,
+			Code:     d.Code,
+			Source:   "sme",
+			Message:  d.Message,
+		})
+	}
+	for fileName := range ws.published {
+		if _, ok := byFile[fileName]; !ok {
+			byFile[fileName] = []diagnostic{}
+		}
+	}
+	ws.published = make(map[string]bool)
+	var result []publishDiagnosticsParams
+	for fileName, list := range byFile {
+		if len(list) != 0 {
+			ws.published[fileName] = true
+		}
+		result = append(result, publishDiagnosticsParams{URI: fileNameToURI(fileName), Diagnostics: list})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].URI < result[j].URI
+	})
+	return result
+}
+
+// returns the sme files of the root found by parser.Discover, so the
+// server sees the same files as sme: the roots and the extensions of the
+// project config of the root are used if it has them, the files matching
+// .smeignore are skipped. The paths that can not be read are left
+// to the diagnostics of the open documents
+func (ws *workspace) smeFiles() []string {
+	if ws.root == "" {
+		return nil
+	}
+	paths := []string{ws.root}
+	extensions := parser.DefaultExtensions
+	if config, err := project.Load(filepath.Join(ws.root, project.DefaultConfigFile)); err == nil {
+		if len(config.Roots) != 0 {
+			paths = config.Roots
+		}
+		if len(config.Extensions) != 0 {
+			extensions = helpers.SplitExtensions(strings.Join(config.Extensions, ","))
+		}
+	}
+	return parser.Discover(paths, extensions, new(parser.Diagnostics))
+}
+
+// returns the text of the open document or of the file on disk,
+// the empty text if the file can not be read
+func (ws *workspace) text(fileName string) string {
+	if text, ok := ws.documents[fileName]; ok {
+		return text
+	}
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+func (ws *workspace) line(fileName string, line int) string {
+	lines := strings.Split(ws.text(fileName), "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
+}
+
+// returns the range of the word starting at the position, like the name of
+// the struct or the package qualified name, the columns of the parser are
+// counted in bytes, they are converted to the UTF-16 code units of LSP
+func (ws *workspace) wordRange(pos ast.Position) textRange {
+	if !pos.IsValid() {
+		return textRange{}
+	}
+	line := ws.line(pos.File, pos.Line)
+	startOffset := pos.Column - 1
+	endOffset := wordEnd(line, startOffset)
+	start := position{Line: pos.Line - 1, Character: utf16Column(line, startOffset)}
+	end := position{Line: start.Line, Character: utf16Column(line, endOffset)}
+	if end.Character == start.Character {
+		end.Character++
+	}
+	return textRange{Start: start, End: end}
+}
+
+// returns the number of UTF-16 code units the rune takes
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// converts the byte offset in the line to the column of LSP, counted in UTF-16
+// code units, the offset past the end of the line is counted in bytes after it
+func utf16Column(line string, offset int) int {
+	if offset > len(line) {
+		return utf16Column(line, len(line)) + offset - len(line)
+	}
+	column := 0
+	for _, r := range line[:offset] {
+		column += utf16Len(r)
+	}
+	return column
+}
+
+// converts the column of LSP, counted in UTF-16 code units, to the byte offset
+// in the line, the column past the end of the line is counted in bytes after it
+func byteOffset(line string, column int) int {
+	n := 0
+	for i, r := range line {
+		if n >= column {
+			return i
+		}
+		n += utf16Len(r)
+	}
+	return len(line) + column - n
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '.'
+}
+
+func wordEnd(line string, start int) int {
+	end := start
+	for end < len(line) && end >= 0 && isWordChar(line[end]) {
+		end++
+	}
+	return end
+}
+
+func wordStart(line string, end int) int {
+	start := end
+	for start > 0 && start <= len(line) && isWordChar(line[start-1]) {
+		start--
+	}
+	return start
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func uriToFileName(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return filepath.Clean(uri)
+	}
+	return filepath.Clean(filepath.FromSlash(u.Path))
+}
+
+func fileNameToURI(fileName string) string {
+	if abs, err := filepath.Abs(fileName); err == nil {
+		fileName = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(fileName)}).String()
+}
@@ -0,0 +1,71 @@
+package lsp
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNonASCIILine(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "a.sme")
+	uri := fileNameToURI(fileName)
+	// "ё" takes 2 bytes and 1 UTF-16 code unit, "🙂" takes 4 bytes and 2 code units,
+	// so B starts at byte 26 and at UTF-16 column 22 of the line
+	const text = "syntax 0.0.1\n\npackage p\n\nstruct A {\n" +
+		"    string s = \"ё🙂\"; B b; Missing m; p.B c\n" +
+		"}\n\nstruct B {\n    int32 x\n}\n"
+	ws := newWorkspace()
+	ws.open(uri, text)
+	published := ws.parse()
+
+	if len(published) != 1 || len(published[0].Diagnostics) != 1 {
+		t.Fatalf("want one diagnostic, got %+v", published)
+	}
+	wantRange := textRange{Start: position{5, 27}, End: position{5, 34}}
+	if got := published[0].Diagnostics[0].Range; got != wantRange {
+		t.Errorf("diagnostic range is %+v, want %+v", got, wantRange)
+	}
+
+	params := textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: uri}, Position: position{5, 22}}
+	definition, ok := ws.definition(params).(location)
+	if !ok {
+		t.Fatalf("no definition of B at %+v", params.Position)
+	}
+	wantDefinition := textRange{Start: position{8, 7}, End: position{8, 8}}
+	if definition.Range != wantDefinition {
+		t.Errorf("definition range is %+v, want %+v", definition.Range, wantDefinition)
+	}
+
+	params.Position = position{5, 24}
+	h, ok := ws.hover(params).(hover)
+	if !ok {
+		t.Fatalf("no hover of field b at %+v", params.Position)
+	}
+	if want := (textRange{Start: position{5, 24}, End: position{5, 25}}); h.Range == nil || *h.Range != want {
+		t.Errorf("hover range is %+v, want %+v", h.Range, want)
+	}
+
+	// after "p." only the structs of the package are offered
+	params.Position = position{5, 40}
+	items := ws.completion(params)
+	if len(items) != 2 || items[0].Label != "A" || items[1].Label != "B" {
+		t.Errorf("completion after p. offers %+v, want A and B", items)
+	}
+}
+
+func TestColumnConversion(t *testing.T) {
+	const line = "aё🙂b"
+	tests := []struct {
+		offset int
+		column int
+	}{
+		{0, 0}, {1, 1}, {3, 2}, {7, 4}, {8, 5}, {10, 7},
+	}
+	for _, tt := range tests {
+		if got := utf16Column(line, tt.offset); got != tt.column {
+			t.Errorf("utf16Column(%d) = %d, want %d", tt.offset, got, tt.column)
+		}
+		if got := byteOffset(line, tt.column); got != tt.offset {
+			t.Errorf("byteOffset(%d) = %d, want %d", tt.column, got, tt.offset)
+		}
+	}
+}
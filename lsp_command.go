@@ -0,0 +1,15 @@
+package main
+
+import (
+	"os"
+
+	"github.com/Ghytro/sme/helpers"
+	"github.com/Ghytro/sme/lsp"
+)
+
+// runLsp serves the editor over stdin and stdout until it asks to exit
+func runLsp() {
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		helpers.PrintError(err.Error())
+	}
+}
@@ -2,20 +2,155 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
 
+	"github.com/Ghytro/sme/codegen"
+	_ "github.com/Ghytro/sme/codegen/cpp"
+	_ "github.com/Ghytro/sme/codegen/golang"
+	_ "github.com/Ghytro/sme/codegen/java"
+	_ "github.com/Ghytro/sme/codegen/python"
 	"github.com/Ghytro/sme/helpers"
 	"github.com/Ghytro/sme/parser"
+	"github.com/Ghytro/sme/project"
 )
 
 func main() {
-	smeFilesDir := flag.String("smeFilesDir", "", "Directory with smep files")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "fmt":
+			runFmt(os.Args[2:])
+			return
+		case "lint":
+			runLint(os.Args[2:])
+			return
+		case "lsp":
+			runLsp()
+			return
+		}
+	}
+
+	configFile := flag.String("config", "", "Project config file, "+project.DefaultConfigFile+" is used if it exists")
+	var smeFiles helpers.PathList
+	flag.Var(&smeFiles, "smeFilesDir", "Directory with sme files, may be given several times, the files and the directories may also follow the flags")
+	extensions := flag.String("ext", strings.Join(parser.DefaultExtensions, ","), "Comma separated extensions of the sme files taken from the directories")
 	outLang := flag.String("outLang", "", "Language to generate the code")
 	outDir := flag.String("outDir", "", "Where to generate the out code")
+	cppNamespace := flag.String("cppNamespace", "", "Namespace to wrap the generated C++ code in")
+	diagnosticsFormat := flag.String("diagnostics-format", parser.DiagnosticsFormatText, "Format of the reported errors: text, json or sarif")
+	jobs := flag.Int("j", runtime.NumCPU(), "Number of files parsed at a time")
 	flag.Parse()
 
-	helpers.HandleSmeFilesDirArgumentErrors(smeFilesDir)
-	helpers.HandleOutLangArgumentErrors(outLang)
-	helpers.HandlerOutDirArgumentErrors(outDir)
+	config := &project.Config{}
+	if *configFile == "" {
+		if exists, _ := helpers.PathExists(project.DefaultConfigFile); exists {
+			*configFile = project.DefaultConfigFile
+		}
+	}
+	if *configFile != "" {
+		var err error
+		if config, err = project.Load(*configFile); err != nil {
+			helpers.PrintError(err.Error())
+		}
+	}
+	// the flags given explicitly override the config
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	paths := append([]string(smeFiles), flag.Args()...)
+	if len(paths) == 0 {
+		paths = config.Roots
+	}
+	helpers.HandleSmeFilesArgumentErrors(&paths)
+	if !setFlags["ext"] && len(config.Extensions) != 0 {
+		*extensions = strings.Join(config.Extensions, ",")
+	}
+	targets := selectTargets(config.Targets, *outLang, *outDir, *cppNamespace)
+	generators := make([]codegen.Generator, len(targets))
+	for i := range targets {
+		generator, ok := codegen.Lookup(targets[i].Lang)
+		if !ok {
+			helpers.HandleOutLangArgumentErrors(&targets[i].Lang, codegen.Languages())
+		}
+		generators[i] = generator
+		helpers.HandlerOutDirArgumentErrors(&targets[i].OutDir)
+	}
+	helpers.HandleDiagnosticsFormatArgumentErrors(diagnosticsFormat, parser.DiagnosticsFormats)
+	helpers.HandleJobsArgumentErrors(jobs)
+
+	tree, diagnostics := parser.Parse(paths, helpers.SplitExtensions(*extensions), *jobs)
+	if !diagnostics.HasErrors() {
+		for i, t := range targets {
+			err := generators[i].Generate(tree, t.OutDir, codegen.Options{Params: t.Options})
+			if err == nil {
+				continue
+			}
+			message := err.Error()
+			if len(targets) > 1 {
+				message = fmt.Sprintf("%s to %s: %s", t.Lang, t.OutDir, message)
+			}
+			diagnostics.Add(parser.Diagnostic{
+				Severity: parser.SeverityError,
+				Code:     parser.CodeGenerationFailed,
+				Message:  message,
+			})
+		}
+	}
+
+	exitWithDiagnostics(diagnostics, *diagnosticsFormat)
+}
+
+// returns the targets of the config overridden by the flags: with -outLang
+// only the targets of that language are generated, or the new one if the
+// config has none, -outDir replaces the directory of the single target and
+// -cppNamespace replaces the namespace of the C++ targets
+func selectTargets(configTargets []project.Target, outLang string, outDir string, cppNamespace string) []project.Target {
+	var targets []project.Target
+	for _, t := range configTargets {
+		if outLang == "" || t.Lang == outLang {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == 0 {
+		targets = append(targets, project.Target{Lang: outLang})
+	}
+	if outDir != "" {
+		if len(targets) > 1 {
+			helpers.PrintError(fmt.Sprintf("-outDir can be given for a single target only, but the config has %d of them, select one with -outLang", len(targets)))
+		}
+		targets[0].OutDir = outDir
+	}
+	for i := range targets {
+		options := make(map[string]string, len(targets[i].Options)+1)
+		for name, value := range targets[i].Options {
+			options[name] = value
+		}
+		if targets[i].Lang == "cpp" && cppNamespace != "" {
+			options["namespace"] = cppNamespace
+		}
+		targets[i].Options = options
+	}
+	return targets
+}
 
-	parser.Parse(smeFilesDir, outLang)
+// the text is for humans, the structured formats are for the tools
+// reading stdout, so they are written even if there are no problems
+func exitWithDiagnostics(diagnostics *parser.Diagnostics, diagnosticsFormat string) {
+	out := os.Stdout
+	if diagnosticsFormat == parser.DiagnosticsFormatText {
+		out = os.Stderr
+	}
+	if err := diagnostics.Write(out, diagnosticsFormat); err != nil {
+		helpers.PrintError(err.Error())
+	}
+	if diagnostics.HasErrors() {
+		os.Exit(1)
+	}
 }
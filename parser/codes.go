@@ -0,0 +1,142 @@
+package parser
+
+// Every diagnostic has a stable code, so the tools processing the
+// diagnostics can filter on it. The codes are never reused or renumbered:
+// SME0xxx are the problems with the files themselves,
+// SME1xxx are the syntax errors,
+// SME2xxx are the errors in the declarations,
+// SME3xxx are the errors of the code generation,
+// SME4xxx are the breaking changes found by sme diff,
+// SME5xxx are the problems found by sme lint.
+const (
+	CodeReadFailed = "SME0001"
+
+	CodeSyntax                    = "SME1000"
+	CodeIncorrectToken            = "SME1001"
+	CodeUnexpectedToken           = "SME1002"
+	CodeExpectedSyntaxKw          = "SME1003"
+	CodeIncorrectSyntaxVer        = "SME1004"
+	CodeSyntaxVerMismatch         = "SME1005"
+	CodeExpectedPackageKw         = "SME1006"
+	CodeIncorrectPackageName      = "SME1007"
+	CodeExpectedStructKw          = "SME1008"
+	CodeNoStructName              = "SME1009"
+	CodeIncorrectStructName       = "SME1010"
+	CodeExpectedOpeningCurlyBrace = "SME1011"
+	CodeExpectedClosingCurlyBrace = "SME1012"
+	CodeIncorrectDefaultValue     = "SME1013"
+	CodeIncorrectType             = "SME1014"
+	CodeIncorrectEnumType         = "SME1015"
+	CodeIncorrectOneofMember      = "SME1016"
+	CodeIncorrectFieldTag         = "SME1017"
+	CodeIncorrectReserved         = "SME1018"
+	CodeUnknownOption             = "SME1019"
+	CodeIncorrectOptionValue      = "SME1020"
+
+	CodeNoSuchPackage          = "SME2001"
+	CodeStructAlreadyExists    = "SME2002"
+	CodeFieldAlreadyExists     = "SME2003"
+	CodeUndeclaredStruct       = "SME2004"
+	CodeRecursiveStruct        = "SME2005"
+	CodeEnumAlreadyExists      = "SME2006"
+	CodeEnumValueAlreadyExists = "SME2007"
+	CodeIncorrectEnumValue     = "SME2008"
+	CodeTooManyOneofMembers    = "SME2009"
+	CodeImportNotFound         = "SME2010"
+	CodeCircularImport         = "SME2011"
+	CodePackageNotImported     = "SME2012"
+	CodeFieldTagAlreadyUsed    = "SME2013"
+	CodeMissingFieldTag        = "SME2014"
+	CodeFieldNameReserved      = "SME2015"
+	CodeFieldTagReserved       = "SME2016"
+	CodeOptionConflict         = "SME2017"
+
+	CodeGenerationFailed = "SME3001"
+
+	CodePackageRemoved          = "SME4001"
+	CodePackageRenamed          = "SME4002"
+	CodeStructRemoved           = "SME4003"
+	CodeStructLayoutChanged     = "SME4004"
+	CodeFieldRemoved            = "SME4005"
+	CodeFieldMoved              = "SME4006"
+	CodeFieldTypeChanged        = "SME4007"
+	CodeFieldOptionalityChanged = "SME4008"
+	CodeEnumRemoved             = "SME4009"
+	CodeEnumTypeChanged         = "SME4010"
+	CodeEnumValueRemoved        = "SME4011"
+	CodeEnumValueRenumbered     = "SME4012"
+	CodeFieldAdded              = "SME4013"
+
+	CodeLintStructName  = "SME5001"
+	CodeLintFieldName   = "SME5002"
+	CodeLintNullDefault = "SME5003"
+	CodeLintCharField   = "SME5004"
+	CodeLintEmptyStruct = "SME5005"
+)
+
+// short descriptions of the codes, they are used as the rules of SARIF output
+var codeDescriptions = map[string]string{
+	CodeReadFailed: "the file can not be read",
+
+	CodeSyntax:                    "syntax error",
+	CodeIncorrectToken:            "incorrect character or literal",
+	CodeUnexpectedToken:           "unexpected token",
+	CodeExpectedSyntaxKw:          "expected 'syntax' keyword",
+	CodeIncorrectSyntaxVer:        "incorrect syntax version",
+	CodeSyntaxVerMismatch:         "syntax version differs between the files",
+	CodeExpectedPackageKw:         "expected 'package' keyword",
+	CodeIncorrectPackageName:      "incorrect package name",
+	CodeExpectedStructKw:          "expected 'struct' or 'enum' keyword",
+	CodeNoStructName:              "expected struct name",
+	CodeIncorrectStructName:       "incorrect struct name",
+	CodeExpectedOpeningCurlyBrace: "expected opening curly brace",
+	CodeExpectedClosingCurlyBrace: "expected closing curly brace",
+	CodeIncorrectDefaultValue:     "incorrect default value",
+	CodeIncorrectType:             "incorrect field type",
+	CodeIncorrectEnumType:         "underlying type of the enum is not an integer type",
+	CodeIncorrectOneofMember:      "oneof member is optional or has default value",
+	CodeIncorrectFieldTag:         "incorrect field tag",
+	CodeIncorrectReserved:         "incorrect reserved statement",
+	CodeUnknownOption:             "unknown option",
+	CodeIncorrectOptionValue:      "incorrect option value",
+
+	CodeNoSuchPackage:          "no such package",
+	CodeStructAlreadyExists:    "struct already exists",
+	CodeFieldAlreadyExists:     "field already exists",
+	CodeUndeclaredStruct:       "struct is not declared",
+	CodeRecursiveStruct:        "struct contains itself",
+	CodeEnumAlreadyExists:      "enum already exists",
+	CodeEnumValueAlreadyExists: "enum value already exists",
+	CodeIncorrectEnumValue:     "incorrect number of the enum value",
+	CodeTooManyOneofMembers:    "too many members in oneof",
+	CodeImportNotFound:         "imported file is not found",
+	CodeCircularImport:         "circular import",
+	CodePackageNotImported:     "package is not imported",
+	CodeFieldTagAlreadyUsed:    "field tag already used",
+	CodeMissingFieldTag:        "field of tagged struct has no tag",
+	CodeFieldNameReserved:      "field name is reserved",
+	CodeFieldTagReserved:       "field tag is reserved",
+	CodeOptionConflict:         "option is set to different values",
+
+	CodeGenerationFailed: "code generation failed",
+
+	CodePackageRemoved:          "package is removed",
+	CodePackageRenamed:          "package is renamed",
+	CodeStructRemoved:           "struct is removed",
+	CodeStructLayoutChanged:     "struct layout is changed",
+	CodeFieldRemoved:            "field is removed",
+	CodeFieldMoved:              "field is moved",
+	CodeFieldTypeChanged:        "field type is changed",
+	CodeFieldOptionalityChanged: "field optionality is changed",
+	CodeEnumRemoved:             "enum is removed",
+	CodeEnumTypeChanged:         "enum underlying type is changed",
+	CodeEnumValueRemoved:        "enum value is removed",
+	CodeEnumValueRenumbered:     "enum value number is changed",
+	CodeFieldAdded:              "field is added to positional struct",
+
+	CodeLintStructName:  "struct or enum name is not PascalCase",
+	CodeLintFieldName:   "field name is not camelCase",
+	CodeLintNullDefault: "optional field has null default value",
+	CodeLintCharField:   "field has char type",
+	CodeLintEmptyStruct: "struct has no fields",
+}
@@ -0,0 +1,155 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+type Severity int
+
+const (
+	SeverityError = Severity(iota)
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "Error"
+	case SeverityWarning:
+		return "Warning"
+	}
+	return "Unknown"
+}
+
+// Diagnostic is a single problem found in the sme files.
+// Line and Column are 0 if the problem is not bound to the position,
+// for example when the file can not be read, File is empty if the
+// problem is not bound to the file, for example when the generation fails
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	File     string
+	Line     int
+	Column   int
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	if d.File == "" {
+		return fmt.Sprintf("%s[%s]: %s", d.Severity, d.Code, d.Message)
+	}
+	if d.Line == 0 {
+		return fmt.Sprintf("%s[%s]: %s - %s", d.Severity, d.Code, d.File, d.Message)
+	}
+	return fmt.Sprintf("%s[%s]: %s:%d:%d - %s", d.Severity, d.Code, d.File, d.Line, d.Column, d.Message)
+}
+
+// ErrorList is returned by the parser when it found several errors in
+// one file, the parser recovers after every error and goes on
+type ErrorList []error
+
+func (el ErrorList) Error() string {
+	switch len(el) {
+	case 0:
+		return "no errors"
+	case 1:
+		return el[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", el[0], len(el)-1)
+}
+
+// Diagnostics collects the problems of all the parsed files,
+// so all of them are reported in a single run
+type Diagnostics struct {
+	list []Diagnostic
+}
+
+func (ds *Diagnostics) Add(d Diagnostic) {
+	ds.list = append(ds.list, d)
+}
+
+// AddError adds the error found in the file, the code and the position
+// are taken from SyntaxErr, ErrorList is added as the separate diagnostics,
+// the other errors are the errors of reading the file
+func (ds *Diagnostics) AddError(file string, err error) {
+	var errList ErrorList
+	if errors.As(err, &errList) {
+		for _, e := range errList {
+			ds.AddError(file, e)
+		}
+		return
+	}
+	d := Diagnostic{
+		Severity: SeverityError,
+		Code:     CodeReadFailed,
+		File:     file,
+		Message:  err.Error(),
+	}
+	var syntaxErr interface {
+		Code() string
+		Line() int
+		Column() int
+		Description() string
+	}
+	if errors.As(err, &syntaxErr) {
+		d.Code = syntaxErr.Code()
+		d.Line = syntaxErr.Line()
+		d.Column = syntaxErr.Column()
+		d.Message = syntaxErr.Description()
+	}
+	ds.Add(d)
+}
+
+func (ds *Diagnostics) HasErrors() bool {
+	for _, d := range ds.list {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (ds *Diagnostics) Len() int {
+	return len(ds.list)
+}
+
+// Sorted returns the diagnostics ordered by file, line and column
+func (ds *Diagnostics) Sorted() []Diagnostic {
+	sorted := make([]Diagnostic, len(ds.list))
+	copy(sorted, ds.list)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return sorted
+}
+
+// Print writes the sorted diagnostics to w, one per line
+func (ds *Diagnostics) Print(w io.Writer) {
+	for _, d := range ds.Sorted() {
+		fmt.Fprintln(w, d)
+	}
+}
+
+// Write writes the sorted diagnostics to w in one of DiagnosticsFormats
+func (ds *Diagnostics) Write(w io.Writer, format string) error {
+	switch format {
+	case DiagnosticsFormatText:
+		ds.Print(w)
+		return nil
+	case DiagnosticsFormatJSON:
+		return ds.writeJSON(w)
+	case DiagnosticsFormatSARIF:
+		return ds.writeSARIF(w)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownDiagnosticsFormat, format)
+}
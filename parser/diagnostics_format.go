@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	DiagnosticsFormatText  = "text"
+	DiagnosticsFormatJSON  = "json"
+	DiagnosticsFormatSARIF = "sarif"
+)
+
+var DiagnosticsFormats = []string{
+	DiagnosticsFormatText,
+	DiagnosticsFormatJSON,
+	DiagnosticsFormatSARIF,
+}
+
+var ErrUnknownDiagnosticsFormat = errors.New("unknown diagnostics format")
+
+type jsonDiagnostic struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Message  string `json:"message"`
+}
+
+type jsonDiagnostics struct {
+	Diagnostics []jsonDiagnostic `json:"diagnostics"`
+}
+
+func (ds *Diagnostics) writeJSON(w io.Writer) error {
+	out := jsonDiagnostics{Diagnostics: make([]jsonDiagnostic, 0, len(ds.list))}
+	for _, d := range ds.Sorted() {
+		out.Diagnostics = append(out.Diagnostics, jsonDiagnostic{
+			Severity: strings.ToLower(d.Severity.String()),
+			Code:     d.Code,
+			File:     d.File,
+			Line:     d.Line,
+			Column:   d.Column,
+			Message:  d.Message,
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// the subset of SARIF 2.1.0 needed to report the diagnostics,
+// see https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func (ds *Diagnostics) writeSARIF(w io.Writer) error {
+	codes := make([]string, 0, len(codeDescriptions))
+	for code := range codeDescriptions {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	rules := make([]sarifRule, 0, len(codes))
+	for _, code := range codes {
+		rules = append(rules, sarifRule{ID: code, ShortDescription: sarifMessage{codeDescriptions[code]}})
+	}
+
+	results := make([]sarifResult, 0, len(ds.list))
+	for _, d := range ds.Sorted() {
+		result := sarifResult{
+			RuleID:  d.Code,
+			Level:   strings.ToLower(d.Severity.String()),
+			Message: sarifMessage{d.Message},
+		}
+		if d.File != "" {
+			location := sarifLocation{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(d.File)},
+				},
+			}
+			if d.Line != 0 {
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+			}
+			result.Locations = []sarifLocation{location}
+		}
+		results = append(results, result)
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "sme",
+				InformationURI: "https://github.com/Ghytro/sme_compiler",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
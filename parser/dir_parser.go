@@ -1,35 +1,171 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 
-	"github.com/Ghytro/sme/helpers"
+	"github.com/Ghytro/sme/ast"
 )
 
-func Parse(smeFilesDir *string, outLang *string) {
-	if err := filepath.Walk(
-		*smeFilesDir,
-		smeFileHandler,
-	); err != nil {
-		helpers.PrintError(err.Error())
+// Parse parses the files found at paths by Discover and the files they
+// import and returns the problems found in all of them, the file with errors
+// does not stop the parsing of the others. Up to jobs files are parsed
+// at a time, the tree and the diagnostics do not depend on jobs. The tree
+// is nil if no file was parsed, otherwise it is returned even if the files
+// have errors
+func Parse(paths []string, extensions []string, jobs int) (*ast.AstTree, *Diagnostics) {
+	l := newImportLoader(new(Diagnostics), nil, jobs)
+	fileNames := Discover(paths, extensions, l.diagnostics)
+	l.prefetch(fileNames)
+	for _, fileName := range fileNames {
+		l.load(fileName)
 	}
+	return l.analyze(), l.diagnostics
 }
 
-func smeFileHandler(path string, info os.FileInfo, err error) error {
-	if err != nil {
-		return err
+// ParseFiles parses the given files and the files they import like Parse,
+// the content of the file found in overlay is parsed instead of the file
+// on disk, so the editors can check the files that are not saved yet
+func ParseFiles(fileNames []string, overlay map[string][]byte) (*ast.AstTree, *Diagnostics) {
+	cleanOverlay := make(map[string][]byte, len(overlay))
+	for fileName, content := range overlay {
+		cleanOverlay[filepath.Clean(fileName)] = content
+	}
+	l := newImportLoader(new(Diagnostics), cleanOverlay, runtime.NumCPU())
+	l.prefetch(fileNames)
+	for _, fileName := range fileNames {
+		l.load(fileName)
 	}
-	file, err := os.Open(path)
+	return l.analyze(), l.diagnostics
+}
+
+// ParseDir is Parse for the programs embedding the compiler, every call
+// builds its own tree, so several schemas can be parsed in one process.
+// The diagnostics are sorted, the error is returned only if dir is not
+// the directory that can be read
+func ParseDir(dir string) (*ast.AstTree, []Diagnostic, error) {
+	info, err := os.Stat(dir)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	defer file.Close()
 	if !info.IsDir() {
-		if err := ParseFileContent(file); err != nil {
-			helpers.PrintError(fmt.Sprintf("%s - %s", file.Name(), err.Error()))
+		return nil, nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	tree, diagnostics := Parse([]string{dir}, DefaultExtensions, runtime.NumCPU())
+	return tree, diagnostics.Sorted(), nil
+}
+
+// ParseReader parses the content of the single file read from r, name is
+// used for the positions of the nodes and to find the imported files, which
+// are read from disk. The error is returned only if r can not be read
+func ParseReader(name string, r io.Reader) (*ast.AstTree, []Diagnostic, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	name = filepath.Clean(name)
+	l := newImportLoader(new(Diagnostics), map[string][]byte{name: content}, runtime.NumCPU())
+	l.prefetch([]string{name})
+	l.load(name)
+	return l.analyze(), l.diagnostics.Sorted(), nil
+}
+
+// importLoader adds every file once to its tree, the files are loaded in
+// depth, so the chain of the files being loaded tells about the circular
+// imports. The files are parsed by the workers before they are loaded,
+// see prefetch
+type importLoader struct {
+	tree        *ast.AstTree
+	diagnostics *Diagnostics
+	overlay     map[string][]byte
+	loaded      map[string]bool
+	chain       []string
+
+	jobs   int
+	parsed map[string]*parsedFile
+}
+
+func newImportLoader(diagnostics *Diagnostics, overlay map[string][]byte, jobs int) *importLoader {
+	if jobs < 1 {
+		jobs = 1
+	}
+	return &importLoader{
+		tree:        ast.NewAstTree(),
+		diagnostics: diagnostics,
+		overlay:     overlay,
+		loaded:      make(map[string]bool),
+		jobs:        jobs,
+		parsed:      make(map[string]*parsedFile),
+	}
+}
+
+func (l *importLoader) open(fileName string) (io.ReadCloser, error) {
+	if content, ok := l.overlay[fileName]; ok {
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
+	return os.Open(fileName)
+}
+
+func (l *importLoader) exists(fileName string) bool {
+	if _, ok := l.overlay[fileName]; ok {
+		return true
+	}
+	info, err := os.Stat(fileName)
+	return err == nil && !info.IsDir()
+}
+
+func (l *importLoader) load(fileName string) {
+	fileName = filepath.Clean(fileName)
+	if l.loaded[fileName] {
+		return
+	}
+	l.loaded[fileName] = true
+	f, ok := l.parsed[fileName]
+	if !ok {
+		l.parseAll([]string{fileName})
+		f = l.parsed[fileName]
+	}
+	if f.err != nil {
+		l.diagnostics.AddError(fileName, f.err)
+	}
+	fileNode := l.merge(f)
+	if fileNode == nil {
+		return
+	}
+	l.chain = append(l.chain, fileName)
+	for _, imp := range fileNode.GetImports() {
+		l.loadImport(imp)
+	}
+	l.chain = l.chain[:len(l.chain)-1]
+}
+
+func (l *importLoader) loadImport(imp *ast.AstImportNode) {
+	pos := imp.GetPosition()
+	fileName := filepath.Clean(imp.GetFileName())
+	for i, f := range l.chain {
+		if f == fileName {
+			chain := append(append([]string{}, l.chain[i:]...), fileName)
+			l.diagnostics.AddError(pos.File, newCircularImportErr(pos.Line, pos.Column, chain))
+			return
 		}
 	}
-	return nil
+	if !l.exists(fileName) {
+		l.diagnostics.AddError(pos.File, newImportNotFoundErr(pos.Line, pos.Column, imp.GetPath()))
+		return
+	}
+	l.load(fileName)
+}
+
+// checks the loaded files together, the tree is nil
+// if none of the files has the correct syntax version
+func (l *importLoader) analyze() *ast.AstTree {
+	if l.tree.GetRoot().GetSyntaxVer() == "" {
+		return nil
+	}
+	Analyze(l.tree, l.diagnostics)
+	return l.tree
 }
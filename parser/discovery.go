@@ -0,0 +1,254 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultExtensions are the extensions of the sme files
+// taken from the directories if no other ones are given
+var DefaultExtensions = []string{".sme", ".stme"}
+
+// IgnoreFileName is the file with the gitignore patterns of the files and
+// the directories that are not taken from the directory it is in
+const IgnoreFileName = ".smeignore"
+
+// Discover returns the sme files found at paths, every file once in the order
+// of the paths. The files are taken as they are, the directories are walked
+// for the files with the extensions, DefaultExtensions if there are none. The
+// files and the directories matching the patterns of the .smeignore files of
+// the walked directories are skipped, the patterns have the gitignore syntax
+// and apply to the directory of the .smeignore file and its subdirectories.
+// The paths that can not be read are added to diagnostics
+func Discover(paths []string, extensions []string, diagnostics *Diagnostics) []string {
+	if len(extensions) == 0 {
+		extensions = DefaultExtensions
+	}
+	d := &discovery{extensions: extensions, diagnostics: diagnostics, seen: make(map[string]bool)}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			diagnostics.AddError(path, err)
+			continue
+		}
+		if !info.IsDir() {
+			d.add(path)
+			continue
+		}
+		d.walk(path)
+	}
+	return d.result
+}
+
+type discovery struct {
+	extensions  []string
+	diagnostics *Diagnostics
+	seen        map[string]bool
+	result      []string
+}
+
+func (d *discovery) add(fileName string) {
+	if fileName = filepath.Clean(fileName); !d.seen[fileName] {
+		d.seen[fileName] = true
+		d.result = append(d.result, fileName)
+	}
+}
+
+func (d *discovery) hasExtension(fileName string) bool {
+	ext := filepath.Ext(fileName)
+	for _, e := range d.extensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *discovery) walk(root string) {
+	var rules []ignoreRule
+	err := filepath.Walk(root, func(fileName string, info os.FileInfo, err error) error {
+		if err != nil {
+			d.diagnostics.AddError(fileName, err)
+			return nil
+		}
+		rel, err := filepath.Rel(root, fileName)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && isIgnored(rules, rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			rules = append(rules, d.readIgnoreFile(fileName, rel)...)
+			return nil
+		}
+		if d.hasExtension(fileName) {
+			d.add(fileName)
+		}
+		return nil
+	})
+	if err != nil {
+		d.diagnostics.AddError(root, err)
+	}
+}
+
+// ignoreRule is the line of the .smeignore file
+type ignoreRule struct {
+	// the directory of the .smeignore file relative to the walked root,
+	// it is "." for the root itself
+	dir     string
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+func (d *discovery) readIgnoreFile(dir string, rel string) []ignoreRule {
+	fileName := filepath.Join(dir, IgnoreFileName)
+	content, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		d.diagnostics.AddError(fileName, err)
+		return nil
+	}
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(content), "\n") {
+		if r, ok := parseIgnoreRule(rel, line); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// the empty lines and the comments are not rules, the incorrect
+// patterns are skipped the same way git skips them
+func parseIgnoreRule(dir string, line string) (ignoreRule, bool) {
+	line = trimIgnoreLine(line)
+	r := ignoreRule{dir: dir}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return r, false
+	}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return r, false
+	}
+	pattern, err := regexp.Compile(ignorePatternRegexp(line))
+	if err != nil {
+		return r, false
+	}
+	r.pattern = pattern
+	return r, true
+}
+
+// removes the line break and the trailing spaces that are not escaped with "\"
+func trimIgnoreLine(line string) string {
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// translates the gitignore pattern to the regular expression matching the
+// path relative to the directory of the .smeignore file. The pattern with
+// the slash at the beginning or in the middle matches the path from that
+// directory, the other patterns match the name at any depth below it
+func ignorePatternRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	if !strings.Contains(pattern, "/") {
+		b.WriteString("(?:.*/)?")
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		atSegmentStart := i == 0 || pattern[i-1] == '/'
+		switch {
+		case atSegmentStart && strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case atSegmentStart && pattern[i:] == "**":
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := classEnd(pattern, i)
+			if end == -1 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i = end
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// returns the index of the bracket closing the class opened at start,
+// the bracket right after the opening one or after "!" is the part of
+// the class, -1 is returned if the class is not closed
+func classEnd(pattern string, start int) int {
+	i := start + 1
+	if i < len(pattern) && pattern[i] == '!' {
+		i++
+	}
+	if i < len(pattern) && pattern[i] == ']' {
+		i++
+	}
+	for ; i < len(pattern); i++ {
+		if pattern[i] == ']' {
+			return i
+		}
+	}
+	return -1
+}
+
+// the last matching rule decides, so the negated rule brings back the files
+// ignored by the rules before it. The files of the ignored directory are not
+// walked at all, so they can not be brought back, like in git
+func isIgnored(rules []ignoreRule, rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		path := rel
+		if r.dir != "." {
+			if !strings.HasPrefix(rel, r.dir+"/") {
+				continue
+			}
+			path = rel[len(r.dir)+1:]
+		}
+		if r.pattern.MatchString(path) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
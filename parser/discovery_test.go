@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	const schema = "syntax 0.0.1\n\npackage p\n"
+	tests := []struct {
+		name       string
+		files      map[string]string
+		extensions []string
+		want       []string
+	}{
+		{
+			name: "default extensions",
+			files: map[string]string{
+				"a.sme": schema, "b.stme": schema, "README.md": "", "a.sme.swp": "", "sub/c.sme": schema,
+			},
+			want: []string{"a.sme", "b.stme", "sub/c.sme"},
+		},
+		{
+			name:       "configured extensions",
+			files:      map[string]string{"a.sme": schema, "b.schema": schema},
+			extensions: []string{".schema"},
+			want:       []string{"b.schema"},
+		},
+		{
+			name: "pattern matches at any depth",
+			files: map[string]string{
+				".smeignore": "draft_*.sme\n", "a.sme": schema, "draft_a.sme": schema, "sub/draft_b.sme": schema,
+			},
+			want: []string{"a.sme"},
+		},
+		{
+			name: "pattern with slash matches from the directory of .smeignore",
+			files: map[string]string{
+				".smeignore": "/a.sme\nsub/b.sme\n", "a.sme": schema, "b.sme": schema, "sub/a.sme": schema, "sub/b.sme": schema,
+			},
+			want: []string{"b.sme", "sub/a.sme"},
+		},
+		{
+			name: "negation brings back the ignored file",
+			files: map[string]string{
+				".smeignore": "*.sme\n!keep.sme\n", "a.sme": schema, "keep.sme": schema, "sub/keep.sme": schema,
+			},
+			want: []string{"keep.sme", "sub/keep.sme"},
+		},
+		{
+			name: "the last matching rule decides",
+			files: map[string]string{
+				".smeignore": "!keep.sme\n*.sme\n", "a.sme": schema, "keep.sme": schema,
+			},
+		},
+		{
+			name: "negation can not bring back the file of the ignored directory",
+			files: map[string]string{
+				".smeignore": "gen/\n!gen/keep.sme\n", "a.sme": schema, "gen/keep.sme": schema,
+			},
+			want: []string{"a.sme"},
+		},
+		{
+			name: "directory-only rule does not match the files",
+			files: map[string]string{
+				".smeignore": "build/\n", "build/a.sme": schema, "sub/build/b.sme": schema, "build.sme": schema,
+			},
+			want: []string{"build.sme"},
+		},
+		{
+			name: "directory-only rule with wildcard",
+			files: map[string]string{
+				".smeignore": "tmp*/\n", "tmp1/a.sme": schema, "tmp.sme": schema, "tmp2.sme": schema,
+			},
+			want: []string{"tmp.sme", "tmp2.sme"},
+		},
+		{
+			name: "nested .smeignore applies to its directory only",
+			files: map[string]string{
+				"sub/.smeignore": "a.sme\n", "a.sme": schema, "sub/a.sme": schema, "sub/deeper/a.sme": schema, "other/a.sme": schema,
+			},
+			want: []string{"a.sme", "other/a.sme"},
+		},
+		{
+			name: "nested negation overrides the parent rule",
+			files: map[string]string{
+				".smeignore": "*.stme\n", "sub/.smeignore": "!b.stme\n", "a.stme": schema, "sub/a.stme": schema, "sub/b.stme": schema,
+			},
+			want: []string{"sub/b.stme"},
+		},
+		{
+			name: "double star, comments and escapes",
+			files: map[string]string{
+				".smeignore": "# comment\n\nold/**/x.sme\n\\#y.sme\n", "old/x.sme": schema, "old/a/b/x.sme": schema, "#y.sme": schema, "x.sme": schema,
+			},
+			want: []string{"x.sme"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			diagnostics := new(Diagnostics)
+			var got []string
+			for _, fileName := range Discover([]string{dir}, tt.extensions, diagnostics) {
+				rel, err := filepath.Rel(dir, fileName)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if diagnostics.Len() != 0 {
+				t.Errorf("unexpected diagnostics: %v", diagnostics.Sorted())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverExplicitFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".smeignore": "*.txt\n", "a.sme": "", "notes.txt": "", "sub/b.sme": "",
+	})
+	notes := filepath.Join(dir, "notes.txt")
+	a := filepath.Join(dir, "a.sme")
+	// the files given explicitly are taken whatever their extensions are,
+	// the file found twice is returned once
+	got := Discover([]string{notes, dir, a}, nil, new(Diagnostics))
+	want := []string{notes, a, filepath.Join(dir, "sub", "b.sme")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	diagnostics := new(Diagnostics)
+	if got := Discover([]string{filepath.Join(dir, "missing")}, nil, diagnostics); len(got) != 0 || diagnostics.Len() != 1 {
+		t.Errorf("missing path gives %v and %d diagnostics, want none and 1", got, diagnostics.Len())
+	}
+}
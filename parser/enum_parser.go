@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/Ghytro/sme/ast"
+)
+
+type EnumAlreadyExistsErr struct {
+	SyntaxErr
+}
+
+func newEnumAlreadyExistsErr(line int, column int, enumName string, declaredAt ast.Position) *EnumAlreadyExistsErr {
+	eaee := new(EnumAlreadyExistsErr)
+	eaee.code = CodeEnumAlreadyExists
+	eaee.line = line
+	eaee.column = column
+	eaee.description = fmt.Sprintf("enum already exists: %s, declared at %s", enumName, declaredAt)
+	return eaee
+}
+
+type IncorrectEnumTypeErr struct {
+	SyntaxErr
+}
+
+func newIncorrectEnumTypeErr(line int, column int, typeName string) *IncorrectEnumTypeErr {
+	iete := new(IncorrectEnumTypeErr)
+	iete.code = CodeIncorrectEnumType
+	iete.line = line
+	iete.column = column
+	iete.description = fmt.Sprintf("underlying type of the enum must be an integer type, got: %s", typeName)
+	return iete
+}
+
+type EnumValueAlreadyExistsErr struct {
+	SyntaxErr
+}
+
+func newEnumValueAlreadyExistsErr(line int, column int, valueName string, declaredAt ast.Position) *EnumValueAlreadyExistsErr {
+	evaee := new(EnumValueAlreadyExistsErr)
+	evaee.code = CodeEnumValueAlreadyExists
+	evaee.line = line
+	evaee.column = column
+	evaee.description = fmt.Sprintf("enum value already exists: %s, declared at %s", valueName, declaredAt)
+	return evaee
+}
+
+type IncorrectEnumValueErr struct {
+	SyntaxErr
+}
+
+func newIncorrectEnumValueErr(line int, column int, desc string) *IncorrectEnumValueErr {
+	ieve := new(IncorrectEnumValueErr)
+	ieve.code = CodeIncorrectEnumValue
+	ieve.line = line
+	ieve.column = column
+	ieve.description = desc
+	return ieve
+}
+
+// the enums are based on int32 unless the other integer type is specified
+const defaultEnumType = "int32"
+
+func (p *fileParser) parseEnum() error {
+	p.next()
+	nameToken := p.peek()
+	if nameToken.kind != tokIdent {
+		return newSyntaxError(CodeUnexpectedToken, nameToken.line, nameToken.column, fmt.Sprintf("expected enum name, but got: %s", nameToken))
+	}
+	p.next()
+	if !NamePattern.MatchString(nameToken.text) {
+		return newSyntaxError(CodeSyntax, nameToken.line, nameToken.column, fmt.Sprintf("incorrect name of enum: %s", nameToken.text))
+	}
+
+	typeToken := nameToken
+	typeName := defaultEnumType
+	if p.peek().kind == tokColon {
+		p.next()
+		typeToken = p.peek()
+		if typeToken.kind != tokIdent {
+			return newSyntaxError(CodeUnexpectedToken, typeToken.line, typeToken.column, fmt.Sprintf("expected type name, but got: %s", typeToken))
+		}
+		p.next()
+		typeName = typeToken.text
+	}
+	if brace := p.peek(); brace.kind != tokLBrace {
+		return newExpectedOpeningCurlyBraceErr(brace.line, brace.column)
+	}
+
+	packageName := p.currentPackageNode.GetName()
+	var underlyingType ast.SmeType
+	if ast.IsPrimitiveTypeName(typeName) {
+		underlyingType, _ = p.tree.TypeFromString(packageName, typeName, false, false, nil)
+	}
+	enumNode, err := p.tree.DeclareEnum(packageName, nameToken.text, underlyingType, p.position(nameToken))
+	switch err {
+	case nil:
+		break
+	case ast.ErrNotIntegerType:
+		err = newIncorrectEnumTypeErr(typeToken.line, typeToken.column, typeName)
+	case ast.ErrEnumAlreadyExists:
+		err = newEnumAlreadyExistsErr(nameToken.line, nameToken.column, nameToken.text, enumNode.GetPosition())
+	case ast.ErrStructAlreadyExists:
+		structNode, _ := p.tree.GetStructNode(packageName, nameToken.text)
+		err = newStructAlreadyExistsErr(nameToken.line, nameToken.column, nameToken.text, structNode.GetPosition())
+	case ast.ErrNoSuchPackage:
+		err = newNoSuchPackageErr(nameToken.line, nameToken.column, packageName)
+	default:
+		err = newSyntaxError(CodeSyntax, nameToken.line, nameToken.column, err.Error())
+	}
+	if err != nil {
+		// the opening brace is not consumed yet,
+		// so the whole body of the enum is skipped on recovery
+		return err
+	}
+	p.next()
+
+	for p.peek().kind != tokRBrace {
+		if t := p.peek(); t.kind == tokEOF || p.peekIsDeclaration() {
+			return newExpectedClosingCurlyBraceErr(t.line, t.column, t.String())
+		}
+		if err := p.parseEnumValue(enumNode); err != nil {
+			p.report(err)
+			p.skipToNextEnumValue()
+		}
+	}
+	enumNode.SetEndPosition(p.position(p.next()))
+	return nil
+}
+
+func (p *fileParser) parseEnumValue(enumNode *ast.AstEnumNode) error {
+	nameToken, err := p.expect(tokIdent)
+	if err != nil {
+		return err
+	}
+	number := ""
+	if p.peek().kind == tokAssign {
+		p.next()
+		numberToken, err := p.expect(tokNumber)
+		if err != nil {
+			return err
+		}
+		number = numberToken.text
+	}
+
+	valueNode, err := ast.AddEnumValue(enumNode, nameToken.text, number, p.position(nameToken))
+	switch err {
+	case nil:
+		break
+	case ast.ErrEnumValueAlreadyExists:
+		return newEnumValueAlreadyExistsErr(nameToken.line, nameToken.column, nameToken.text, valueNode.GetPosition())
+	case ast.ErrEnumValueNumberAlreadyUsed:
+		return newIncorrectEnumValueErr(nameToken.line, nameToken.column, fmt.Sprintf(
+			"number %s of %s is already used by %s", valueNode.GetNumber(), nameToken.text, valueNode.GetName()))
+	case ast.ErrEnumValueOutOfRange:
+		return newIncorrectEnumValueErr(nameToken.line, nameToken.column, fmt.Sprintf(
+			"number of %s does not fit the underlying type of enum %s", nameToken.text, enumNode.GetName()))
+	default:
+		return newSyntaxError(CodeSyntax, nameToken.line, nameToken.column, err.Error())
+	}
+
+	switch p.peek().kind {
+	case tokComma, tokSemicolon:
+		p.next()
+	case tokRBrace:
+		break
+	default:
+		t := p.peek()
+		return newSyntaxError(CodeUnexpectedToken, t.line, t.column, fmt.Sprintf("expected: ',', but got: %s", t))
+	}
+	return nil
+}
+
+// skips the rest of the incorrect enum value up to ',', ';' or the end of the enum
+func (p *fileParser) skipToNextEnumValue() {
+	for {
+		switch p.peek().kind {
+		case tokEOF, tokRBrace:
+			return
+		case tokComma, tokSemicolon:
+			p.next()
+			return
+		}
+		p.next()
+	}
+}
@@ -1,118 +1,221 @@
 package parser
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
-	"log"
-	"os"
-	"strings"
+	"io"
+	"regexp"
 
 	"github.com/Ghytro/sme/ast"
-	"github.com/Ghytro/sme/helpers"
 )
 
-const (
-	lpStateUndefined = LineParserStateId(iota - 1)
-	lpStateReadingSyntaxVer
-	lpStateReadingPackageName
-	lpStateReadingStructName
-	lpStateReadingStruct
-)
-
-type LineParserStateId int
-type LineParserFunc func(string, *LineParserState) (LineParserStateId, error)
-
-type LineParserState struct {
-	stateId            LineParserStateId
-	lineNumber         int
+// The parser reads the tokens produced by the lexer and builds the AST
+// with the recursive descent over the grammar below, the line breaks and
+// the indentation do not matter:
+//
+//	file      = "syntax" version { import } "package" name { option } { struct | enum } .
+//	import    = "import" string [ ";" ] .
+//	option    = "option" name "=" string [ ";" ] .
+//	struct    = "struct" name "{" { field | oneof | reserved } "}" .
+//	oneof     = "oneof" name [ "=" tag ] "{" { type name [ ";" | "," ] } "}" [ ";" ] .
+//	enum      = "enum" name [ ":" type ] "{" [ enumValue { ( "," | ";" ) enumValue } [ "," | ";" ] ] "}" .
+//	enumValue = name [ "=" number ] .
+//	field     = [ "optional" ] type fieldDecl { "," fieldDecl } [ ";" ] .
+//	fieldDecl = fieldName [ "=" tag ] [ "=" value ] .
+//	tag       = "@" number .
+//	reserved  = "reserved" ( string | number [ "to" number ] ) { "," ( string | number [ "to" number ] ) } [ ";" ] .
+//	type      = "list" "[" type "]" | "map" "[" type "," type "]" | name [ "." name ] .
+//	value     = string | character | number | name .
+//
+// After an error in the field declaration the parser skips to the next
+// field, after an error in the struct or enum header it skips to the next
+// declaration, so all the errors of the file are reported at once.
+type fileParser struct {
+	tree               *ast.AstTree
+	fileName           string
+	tokens             []token
+	pos                int
+	syntaxVerPos       ast.Position
+	fileNode           *ast.AstFileNode
 	currentPackageNode *ast.AstPackageNode
 	currentStructNode  *ast.AstStructNode
+	errs               ErrorList
+
+	// the struct names used in the type of the current field
+	typeReferences []typeReference
+	// the names of the fields of the current struct declared without the tag
+	untaggedFields []token
+}
+
+var syntaxVerPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+$`)
+
+// NamePattern is for the names of the packages, the structs and the enums,
+// the tools checking the names against their conventions build upon it
+var NamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+type typeReference struct {
+	name  string
+	token token
 }
 
-func NewLineParserState() *LineParserState {
-	return &LineParserState{
-		stateId:    lpStateReadingSyntaxVer,
-		lineNumber: 1,
+// ParseFileContent adds the content of the file to tree, fileName is
+// used for the positions of the nodes, the returned error is ErrorList
+// if the content has errors. The imported files are not loaded, the
+// references to the structs are checked by Analyze after all the files are parsed
+func ParseFileContent(tree *ast.AstTree, fileName string, r io.Reader) error {
+	p, err := newFileParser(tree, fileName, r)
+	if err != nil {
+		return err
 	}
+	p.parseFile()
+	return p.err()
 }
 
-func ParseFileContent(file *os.File) error {
-	ps := NewLineParserState()
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		line = beautifyLine(string(line))
-		if line == "" {
-			continue
-		}
-		if err := parseLine(string(line), ps); err != nil {
-			return err
-		}
+func newFileParser(tree *ast.AstTree, fileName string, r io.Reader) (*fileParser, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	tokens, lexErrs := tokenize(string(src))
+	return &fileParser{tree: tree, fileName: fileName, tokens: tokens, errs: lexErrs}, nil
+}
+
+// returns the errors of the file as ErrorList, nil if there are no errors
+func (p *fileParser) err() error {
+	if len(p.errs) != 0 {
+		return p.errs
 	}
 	return nil
 }
 
-func beautifyLine(line string) string {
-	// removing comments
-	commentPos := strings.Index(string(line), "//")
-	if commentPos != -1 {
-		line = line[commentPos:]
+func (p *fileParser) position(t token) ast.Position {
+	return ast.Position{File: p.fileName, Line: t.line, Column: t.column}
+}
+
+func (p *fileParser) report(err error) {
+	p.errs = append(p.errs, err)
+}
+
+func (p *fileParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *fileParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
 	}
-	//removing extra tabulations in beginning and ending
-	line = strings.Trim(line, " \t")
-	return line
+	return t
 }
 
-var parserFuncs = map[LineParserStateId]LineParserFunc{
-	lpStateReadingSyntaxVer:   readSyntaxVersion,
-	lpStateReadingPackageName: readPackageName,
-	lpStateReadingStructName:  readStructName,
-	lpStateReadingStruct:      readStruct,
+func (p *fileParser) peekIsKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && t.text == keyword
 }
 
-// all the methods have the same signature, return value is the new state of parser
-func parseLine(line string, ps *LineParserState) error {
-	var err error
-	ps.stateId, err = parserFuncs[ps.stateId](line, ps)
-	if err != nil {
-		return err
+// the unexpected token is not consumed, so the parser can recover on it
+func (p *fileParser) expect(kind tokenKind) (token, error) {
+	t := p.peek()
+	if t.kind != kind {
+		return t, newSyntaxError(CodeUnexpectedToken, t.line, t.column, fmt.Sprintf("expected: %s, but got: %s", kind, t))
 	}
-	ps.lineNumber++
-	return nil
+	return p.next(), nil
 }
 
-type LineParserStateConflictErr struct {
-	expectedState LineParserStateId
-	gotState      LineParserStateId
+// the structs can not be added without the package,
+// so the errors in the file header stop the parsing of the file
+func (p *fileParser) parseFile() {
+	if err := p.parseSyntaxVersion(); err != nil {
+		p.report(err)
+		return
+	}
+	p.fileNode = p.tree.AddFile(p.fileName)
+	if err := p.parseImports(); err != nil {
+		p.report(err)
+		return
+	}
+	if err := p.parsePackageName(); err != nil {
+		p.report(err)
+		return
+	}
+	p.parseOptions()
+	for p.peek().kind != tokEOF {
+		parseDeclaration := p.parseStruct
+		if p.peekIsKeyword("enum") {
+			parseDeclaration = p.parseEnum
+		}
+		if err := parseDeclaration(); err != nil {
+			p.report(err)
+			p.skipToNextDeclaration()
+		}
+	}
 }
 
-func newLineParserStateConflictErr(
-	expected LineParserStateId,
-	got LineParserStateId) *LineParserStateConflictErr {
-	return &LineParserStateConflictErr{expected, got}
+// skips the tokens up to the next 'struct' or 'enum' keyword outside of the braces
+func (p *fileParser) skipToNextDeclaration() {
+	depth := 0
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokEOF:
+			return
+		case t.kind == tokLBrace:
+			depth++
+		case t.kind == tokRBrace:
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0 && t.kind == tokIdent && (t.text == "struct" || t.text == "enum"):
+			return
+		}
+		p.next()
+	}
 }
 
-func (lpsce *LineParserStateConflictErr) Error() string {
-	stateMessages := map[LineParserStateId]string{
-		lpStateReadingSyntaxVer:   "syntax version declaration",
-		lpStateReadingPackageName: "package declaration",
-		lpStateReadingStructName:  "struct declaration",
-		lpStateReadingStruct:      "struct field declaration",
+// skips the rest of the incorrect field declaration, it ends with ';',
+// the end of the line the error was found at or the end of the struct
+func (p *fileParser) skipToNextField(errLine int) {
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokEOF, t.kind == tokRBrace, t.line > errLine:
+			return
+		case t.kind == tokSemicolon:
+			p.next()
+			return
+		}
+		p.next()
 	}
-	return fmt.Sprintf(
-		"expected: %s, but got: %s",
-		stateMessages[lpsce.expectedState],
-		stateMessages[lpsce.gotState],
-	)
+}
+
+// the line of the current token is used when the error has no position
+func (p *fileParser) errorLine(err error) int {
+	var syntaxErr interface{ Line() int }
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Line()
+	}
+	return p.peek().line
+}
+
+// tells if the next tokens start the struct or enum declaration,
+// it is used to detect the missing closing brace of the previous one
+func (p *fileParser) peekIsDeclaration() bool {
+	if !(p.peekIsKeyword("struct") || p.peekIsKeyword("enum")) || p.pos+2 >= len(p.tokens) {
+		return false
+	}
+	next := p.tokens[p.pos+2].kind
+	return p.tokens[p.pos+1].kind == tokIdent && (next == tokLBrace || next == tokColon)
 }
 
 type ExpectedSyntaxErr struct {
 	SyntaxErr
 }
 
-func newExpectedSyntaxErr(line int, got string) (ese *ExpectedSyntaxErr) {
+func newExpectedSyntaxErr(line int, column int, got string) *ExpectedSyntaxErr {
+	ese := new(ExpectedSyntaxErr)
+	ese.code = CodeExpectedSyntaxKw
 	ese.line = line
-	ese.column = 0
+	ese.column = column
 	ese.description = fmt.Sprintf("expected: 'syntax' keyword, got: %s", got)
 	return ese
 }
@@ -121,47 +224,58 @@ type IncorrectSyntaxVerErr struct {
 	SyntaxErr
 }
 
-func newIncorrectSyntaxVerErr(line int, got string) (isve *IncorrectSyntaxVerErr) {
+func newIncorrectSyntaxVerErr(line int, column int, got string) *IncorrectSyntaxVerErr {
+	isve := new(IncorrectSyntaxVerErr)
+	isve.code = CodeIncorrectSyntaxVer
 	isve.line = line
-	isve.column = len("syntax") + 1
+	isve.column = column
 	isve.description = fmt.Sprintf("incorrect syntax version specified: %s", got)
 	return isve
 }
 
-func readSyntaxVersion(line string, ps *LineParserState) (LineParserStateId, error) {
-	if ps.stateId != lpStateReadingSyntaxVer {
-		return lpStateUndefined, newLineParserStateConflictErr(lpStateReadingSyntaxVer, ps.stateId)
-	}
-	if !strings.HasPrefix(line, "syntax") {
-		return lpStateUndefined, newExpectedSyntaxErr(ps.lineNumber, strings.Split(line, " ")[0])
-	}
-	versionOffset := len("syntax")
-	for helpers.EqualsAny(line[versionOffset], ' ', '\t') && versionOffset < len(line) {
-		versionOffset++
-	}
-	if versionOffset == len(line) {
-		return lpStateUndefined, newExpectedSyntaxErr(ps.lineNumber, "end of line")
+type SyntaxVerMismatchErr struct {
+	SyntaxErr
+}
+
+func newSyntaxVerMismatchErr(line int, column int, got string, expected string) *SyntaxVerMismatchErr {
+	svme := new(SyntaxVerMismatchErr)
+	svme.code = CodeSyntaxVerMismatch
+	svme.line = line
+	svme.column = column
+	svme.description = fmt.Sprintf("syntax version %s differs from version %s of the other files", got, expected)
+	return svme
+}
+
+func (p *fileParser) parseSyntaxVersion() error {
+	t := p.next()
+	if t.kind != tokIdent || t.text != "syntax" {
+		return newExpectedSyntaxErr(t.line, t.column, t.String())
 	}
-	syntaxVer := line[versionOffset:]
-	syntaxVerCorrect, err := helpers.MatchString(`[0-9]+.[0-9]+.[0-9]+`, syntaxVer)
-	if err != nil {
-		log.Fatal("Debug: ", err)
+	verToken := p.next()
+	syntaxVer := verToken.text
+	if verToken.kind != tokNumber || !syntaxVerPattern.MatchString(syntaxVer) {
+		return newIncorrectSyntaxVerErr(verToken.line, verToken.column, verToken.String())
 	}
-	if !syntaxVerCorrect {
-		return lpStateUndefined, newIncorrectSyntaxVerErr(ps.lineNumber, syntaxVer)
+	p.syntaxVerPos = p.position(verToken)
+	// the first parsed file sets the version of the tree
+	root := p.tree.GetRoot()
+	if treeVer := root.GetSyntaxVer(); treeVer == "" {
+		root.SetSyntaxVer(syntaxVer)
+	} else if treeVer != syntaxVer {
+		return newSyntaxVerMismatchErr(verToken.line, verToken.column, syntaxVer, treeVer)
 	}
-	ast.InitAstTree(syntaxVer)
-	return lpStateReadingPackageName, nil
+	return nil
 }
 
 type ExpectedPackageKwErr struct {
 	SyntaxErr
 }
 
-func newExpectedPackageKwErr(line int, got string) *ExpectedPackageKwErr {
+func newExpectedPackageKwErr(line int, column int, got string) *ExpectedPackageKwErr {
 	epke := new(ExpectedPackageKwErr)
+	epke.code = CodeExpectedPackageKw
 	epke.line = line
-	epke.column = 0
+	epke.column = column
 	epke.description = fmt.Sprintf("expected 'package' keyword, got: %s", got)
 	return epke
 }
@@ -170,46 +284,40 @@ type IncorrectPackageNameErr struct {
 	SyntaxErr
 }
 
-func newIncorrectPackageNameErr(line int, got string) *IncorrectPackageNameErr {
+func newIncorrectPackageNameErr(line int, column int, got string) *IncorrectPackageNameErr {
 	ipne := new(IncorrectPackageNameErr)
+	ipne.code = CodeIncorrectPackageName
 	ipne.line = line
-	ipne.column = len("package")
+	ipne.column = column
 	ipne.description = fmt.Sprintf("incorrect format of package name: %s", got)
 	return ipne
 }
 
-func readPackageName(line string, ps *LineParserState) (LineParserStateId, error) {
-	if ps.stateId != lpStateReadingPackageName {
-		return lpStateUndefined, newLineParserStateConflictErr(lpStateReadingPackageName, ps.stateId)
-	}
-	if !strings.HasPrefix(line, "package") {
-		return lpStateUndefined, newExpectedPackageKwErr(ps.lineNumber, strings.Split(line, " ")[0])
-	}
-	packageNameOffset := len("package")
-	for helpers.EqualsAny(line[packageNameOffset], ' ', '\t') {
-		packageNameOffset++
-	}
-	packageName := line[packageNameOffset:]
-	packageNameCorrect, err := helpers.MatchString(`[A-Za-z][A-Za-z0-9_]*`, packageName)
-	if err != nil {
-		helpers.PrintError("debug: incorrect regular expression at readPackageName")
+func (p *fileParser) parsePackageName() error {
+	t := p.next()
+	if t.kind != tokIdent || t.text != "package" {
+		return newExpectedPackageKwErr(t.line, t.column, t.String())
 	}
-	if !packageNameCorrect {
-		return lpStateUndefined, newIncorrectPackageNameErr(ps.lineNumber, packageName)
+	nameToken := p.next()
+	if nameToken.kind != tokIdent || !NamePattern.MatchString(nameToken.text) {
+		return newIncorrectPackageNameErr(nameToken.line, nameToken.column, nameToken.String())
 	}
-	ps.currentPackageNode, _ = ast.AddPackage(packageName)
-	return lpStateReadingStructName, nil
+	p.currentPackageNode, _ = p.tree.AddPackage(nameToken.text)
+	p.fileNode.SetPackageName(nameToken.text)
+	p.fileNode.SetPackagePosition(p.position(nameToken))
+	return nil
 }
 
 type ExpectedStructKwErr struct {
 	SyntaxErr
 }
 
-func newExpectedStructKwErr(line int, got string) *ExpectedStructKwErr {
+func newExpectedStructKwErr(line int, column int, got string) *ExpectedStructKwErr {
 	eske := new(ExpectedStructKwErr)
+	eske.code = CodeExpectedStructKw
 	eske.line = line
-	eske.column = 0
-	eske.description = fmt.Sprintf("expected 'package' keyword, got: %s", got)
+	eske.column = column
+	eske.description = fmt.Sprintf("expected 'struct' or 'enum' keyword, got: %s", got)
 	return eske
 }
 
@@ -219,18 +327,33 @@ type ExpectedOpeningCurlyBraceErr struct {
 
 func newExpectedOpeningCurlyBraceErr(line int, column int) *ExpectedOpeningCurlyBraceErr {
 	eocbe := new(ExpectedOpeningCurlyBraceErr)
+	eocbe.code = CodeExpectedOpeningCurlyBrace
 	eocbe.line = line
 	eocbe.column = column
 	eocbe.description = "expected opening curly brace"
 	return eocbe
 }
 
+type ExpectedClosingCurlyBraceErr struct {
+	SyntaxErr
+}
+
+func newExpectedClosingCurlyBraceErr(line int, column int, got string) *ExpectedClosingCurlyBraceErr {
+	eccbe := new(ExpectedClosingCurlyBraceErr)
+	eccbe.code = CodeExpectedClosingCurlyBrace
+	eccbe.line = line
+	eccbe.column = column
+	eccbe.description = fmt.Sprintf("expected closing curly brace, but got: %s", got)
+	return eccbe
+}
+
 type NoStructNameErr struct {
 	SyntaxErr
 }
 
 func newNoStructNameErr(line int, column int) *NoStructNameErr {
 	nsne := new(NoStructNameErr)
+	nsne.code = CodeNoStructName
 	nsne.line = line
 	nsne.column = column
 	nsne.description = "expected struct name"
@@ -243,6 +366,7 @@ type NoSuchPackageErr struct {
 
 func newNoSuchPackageErr(line int, column int, packageName string) *NoSuchPackageErr {
 	nspe := new(NoSuchPackageErr)
+	nspe.code = CodeNoSuchPackage
 	nspe.line = line
 	nspe.column = column
 	nspe.description = fmt.Sprintf("no such package: %s", packageName)
@@ -253,119 +377,284 @@ type StructAlreadyExistsErr struct {
 	SyntaxErr
 }
 
-func newStructAlreadyExistsErr(line int, column int, structName string) *StructAlreadyExistsErr {
+func newStructAlreadyExistsErr(line int, column int, structName string, declaredAt ast.Position) *StructAlreadyExistsErr {
 	saee := new(StructAlreadyExistsErr)
+	saee.code = CodeStructAlreadyExists
 	saee.line = line
 	saee.column = column
-	saee.description = fmt.Sprintf("struct already exists: %s", structName)
+	saee.description = fmt.Sprintf("struct already exists: %s, declared at %s", structName, declaredAt)
 	return saee
 }
 
-func readStructName(line string, ps *LineParserState) (LineParserStateId, error) {
-	if ps.stateId != lpStateReadingStructName {
-		return lpStateUndefined, newLineParserStateConflictErr(lpStateReadingStructName, ps.stateId)
-	}
-	if !strings.HasPrefix(line, "struct") {
-		return lpStateUndefined, newExpectedStructKwErr(ps.lineNumber, strings.Split(line, " ")[0])
+func (p *fileParser) parseStruct() error {
+	t := p.next()
+	if t.kind != tokIdent || t.text != "struct" {
+		return newExpectedStructKwErr(t.line, t.column, t.String())
 	}
-	idx := len("struct")
-	for idx < len(line) && helpers.EqualsAny(line[idx], ' ', '\t') {
-		idx++
+	nameToken := p.next()
+	if nameToken.kind != tokIdent {
+		return newNoStructNameErr(nameToken.line, nameToken.column)
 	}
-	var structNameBuff strings.Builder
-	for idx < len(line) && helpers.IsAllowedStructChar(line[idx]) {
-		structNameBuff.WriteByte(line[idx])
-		idx++
+	if !NamePattern.MatchString(nameToken.text) {
+		return newSyntaxError(CodeIncorrectStructName, nameToken.line, nameToken.column, fmt.Sprintf("incorrect name of struct: %s", nameToken.text))
 	}
-	for idx < len(line) && helpers.EqualsAny(line[idx], ' ', '\t') {
-		idx++
-	}
-	isCorrectStructName, err := helpers.MatchString(`[A-za-z][A-za-z0-9_]*`, structNameBuff.String())
-	if err != nil {
-		helpers.PrintError("debug: incorrect regex at readStructName")
+	if brace := p.peek(); brace.kind != tokLBrace {
+		return newExpectedOpeningCurlyBraceErr(brace.line, brace.column)
 	}
-	if !isCorrectStructName {
-		return lpStateUndefined, newSyntaxError(ps.lineNumber, len("struct "), fmt.Sprintf("incorrect name of struct: %s", structNameBuff.String()))
-	}
-	if idx == len(line) || line[idx] != '{' {
-		return lpStateUndefined, newExpectedOpeningCurlyBraceErr(ps.lineNumber, idx)
-	}
-	structName := structNameBuff.String()
-	if structName == "" {
-		return lpStateUndefined, newNoStructNameErr(ps.lineNumber, idx)
-	}
-	packageName := ps.currentPackageNode.GetName()
-	ps.currentStructNode, err = ast.AddStruct(packageName, structName)
+
+	packageName := p.currentPackageNode.GetName()
+	structNode, err := p.tree.DeclareStruct(packageName, nameToken.text, p.position(nameToken))
 	switch err {
 	case nil:
-		break
+		p.currentStructNode = structNode
+		p.untaggedFields = p.untaggedFields[:0]
 	case ast.ErrNoSuchPackage:
-		return lpStateUndefined, newNoSuchPackageErr(ps.lineNumber, idx, packageName)
+		err = newNoSuchPackageErr(nameToken.line, nameToken.column, packageName)
+	case ast.ErrStructAlreadyExists:
+		err = newStructAlreadyExistsErr(nameToken.line, nameToken.column, nameToken.text, structNode.GetPosition())
+	case ast.ErrEnumAlreadyExists:
+		enumNode, _ := p.tree.GetEnumNode(packageName, nameToken.text)
+		err = newEnumAlreadyExistsErr(nameToken.line, nameToken.column, nameToken.text, enumNode.GetPosition())
 	default:
-		helpers.PrintError(
-			fmt.Sprintf(
-				"an error occured: %s",
-				err.Error(),
-			),
-		)
+		err = newSyntaxError(CodeSyntax, nameToken.line, nameToken.column, err.Error())
 	}
-	return lpStateReadingStruct, nil
-}
+	if err != nil {
+		// the opening brace is not consumed yet,
+		// so the whole body of the struct is skipped on recovery
+		return err
+	}
+	p.next()
 
-func readStruct(line string, ps *LineParserState) (LineParserStateId, error) {
-	if ps.stateId != lpStateReadingStruct {
-		return lpStateUndefined, newLineParserStateConflictErr(lpStateReadingStruct, ps.stateId)
+	for p.peek().kind != tokRBrace {
+		if t := p.peek(); t.kind == tokEOF || p.peekIsDeclaration() {
+			return newExpectedClosingCurlyBraceErr(t.line, t.column, t.String())
+		}
+		parseField := p.parseFieldDeclaration
+		if p.peekIsOneof() {
+			parseField = p.parseOneof
+		} else if p.peekIsReserved() {
+			parseField = p.parseReserved
+		}
+		if err := parseField(); err != nil {
+			p.report(err)
+			p.skipToNextField(p.errorLine(err))
+		}
 	}
-	if line == "}" {
-		return lpStateReadingStructName, nil
+	structNode.SetEndPosition(p.position(p.next()))
+	p.checkFieldTags(structNode)
+	return nil
+}
+
+type FieldAlreadyExistsErr struct {
+	SyntaxErr
+}
+
+func newFieldAlreadyExistsErr(line int, column int, fieldName string) *FieldAlreadyExistsErr {
+	faee := new(FieldAlreadyExistsErr)
+	faee.code = CodeFieldAlreadyExists
+	faee.line = line
+	faee.column = column
+	faee.description = fmt.Sprintf("field already exists: %s", fieldName)
+	return faee
+}
+
+func (p *fileParser) parseFieldDeclaration() error {
+	isOptional := false
+	if p.peekIsKeyword("optional") {
+		isOptional = true
+		p.next()
 	}
-	declData, err := parseFieldDeclarations(line, ps.lineNumber)
+	typeToken := p.peek()
+	p.typeReferences = p.typeReferences[:0]
+	typeName, err := p.parseType()
 	if err != nil {
-		return lpStateUndefined, err
+		return err
 	}
 
-	// parse the type of fields
-	packageName := ps.currentPackageNode.GetName()
-	structName := ps.currentStructNode.GetName()
-	for _, f := range declData.Fields {
-		fieldSmeType, err := ast.TypeFromString(
+	packageName := p.currentPackageNode.GetName()
+	structName := p.currentStructNode.GetName()
+	for {
+		nameToken := p.peek()
+		if nameToken.kind != tokIdent {
+			return newSyntaxError(CodeUnexpectedToken, nameToken.line, nameToken.column, fmt.Sprintf("expected field name, but got: %s", nameToken))
+		}
+		p.next()
+		var (
+			tag             int
+			tagToken        token
+			valueToken      token
+			hasDefaultValue bool
+			defaultValue    interface{}
+		)
+		if p.peekIsTag() {
+			tag, tagToken, err = p.parseTag()
+			if err != nil {
+				return err
+			}
+		}
+		if p.peek().kind == tokAssign {
+			p.next()
+			valueToken = p.peek()
+			hasDefaultValue = true
+			defaultValue, err = p.parseDefaultValue(typeName, isOptional)
+			if err != nil {
+				return err
+			}
+		}
+
+		fieldSmeType, err := p.tree.TypeFromString(
 			packageName,
-			declData.FieldsType,
-			declData.IsOptional,
-			f.HasDefaultValue,
-			f.DefaultValue,
+			typeName,
+			isOptional,
+			hasDefaultValue,
+			defaultValue,
 		)
-		if err != nil {
-			return lpStateUndefined, err
+		if errors.Is(err, ast.ErrIncorrectDefaultValue) {
+			return newSyntaxError(CodeIncorrectDefaultValue, valueToken.line, valueToken.column, err.Error())
 		}
-		_, err = ast.AddStructField(packageName, structName, f.Name, fieldSmeType)
 		if err != nil {
-			return lpStateUndefined, err
+			return newSyntaxError(CodeIncorrectType, typeToken.line, typeToken.column, err.Error())
+		}
+		fieldNode, err := p.tree.AddStructField(packageName, structName, nameToken.text, fieldSmeType)
+		switch err {
+		case nil:
+			fieldNode.SetPosition(p.position(nameToken))
+			for _, ref := range p.typeReferences {
+				fieldNode.SetReferencePosition(ref.name, p.position(ref.token))
+			}
+			if hasDefaultValue && defaultValue == nil {
+				fieldNode.SetNullDefault()
+			}
+		case ast.ErrFieldAlreadyExists:
+			return newFieldAlreadyExistsErr(nameToken.line, nameToken.column, nameToken.text)
+		case ast.ErrFieldNameReserved:
+			return p.fieldNameReservedErr(nameToken)
+		default:
+			return newSyntaxError(CodeSyntax, nameToken.line, nameToken.column, err.Error())
+		}
+		if tag == 0 {
+			p.untaggedFields = append(p.untaggedFields, nameToken)
+		} else if err := p.setFieldTag(nameToken.text, tag, tagToken); err != nil {
+			return err
+		}
+
+		if p.peek().kind != tokComma {
+			break
 		}
+		p.next()
 	}
-	return lpStateReadingStruct, nil
+	if p.peek().kind == tokSemicolon {
+		p.next()
+	}
+	return nil
 }
 
-type fieldData struct {
-	Name            string
-	DefaultValue    interface{}
-	HasDefaultValue bool
+// returns the type name in the form accepted by ast.TypeFromString
+func (p *fileParser) parseType() (string, error) {
+	t := p.peek()
+	if t.kind != tokIdent {
+		return "", newSyntaxError(CodeUnexpectedToken, t.line, t.column, fmt.Sprintf("expected type name, but got: %s", t))
+	}
+	p.next()
+	switch {
+	case t.text == "list" && p.peek().kind == tokLBracket:
+		p.next()
+		valueType, err := p.parseType()
+		if err != nil {
+			return "", err
+		}
+		if _, err := p.expect(tokRBracket); err != nil {
+			return "", err
+		}
+		return "list[" + valueType + "]", nil
+	case t.text == "map" && p.peek().kind == tokLBracket:
+		p.next()
+		keyType, err := p.parseType()
+		if err != nil {
+			return "", err
+		}
+		if _, err := p.expect(tokComma); err != nil {
+			return "", err
+		}
+		valueType, err := p.parseType()
+		if err != nil {
+			return "", err
+		}
+		if _, err := p.expect(tokRBracket); err != nil {
+			return "", err
+		}
+		return "map[" + keyType + "," + valueType + "]", nil
+	}
+	typeName := t.text
+	if p.peek().kind == tokDot {
+		p.next()
+		structToken, err := p.expect(tokIdent)
+		if err != nil {
+			return "", err
+		}
+		typeName += "." + structToken.text
+	}
+	if !ast.IsPrimitiveTypeName(typeName) {
+		qualifiedName := typeName
+		if t.text == typeName {
+			qualifiedName = p.currentPackageNode.GetName() + "." + typeName
+		}
+		p.typeReferences = append(p.typeReferences, typeReference{name: qualifiedName, token: t})
+	}
+	return typeName, nil
 }
 
-type fieldDeclData struct {
-	IsOptional bool
-	FieldsType string
-	Fields     []fieldData
+// returns nil if the default value is null
+func (p *fileParser) parseDefaultValue(typeName string, isOptional bool) (interface{}, error) {
+	t := p.peek()
+	if t.kind == tokIdent && t.text == "null" {
+		p.next()
+		if !isOptional {
+			return nil, newSyntaxError(CodeIncorrectDefaultValue, t.line, t.column, "non-optional types cannot hold null as default value")
+		}
+		return nil, nil
+	}
+	if typeName == "string" {
+		if t.kind != tokString {
+			return nil, newSyntaxError(CodeIncorrectDefaultValue, t.line, t.column, fmt.Sprintf("expected opening quotes in string default value declaration, but got: %s", t))
+		}
+		p.next()
+		return t.text, nil
+	}
+	switch t.kind {
+	case tokChar, tokNumber, tokIdent:
+		p.next()
+		return t.text, nil
+	}
+	return nil, newSyntaxError(CodeIncorrectDefaultValue, t.line, t.column, fmt.Sprintf("expected default value declaration for value, but got: %s", t))
 }
 
 type SyntaxErr struct {
+	code        string
 	line        int
 	column      int
 	description string
 }
 
-func newSyntaxError(line int, column int, desc string) *SyntaxErr {
-	return &SyntaxErr{line, column, desc}
+func newSyntaxError(code string, line int, column int, desc string) *SyntaxErr {
+	return &SyntaxErr{code, line, column, desc}
+}
+
+// Code is the stable identifier of the error kind, see codes.go
+func (se *SyntaxErr) Code() string {
+	return se.code
+}
+
+func (se *SyntaxErr) Line() int {
+	return se.line
+}
+
+func (se *SyntaxErr) Column() int {
+	return se.column
+}
+
+func (se *SyntaxErr) Description() string {
+	return se.description
 }
 
 func (se *SyntaxErr) Error() string {
@@ -376,145 +665,3 @@ func (se *SyntaxErr) Error() string {
 		se.description,
 	)
 }
-
-func parseFieldDeclarations(line string, lineNumber int) (result fieldDeclData, err error) {
-	const (
-		stateReadingTypeName = iota
-		stateReadingFieldName
-		stateReadingDefaultValue
-	)
-	idx := 0
-	state := stateReadingTypeName
-	if strings.HasPrefix(line, "optional") {
-		result.IsOptional = true
-		idx = len("optional")
-	}
-	var (
-		buffer       strings.Builder
-		pendingField fieldData
-	)
-	for idx < len(line) {
-		switch state {
-		case stateReadingTypeName:
-			for idx < len(line) && helpers.EqualsAny(line[idx], ' ', '\t') {
-				idx++
-			}
-			for idx < len(line) && !helpers.EqualsAny(line[idx], ' ', '\t') {
-				buffer.WriteByte(line[idx])
-				idx++
-			}
-			if strings.HasPrefix((buffer.String()), "map[") {
-				for idx < len(line) && line[idx] != ']' {
-					buffer.WriteByte(line[idx])
-					idx++
-				}
-				if idx == len(line) {
-					return fieldDeclData{}, newSyntaxError(lineNumber, idx, "expected closing bracket, but got: end of line")
-				}
-				buffer.WriteByte(']')
-				typeName := strings.ReplaceAll(buffer.String(), " ", "")
-				m, err := helpers.MatchString(`map\[.*,.*\]`, typeName)
-				if err != nil {
-					helpers.PrintError("debug: incorrect regex at parseFieldDeclarations")
-				}
-				if !m {
-					return fieldDeclData{}, newSyntaxError(lineNumber, idx-len(typeName), "incorrect declaration of a map")
-				}
-				buffer.Reset()
-				buffer.WriteString(typeName)
-			} else if !strings.HasPrefix(buffer.String(), "list[") && !ast.IsPrimitiveTypeName(buffer.String()) {
-				correctTypeName, err := helpers.MatchString(`[A-Za-z]?[A-Za-z0-9_](.[A-Za-z]?[A-Za-z0-9_])?`, buffer.String())
-				if err != nil {
-					helpers.PrintError("debug: unable to compile regexp at parseFieldDeclarations")
-				}
-				if !correctTypeName {
-					return fieldDeclData{}, newSyntaxError(lineNumber, 0, fmt.Sprintf("incorrect type name: %s", buffer.String()))
-				}
-			}
-			result.FieldsType = buffer.String()
-			state = stateReadingFieldName
-		case stateReadingFieldName:
-			for helpers.EqualsAny(line[idx], ' ', '\t') {
-				idx++
-			}
-			for idx < len(line) && !helpers.EqualsAny(line[idx], ' ', '\t', ',', '=') {
-				buffer.WriteByte(line[idx])
-				idx++
-			}
-			pendingField.Name = buffer.String()
-			if pendingField.Name[0] >= '0' && pendingField.Name[0] <= '9' {
-				return fieldDeclData{},
-					newSyntaxError(
-						lineNumber,
-						idx-len(pendingField.Name),
-						"field name can not start with a number",
-					)
-			}
-			for idx < len(line) && !helpers.EqualsAny(line[idx], '=', ',') {
-				idx++
-			}
-			if idx < len(line) && line[idx] == '=' {
-				state = stateReadingDefaultValue
-			} else {
-				idx++
-				result.Fields = append(result.Fields, pendingField)
-				pendingField = fieldData{}
-			}
-		case stateReadingDefaultValue:
-			for helpers.EqualsAny(line[idx], ' ', '\t') {
-				idx++
-			}
-			if result.FieldsType == "string" {
-				for idx < len(line) && !helpers.EqualsAny(line[idx], '"') {
-					idx++
-				}
-				if idx == len(line) {
-					return fieldDeclData{}, newSyntaxError(lineNumber, idx, "expected opening quotes in string default value declaration, but got: end of line")
-				}
-				idx++
-				for idx < len(line) && !helpers.EqualsAny(line[idx], '"') {
-					buffer.WriteByte(line[idx])
-					idx++
-				}
-				if idx == len(line) {
-					return fieldDeclData{},
-						newSyntaxError(
-							lineNumber,
-							idx,
-							"expected closing quotes, but got: end of line",
-						)
-				}
-				idx += 2
-			} else {
-				for helpers.EqualsAny(line[idx], ' ', '\t') {
-					idx++
-				}
-				for idx < len(line) && !helpers.EqualsAny(line[idx], ' ', '\t', ',') {
-					buffer.WriteByte(line[idx])
-					idx++
-				}
-				for idx < len(line) && !helpers.EqualsAny(line[idx], ',') {
-					idx++
-				}
-				idx++
-				if buffer.String() == "" {
-					return fieldDeclData{}, newSyntaxError(lineNumber, idx, "expected default value declaration for value, but got: end of line")
-				}
-			}
-			if !result.IsOptional && buffer.String() == "null" {
-				return fieldDeclData{}, newSyntaxError(lineNumber, idx, "non-optional types cannot hold null as default value")
-			}
-			pendingField.HasDefaultValue = true
-			if buffer.String() == "null" {
-				pendingField.DefaultValue = nil
-			} else {
-				pendingField.DefaultValue = buffer.String()
-			}
-			result.Fields = append(result.Fields, pendingField)
-			pendingField = fieldData{}
-			state = stateReadingFieldName
-		}
-		buffer.Reset()
-	}
-	return result, nil
-}
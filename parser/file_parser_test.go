@@ -0,0 +1,201 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Ghytro/sme/ast"
+)
+
+type wantDiagnostic struct {
+	code   string
+	line   int
+	column int
+}
+
+func parseSource(t *testing.T, source string) []Diagnostic {
+	t.Helper()
+	_, diagnostics, err := ParseReader("test.sme", strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("ParseReader: %s", err)
+	}
+	return diagnostics
+}
+
+func checkDiagnostics(t *testing.T, got []Diagnostic, want []wantDiagnostic) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d diagnostics, want %d: %v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Code != w.code || g.Line != w.line || g.Column != w.column {
+			t.Errorf("diagnostic %d is %s at %d:%d, want %s at %d:%d: %s", i, g.Code, g.Line, g.Column, w.code, w.line, w.column, g.Message)
+		}
+	}
+}
+
+func TestParserRecovery(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []wantDiagnostic
+	}{
+		{
+			name: "errors in several structs",
+			source: `syntax 0.0.1
+
+package rc
+
+struct A {
+    int32 x
+    int32 x
+}
+
+struct 1B {
+    int32 z
+}
+
+struct D {
+    list[ int32 v
+}
+`,
+			want: []wantDiagnostic{
+				{CodeFieldAlreadyExists, 7, 11},
+				{CodeNoStructName, 10, 8},
+				{CodeUnexpectedToken, 15, 17},
+			},
+		},
+		{
+			name: "error in the field does not hide the next fields",
+			source: `syntax 0.0.1
+
+package rc
+
+struct A {
+    int32 x = abc
+    int32 x
+    strin y
+}
+`,
+			want: []wantDiagnostic{
+				{CodeIncorrectDefaultValue, 6, 15},
+				{CodeUndeclaredStruct, 8, 5},
+			},
+		},
+		{
+			name: "field names differing only in case",
+			source: `syntax 0.0.1
+
+package rc
+
+struct A {
+    int32 x
+    int32 X
+    oneof o {
+        string O
+    }
+}
+`,
+			want: []wantDiagnostic{
+				{CodeFieldAlreadyExists, 7, 11},
+				{CodeFieldAlreadyExists, 9, 16},
+			},
+		},
+		{
+			name: "missing closing brace",
+			source: `syntax 0.0.1
+
+package rc
+
+struct A {
+    int32 x
+`,
+			want: []wantDiagnostic{
+				{CodeExpectedClosingCurlyBrace, 7, 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkDiagnostics(t, parseSource(t, tt.source), tt.want)
+		})
+	}
+}
+
+func TestDefaultValueValidation(t *testing.T) {
+	tests := []struct {
+		field   string
+		wantErr bool
+	}{
+		{"int8 x = 127", false},
+		{"int8 x = -128", false},
+		{"int8 x = 1000", true},
+		{"int8 x = -129", true},
+		{"int16 x = 32768", true},
+		{"int32 x = 2147483647", false},
+		{"int64 x = -9223372036854775808", false},
+		{"uint8 x = 255", false},
+		{"uint8 x = 256", true},
+		{"uint8 x = -1", true},
+		{"uint16 x = 65536", true},
+		{"uint32 x = 4294967296", true},
+		{"uint64 x = 18446744073709551615", false},
+		{"int32 x = 1.5", true},
+		{"float x = 1.5", false},
+		{"float x = 1e39", true},
+		{"float x = abc", true},
+		{"double x = 1e39", false},
+		{"double x = inf", true},
+		{"double x = 1e400", true},
+		{"bool x = true", false},
+		{"bool x = 0", false},
+		{"bool x = maybe", true},
+		{"char x = 'a'", false},
+		{`string x = "abc"`, false},
+		{"optional int8 x = 200", true},
+		{"optional int8 x = null", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			source := "syntax 0.0.1\n\npackage dv\n\nstruct S {\n    " + tt.field + "\n}\n"
+			var want []wantDiagnostic
+			if tt.wantErr {
+				column := strings.Index(tt.field, "= ") + 7
+				want = []wantDiagnostic{{CodeIncorrectDefaultValue, 6, column}}
+			}
+			checkDiagnostics(t, parseSource(t, source), want)
+		})
+	}
+}
+
+func TestPackageNamesLikeParametricTypes(t *testing.T) {
+	tests := []struct {
+		packageName string
+		field       string
+		wantType    string
+	}{
+		{"maps", "A a", "maps.A"},
+		{"mapping", "list[A] a", "list[mapping.A]"},
+		{"lists", "map[int32, A] a", "map[int32, lists.A]"},
+		{"list", "list.A a", "list.A"},
+		{"map", "map[string, list[map.A]] a", "map[string, list[map.A]]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.packageName, func(t *testing.T) {
+			source := "syntax 0.0.1\n\npackage " + tt.packageName + "\n\nstruct A {\n    int32 x\n}\n\nstruct B {\n    " + tt.field + "\n}\n"
+			tree, diagnostics, err := ParseReader("test.sme", strings.NewReader(source))
+			if err != nil {
+				t.Fatalf("ParseReader: %s", err)
+			}
+			checkDiagnostics(t, diagnostics, nil)
+			s, err := tree.GetStructNode(tt.packageName, "B")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ast.TypeName(s.GetFields()[0].GetFieldType()); got != tt.wantType {
+				t.Errorf("field type is %s, want %s", got, tt.wantType)
+			}
+		})
+	}
+}
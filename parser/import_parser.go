@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Ghytro/sme/ast"
+)
+
+type ImportNotFoundErr struct {
+	SyntaxErr
+}
+
+func newImportNotFoundErr(line int, column int, path string) *ImportNotFoundErr {
+	infe := new(ImportNotFoundErr)
+	infe.code = CodeImportNotFound
+	infe.line = line
+	infe.column = column
+	infe.description = fmt.Sprintf("imported file is not found: %s", path)
+	return infe
+}
+
+type CircularImportErr struct {
+	SyntaxErr
+}
+
+func newCircularImportErr(line int, column int, chain []string) *CircularImportErr {
+	cie := new(CircularImportErr)
+	cie.code = CodeCircularImport
+	cie.line = line
+	cie.column = column
+	cie.description = fmt.Sprintf("circular import: %s", strings.Join(chain, " -> "))
+	return cie
+}
+
+type PackageNotImportedErr struct {
+	SyntaxErr
+}
+
+func newPackageNotImportedErr(line int, column int, packageName string) *PackageNotImportedErr {
+	pnie := new(PackageNotImportedErr)
+	pnie.code = CodePackageNotImported
+	pnie.line = line
+	pnie.column = column
+	pnie.description = fmt.Sprintf("package %s is not imported by this file", packageName)
+	return pnie
+}
+
+// the path of the imported file is relative to the directory of the
+// importing file, the imported files are loaded by Parse after this file
+func (p *fileParser) parseImports() error {
+	for p.peekIsKeyword("import") {
+		p.next()
+		pathToken := p.peek()
+		if pathToken.kind != tokString {
+			return newSyntaxError(CodeUnexpectedToken, pathToken.line, pathToken.column, fmt.Sprintf("expected path of the imported file, but got: %s", pathToken))
+		}
+		p.next()
+		fileName := filepath.FromSlash(pathToken.text)
+		if !filepath.IsAbs(fileName) {
+			fileName = filepath.Join(filepath.Dir(p.fileName), fileName)
+		}
+		ast.AddImport(p.fileNode, pathToken.text, filepath.Clean(fileName), p.position(pathToken))
+		if p.peek().kind == tokSemicolon {
+			p.next()
+		}
+	}
+	return nil
+}
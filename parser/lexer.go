@@ -0,0 +1,294 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF = tokenKind(iota)
+	tokIdent
+	tokNumber
+	tokString
+	tokChar
+	tokLBrace
+	tokRBrace
+	tokLBracket
+	tokRBracket
+	tokComma
+	tokDot
+	tokAssign
+	tokSemicolon
+	tokColon
+	tokAt
+)
+
+var tokenKindNames = map[tokenKind]string{
+	tokEOF:       "end of file",
+	tokIdent:     "identifier",
+	tokNumber:    "number",
+	tokString:    "string",
+	tokChar:      "character",
+	tokLBrace:    "'{'",
+	tokRBrace:    "'}'",
+	tokLBracket:  "'['",
+	tokRBracket:  "']'",
+	tokComma:     "','",
+	tokDot:       "'.'",
+	tokAssign:    "'='",
+	tokSemicolon: "';'",
+	tokColon:     "':'",
+	tokAt:        "'@'",
+}
+
+func (k tokenKind) String() string {
+	return tokenKindNames[k]
+}
+
+// line and column are counted from 1, column is counted in bytes
+type token struct {
+	kind   tokenKind
+	text   string
+	line   int
+	column int
+}
+
+// describes the token for the error messages
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return t.kind.String()
+	case tokString:
+		return fmt.Sprintf("%q", t.text)
+	case tokChar:
+		return fmt.Sprintf("'%s'", t.text)
+	}
+	return t.text
+}
+
+var punctuation = map[byte]tokenKind{
+	'{': tokLBrace,
+	'}': tokRBrace,
+	'[': tokLBracket,
+	']': tokRBracket,
+	',': tokComma,
+	'.': tokDot,
+	'=': tokAssign,
+	';': tokSemicolon,
+	':': tokColon,
+	'@': tokAt,
+}
+
+type lexer struct {
+	src      string
+	offset   int
+	line     int
+	column   int
+	comments []Comment
+}
+
+// Comment is the "//" comment of the sme file, the parser skips the
+// comments, so they are returned by Comments for the tools keeping them
+type Comment struct {
+	Line   int
+	Column int
+	// the text of the comment with "//", without the line break
+	Text string
+}
+
+// Comments returns the comments of the source in the order they appear,
+// the incorrect literals are skipped the same way tokenize does
+func Comments(src string) []Comment {
+	l := newLexer(src)
+	for {
+		if t, err := l.next(); err == nil && t.kind == tokEOF {
+			return l.comments
+		}
+	}
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src, line: 1, column: 1}
+}
+
+// splits the whole source into tokens, the last token is always tokEOF.
+// The lexer skips the incorrect characters and literals, so the parser
+// can go on and find the other errors, the skipped parts are reported in errs
+func tokenize(src string) (tokens []token, errs []error) {
+	l := newLexer(src)
+	for {
+		t, err := l.next()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		tokens = append(tokens, t)
+		if t.kind == tokEOF {
+			return tokens, errs
+		}
+	}
+}
+
+func (l *lexer) peekByte(ahead int) byte {
+	if l.offset+ahead < len(l.src) {
+		return l.src[l.offset+ahead]
+	}
+	return 0
+}
+
+func (l *lexer) advance() byte {
+	c := l.src[l.offset]
+	l.offset++
+	if c == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	return c
+}
+
+func (l *lexer) skipSpacesAndComments() {
+	for l.offset < len(l.src) {
+		c := l.peekByte(0)
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			l.advance()
+		case c == '/' && l.peekByte(1) == '/':
+			comment := Comment{Line: l.line, Column: l.column}
+			start := l.offset
+			l.skipLine()
+			comment.Text = strings.TrimRight(l.src[start:l.offset], " \t\r")
+			l.comments = append(l.comments, comment)
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) skipLine() {
+	for l.offset < len(l.src) && l.peekByte(0) != '\n' {
+		l.advance()
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipSpacesAndComments()
+	t := token{line: l.line, column: l.column}
+	if l.offset >= len(l.src) {
+		t.kind = tokEOF
+		return t, nil
+	}
+	c := l.peekByte(0)
+	switch {
+	case isIdentStart(c):
+		start := l.offset
+		for l.offset < len(l.src) && (isIdentStart(l.peekByte(0)) || isDigit(l.peekByte(0))) {
+			l.advance()
+		}
+		t.kind = tokIdent
+		t.text = l.src[start:l.offset]
+		return t, nil
+	case isDigit(c) || (c == '-' || c == '+') && (isDigit(l.peekByte(1)) || l.peekByte(1) == '.'):
+		t.kind = tokNumber
+		t.text = l.readNumber()
+		return t, nil
+	case c == '"':
+		text, err := l.readQuoted('"')
+		if err != nil {
+			return t, err
+		}
+		t.kind = tokString
+		t.text = text
+		return t, nil
+	case c == '\'':
+		text, err := l.readQuoted('\'')
+		if err != nil {
+			return t, err
+		}
+		if len(text) != 1 {
+			return t, newSyntaxError(CodeIncorrectToken, t.line, t.column, "character literal must contain exactly one character")
+		}
+		t.kind = tokChar
+		t.text = text
+		return t, nil
+	}
+	if kind, ok := punctuation[c]; ok {
+		l.advance()
+		t.kind = kind
+		t.text = string(c)
+		return t, nil
+	}
+	l.advance()
+	return t, newSyntaxError(CodeIncorrectToken, t.line, t.column, fmt.Sprintf("unexpected character: %q", c))
+}
+
+// reads the numbers like 12, -3, 1.5, 2e-3 and the versions like 0.0.1
+func (l *lexer) readNumber() string {
+	start := l.offset
+	if c := l.peekByte(0); c == '-' || c == '+' {
+		l.advance()
+	}
+	for l.offset < len(l.src) && (isDigit(l.peekByte(0)) || l.peekByte(0) == '.') {
+		l.advance()
+	}
+	if c := l.peekByte(0); c == 'e' || c == 'E' {
+		l.advance()
+		if c := l.peekByte(0); c == '-' || c == '+' {
+			l.advance()
+		}
+		for l.offset < len(l.src) && isDigit(l.peekByte(0)) {
+			l.advance()
+		}
+	}
+	return l.src[start:l.offset]
+}
+
+var escapedChars = map[byte]byte{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+}
+
+func (l *lexer) readQuoted(quote byte) (string, error) {
+	line, column := l.line, l.column
+	l.advance()
+	var b strings.Builder
+	for {
+		if l.offset >= len(l.src) || l.peekByte(0) == '\n' {
+			return "", newSyntaxError(CodeIncorrectToken, line, column, "expected closing quotes, but got: end of line")
+		}
+		c := l.advance()
+		if c == quote {
+			return b.String(), nil
+		}
+		if c == '\\' {
+			if l.offset >= len(l.src) {
+				continue
+			}
+			escaped, ok := escapedChars[l.peekByte(0)]
+			if !ok {
+				err := newSyntaxError(CodeIncorrectToken, l.line, l.column-1, fmt.Sprintf("unknown escape sequence: \\%c", l.peekByte(0)))
+				l.skipLine()
+				return "", err
+			}
+			l.advance()
+			c = escaped
+		}
+		b.WriteByte(c)
+	}
+}
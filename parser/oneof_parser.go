@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/Ghytro/sme/ast"
+)
+
+type IncorrectOneofMemberErr struct {
+	SyntaxErr
+}
+
+func newIncorrectOneofMemberErr(line int, column int, desc string) *IncorrectOneofMemberErr {
+	iome := new(IncorrectOneofMemberErr)
+	iome.code = CodeIncorrectOneofMember
+	iome.line = line
+	iome.column = column
+	iome.description = desc
+	return iome
+}
+
+type TooManyOneofMembersErr struct {
+	SyntaxErr
+}
+
+func newTooManyOneofMembersErr(line int, column int, oneofName string) *TooManyOneofMembersErr {
+	tmome := new(TooManyOneofMembersErr)
+	tmome.code = CodeTooManyOneofMembers
+	tmome.line = line
+	tmome.column = column
+	tmome.description = fmt.Sprintf("oneof %s can not have more than %d members", oneofName, ast.MaxOneofMembers)
+	return tmome
+}
+
+// 'oneof' is the keyword only when it is followed by the name and the
+// opening brace or the tag, so the structs named oneof can still be used as field types
+func (p *fileParser) peekIsOneof() bool {
+	if !p.peekIsKeyword("oneof") || p.pos+3 >= len(p.tokens) {
+		return false
+	}
+	next := p.tokens[p.pos+2].kind
+	return p.tokens[p.pos+1].kind == tokIdent && (next == tokLBrace || next == tokAssign && p.tokens[p.pos+3].kind == tokAt)
+}
+
+func (p *fileParser) parseOneof() error {
+	p.next()
+	nameToken := p.next()
+	var (
+		tag      int
+		tagToken token
+		err      error
+	)
+	hasTag := p.peekIsTag()
+	if hasTag {
+		if tag, tagToken, err = p.parseTag(); err != nil {
+			// the oneof is still parsed without the tag to report the errors of its members
+			p.report(err)
+		}
+	}
+	if _, err := p.expect(tokLBrace); err != nil {
+		return err
+	}
+
+	packageName := p.currentPackageNode.GetName()
+	structName := p.currentStructNode.GetName()
+	oneofNode, err := p.tree.AddOneof(packageName, structName, nameToken.text, p.position(nameToken))
+	switch err {
+	case nil:
+		if !hasTag {
+			p.untaggedFields = append(p.untaggedFields, nameToken)
+		} else if tag != 0 {
+			err = p.setFieldTag(nameToken.text, tag, tagToken)
+		}
+	case ast.ErrFieldAlreadyExists:
+		err = newFieldAlreadyExistsErr(nameToken.line, nameToken.column, nameToken.text)
+	case ast.ErrFieldNameReserved:
+		err = p.fieldNameReservedErr(nameToken)
+	default:
+		err = newSyntaxError(CodeSyntax, nameToken.line, nameToken.column, err.Error())
+	}
+	if err != nil {
+		// the members are still parsed to report their errors
+		p.report(err)
+	}
+
+	for p.peek().kind != tokRBrace {
+		if t := p.peek(); t.kind == tokEOF || p.peekIsDeclaration() {
+			return newExpectedClosingCurlyBraceErr(t.line, t.column, t.String())
+		}
+		if err := p.parseOneofMember(oneofNode); err != nil {
+			p.report(err)
+			p.skipToNextField(p.errorLine(err))
+		}
+	}
+	closingBrace := p.next()
+	if oneofNode != nil {
+		oneofNode.SetEndPosition(p.position(closingBrace))
+	}
+	if p.peek().kind == tokSemicolon {
+		p.next()
+	}
+	return nil
+}
+
+// the member is declared like the field with the single name,
+// it can not be optional and can not have the default value
+func (p *fileParser) parseOneofMember(oneofNode *ast.AstOneofNode) error {
+	if t := p.peek(); p.peekIsKeyword("optional") {
+		return newIncorrectOneofMemberErr(t.line, t.column, "oneof members can not be optional")
+	}
+	typeToken := p.peek()
+	p.typeReferences = p.typeReferences[:0]
+	typeName, err := p.parseType()
+	if err != nil {
+		return err
+	}
+	nameToken := p.peek()
+	if nameToken.kind != tokIdent {
+		return newSyntaxError(CodeUnexpectedToken, nameToken.line, nameToken.column, fmt.Sprintf("expected member name, but got: %s", nameToken))
+	}
+	p.next()
+	if t := p.peek(); t.kind == tokAssign {
+		return newIncorrectOneofMemberErr(t.line, t.column, "oneof members can not have default value")
+	}
+
+	memberType, err := p.tree.TypeFromString(p.currentPackageNode.GetName(), typeName, false, false, nil)
+	if err != nil {
+		return newSyntaxError(CodeIncorrectType, typeToken.line, typeToken.column, err.Error())
+	}
+	if oneofNode != nil {
+		memberNode, err := p.tree.AddOneofMember(oneofNode, nameToken.text, memberType)
+		switch err {
+		case nil:
+			memberNode.SetPosition(p.position(nameToken))
+			for _, ref := range p.typeReferences {
+				memberNode.SetReferencePosition(ref.name, p.position(ref.token))
+			}
+		case ast.ErrFieldAlreadyExists:
+			return newFieldAlreadyExistsErr(nameToken.line, nameToken.column, nameToken.text)
+		case ast.ErrFieldNameReserved:
+			return p.fieldNameReservedErr(nameToken)
+		case ast.ErrTooManyOneofMembers:
+			return newTooManyOneofMembersErr(nameToken.line, nameToken.column, oneofNode.GetName())
+		default:
+			return newSyntaxError(CodeSyntax, nameToken.line, nameToken.column, err.Error())
+		}
+	}
+
+	switch p.peek().kind {
+	case tokSemicolon, tokComma:
+		p.next()
+	}
+	return nil
+}
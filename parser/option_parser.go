@@ -0,0 +1,219 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Ghytro/sme/ast"
+)
+
+type UnknownOptionErr struct {
+	SyntaxErr
+}
+
+func newUnknownOptionErr(line int, column int, name string) *UnknownOptionErr {
+	uoe := new(UnknownOptionErr)
+	uoe.code = CodeUnknownOption
+	uoe.line = line
+	uoe.column = column
+	uoe.description = fmt.Sprintf("unknown option: %s, the allowed options are: %s", name, strings.Join(optionNames(), ", "))
+	return uoe
+}
+
+type IncorrectOptionValueErr struct {
+	SyntaxErr
+}
+
+func newIncorrectOptionValueErr(line int, column int, name string, value string, format string) *IncorrectOptionValueErr {
+	iove := new(IncorrectOptionValueErr)
+	iove.code = CodeIncorrectOptionValue
+	iove.line = line
+	iove.column = column
+	iove.description = fmt.Sprintf("incorrect value of option %s: %q, expected %s", name, value, format)
+	return iove
+}
+
+type OptionConflictErr struct {
+	SyntaxErr
+}
+
+func newOptionConflictErr(line int, column int, name string, value string, setAt ast.Position, setValue string) *OptionConflictErr {
+	oce := new(OptionConflictErr)
+	oce.code = CodeOptionConflict
+	oce.line = line
+	oce.column = column
+	oce.description = fmt.Sprintf("option %s = %q conflicts with %q set at %s", name, value, setValue, setAt)
+	return oce
+}
+
+// packageOption is the option of the code generated for the package of the
+// file, so the files of the same package setting the option must set it
+// to the same value, the files of the other packages may set the other one
+type packageOption struct {
+	name string
+	// the allowed values for the error message
+	format string
+	valid  func(value string) bool
+	set    func(packageNode *ast.AstPackageNode, value string)
+}
+
+var packageOptions = []packageOption{
+	{
+		"cpp_namespace", "C++ identifiers separated with '::', like \"acme::schemas\"",
+		func(v string) bool { return isQualifiedName(v, "::", cppIdentPattern, cppKeywords) },
+		(*ast.AstPackageNode).SetCppNamespaceName,
+	},
+	{
+		"go_package", "Go import path, like \"github.com/acme/schemas/addr_book\"",
+		isGoImportPath,
+		(*ast.AstPackageNode).SetGoPackageName,
+	},
+	{
+		"java_package", "Java identifiers separated with '.', like \"com.acme.schemas\"",
+		func(v string) bool { return isQualifiedName(v, ".", javaIdentPattern, javaKeywords) },
+		(*ast.AstPackageNode).SetJavaPackageName,
+	},
+	{
+		"python_module", "Python identifiers separated with '.', like \"acme.schemas\"",
+		func(v string) bool { return isQualifiedName(v, ".", pythonIdentPattern, pythonKeywords) },
+		(*ast.AstPackageNode).SetPythonModuleName,
+	},
+}
+
+func findPackageOption(name string) *packageOption {
+	for i := range packageOptions {
+		if packageOptions[i].name == name {
+			return &packageOptions[i]
+		}
+	}
+	return nil
+}
+
+func optionNames() []string {
+	names := make([]string, len(packageOptions))
+	for i, o := range packageOptions {
+		names[i] = o.name
+	}
+	return names
+}
+
+var (
+	cppIdentPattern    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	javaIdentPattern   = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	pythonIdentPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// the characters allowed in the elements of the Go import path
+	goPathElemPattern = regexp.MustCompile(`^[A-Za-z0-9_.~+-]+$`)
+)
+
+// the names are checked against the reserved words only, the names
+// reserved by the standard libraries are left to the compilers
+var cppKeywords = map[string]bool{
+	"alignas": true, "alignof": true, "and": true, "asm": true, "auto": true,
+	"bool": true, "break": true, "case": true, "catch": true, "char": true,
+	"class": true, "const": true, "constexpr": true, "continue": true,
+	"default": true, "delete": true, "do": true, "double": true, "else": true,
+	"enum": true, "explicit": true, "export": true, "extern": true, "false": true,
+	"float": true, "for": true, "friend": true, "goto": true, "if": true,
+	"inline": true, "int": true, "long": true, "mutable": true, "namespace": true,
+	"new": true, "noexcept": true, "not": true, "nullptr": true, "operator": true,
+	"or": true, "private": true, "protected": true, "public": true,
+	"register": true, "return": true, "short": true, "signed": true,
+	"sizeof": true, "static": true, "struct": true, "switch": true,
+	"template": true, "this": true, "throw": true, "true": true, "try": true,
+	"typedef": true, "typename": true, "union": true, "unsigned": true,
+	"using": true, "virtual": true, "void": true, "volatile": true, "while": true,
+}
+
+var javaKeywords = map[string]bool{
+	"abstract": true, "assert": true, "boolean": true, "break": true, "byte": true,
+	"case": true, "catch": true, "char": true, "class": true, "const": true,
+	"continue": true, "default": true, "do": true, "double": true, "else": true,
+	"enum": true, "extends": true, "final": true, "finally": true, "float": true,
+	"for": true, "goto": true, "if": true, "implements": true, "import": true,
+	"instanceof": true, "int": true, "interface": true, "long": true, "native": true,
+	"new": true, "package": true, "private": true, "protected": true, "public": true,
+	"return": true, "short": true, "static": true, "strictfp": true, "super": true,
+	"switch": true, "synchronized": true, "this": true, "throw": true, "throws": true,
+	"transient": true, "try": true, "void": true, "volatile": true, "while": true,
+	"true": true, "false": true, "null": true,
+}
+
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true,
+	"assert": true, "async": true, "await": true, "break": true, "class": true,
+	"continue": true, "def": true, "del": true, "elif": true, "else": true,
+	"except": true, "finally": true, "for": true, "from": true, "global": true,
+	"if": true, "import": true, "in": true, "is": true, "lambda": true,
+	"nonlocal": true, "not": true, "or": true, "pass": true, "raise": true,
+	"return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+// tells if every part of the name separated with sep is the identifier
+// and not the keyword, the empty parts are not allowed
+func isQualifiedName(name string, sep string, identPattern *regexp.Regexp, keywords map[string]bool) bool {
+	for _, part := range strings.Split(name, sep) {
+		if !identPattern.MatchString(part) || keywords[part] {
+			return false
+		}
+	}
+	return true
+}
+
+func isGoImportPath(path string) bool {
+	for _, elem := range strings.Split(path, "/") {
+		if !goPathElemPattern.MatchString(elem) || elem == "." || elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// the options follow the package declaration, after an error in the
+// option the parser skips to the end of its line
+func (p *fileParser) parseOptions() {
+	for p.peekIsKeyword("option") {
+		line := p.peek().line
+		if err := p.parseOption(); err != nil {
+			p.report(err)
+			p.skipToLineEnd(line)
+		}
+	}
+}
+
+func (p *fileParser) parseOption() error {
+	p.next()
+	nameToken, err := p.expect(tokIdent)
+	if err != nil {
+		return err
+	}
+	option := findPackageOption(nameToken.text)
+	if option == nil {
+		return newUnknownOptionErr(nameToken.line, nameToken.column, nameToken.text)
+	}
+	if _, err := p.expect(tokAssign); err != nil {
+		return err
+	}
+	valueToken, err := p.expect(tokString)
+	if err != nil {
+		return err
+	}
+	if !option.valid(valueToken.text) {
+		return newIncorrectOptionValueErr(valueToken.line, valueToken.column, option.name, valueToken.text, option.format)
+	}
+	ast.AddOption(p.fileNode, option.name, valueToken.text, p.position(nameToken))
+	if p.peek().kind == tokSemicolon {
+		p.next()
+	}
+	return nil
+}
+
+// skips the tokens of the line up to ';' inclusive
+func (p *fileParser) skipToLineEnd(line int) {
+	for t := p.peek(); t.kind != tokEOF && t.line == line; t = p.peek() {
+		p.next()
+		if t.kind == tokSemicolon {
+			return
+		}
+	}
+}
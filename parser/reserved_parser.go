@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Ghytro/sme/ast"
+)
+
+type IncorrectReservedErr struct {
+	SyntaxErr
+}
+
+func newIncorrectReservedErr(line int, column int, desc string) *IncorrectReservedErr {
+	ire := new(IncorrectReservedErr)
+	ire.code = CodeIncorrectReserved
+	ire.line = line
+	ire.column = column
+	ire.description = desc
+	return ire
+}
+
+type FieldNameReservedErr struct {
+	SyntaxErr
+}
+
+func newFieldNameReservedErr(line int, column int, desc string) *FieldNameReservedErr {
+	fnre := new(FieldNameReservedErr)
+	fnre.code = CodeFieldNameReserved
+	fnre.line = line
+	fnre.column = column
+	fnre.description = desc
+	return fnre
+}
+
+type FieldTagReservedErr struct {
+	SyntaxErr
+}
+
+func newFieldTagReservedErr(line int, column int, desc string) *FieldTagReservedErr {
+	ftre := new(FieldTagReservedErr)
+	ftre.code = CodeFieldTagReserved
+	ftre.line = line
+	ftre.column = column
+	ftre.description = desc
+	return ftre
+}
+
+// reports the field declared with the name reserved before it
+func (p *fileParser) fieldNameReservedErr(nameToken token) error {
+	reserved := p.currentStructNode.FindReservedName(nameToken.text)
+	return newFieldNameReservedErr(nameToken.line, nameToken.column, fmt.Sprintf("field name %s is reserved at %s", nameToken.text, reserved.GetPosition()))
+}
+
+// reports the field declared with the tag reserved before it
+func (p *fileParser) fieldTagReservedErr(tagToken token, tag int) error {
+	reserved := p.currentStructNode.FindReservedTag(tag)
+	return newFieldTagReservedErr(tagToken.line, tagToken.column, fmt.Sprintf("field tag @%d is reserved at %s", tag, reserved.GetPosition()))
+}
+
+// 'reserved' is the keyword only when it is followed by the number or
+// the string, so the structs named reserved can still be used as field types
+func (p *fileParser) peekIsReserved() bool {
+	if !p.peekIsKeyword("reserved") || p.pos+1 >= len(p.tokens) {
+		return false
+	}
+	next := p.tokens[p.pos+1].kind
+	return next == tokNumber || next == tokString
+}
+
+func (p *fileParser) parseReserved() error {
+	p.next()
+	for {
+		if err := p.parseReservedItem(); err != nil {
+			return err
+		}
+		if p.peek().kind != tokComma {
+			break
+		}
+		p.next()
+	}
+	if p.peek().kind == tokSemicolon {
+		p.next()
+	}
+	return nil
+}
+
+// the item is either the quoted field name or the tag with the optional
+// last tag of the range after 'to'
+func (p *fileParser) parseReservedItem() error {
+	packageName := p.currentPackageNode.GetName()
+	structName := p.currentStructNode.GetName()
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		if !isIdentifier(t.text) {
+			return newIncorrectReservedErr(t.line, t.column, fmt.Sprintf("incorrect reserved field name: %q", t.text))
+		}
+		fieldNode, err := p.tree.ReserveFieldName(packageName, structName, t.text, p.position(t))
+		switch err {
+		case nil:
+			return nil
+		case ast.ErrFieldNameReserved:
+			return newFieldNameReservedErr(t.line, t.column, fmt.Sprintf("reserved field name %s is used by the field declared at %s", t.text, fieldNode.GetPosition()))
+		}
+		return newSyntaxError(CodeSyntax, t.line, t.column, err.Error())
+	case tokNumber:
+		from, err := parseReservedTag(t)
+		if err != nil {
+			return err
+		}
+		to := from
+		if p.peekIsKeyword("to") {
+			p.next()
+			last := p.next()
+			if to, err = parseReservedTag(last); err != nil {
+				return err
+			}
+			if to < from {
+				return newIncorrectReservedErr(last.line, last.column, fmt.Sprintf("reserved range %d to %d is empty", from, to))
+			}
+		}
+		fieldNode, err := p.tree.ReserveFieldTags(packageName, structName, from, to, p.position(t))
+		switch err {
+		case nil:
+			return nil
+		case ast.ErrFieldTagReserved:
+			return newFieldTagReservedErr(t.line, t.column, fmt.Sprintf("reserved field tag @%d is used by field %s", fieldNode.GetTag(), fieldNode.GetName()))
+		}
+		return newSyntaxError(CodeSyntax, t.line, t.column, err.Error())
+	}
+	return newIncorrectReservedErr(t.line, t.column, fmt.Sprintf("expected reserved field name or tag, but got: %s", t))
+}
+
+func parseReservedTag(t token) (int, error) {
+	if t.kind != tokNumber {
+		return 0, newIncorrectReservedErr(t.line, t.column, fmt.Sprintf("expected reserved field tag, but got: %s", t))
+	}
+	tag, err := strconv.ParseUint(t.text, 10, 16)
+	if err != nil || tag == 0 {
+		return 0, newIncorrectFieldTagErr(t.line, t.column, t.String())
+	}
+	return int(tag), nil
+}
+
+func isIdentifier(s string) bool {
+	if s == "" || !isIdentStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isIdentStart(s[i]) && !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,299 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Ghytro/sme/ast"
+)
+
+type UndeclaredStructErr struct {
+	SyntaxErr
+}
+
+func newUndeclaredStructErr(line int, column int, packageName string, structName string) *UndeclaredStructErr {
+	use := new(UndeclaredStructErr)
+	use.code = CodeUndeclaredStruct
+	use.line = line
+	use.column = column
+	use.description = fmt.Sprintf("struct %s is not declared in package %s", structName, packageName)
+	return use
+}
+
+type RecursiveStructErr struct {
+	SyntaxErr
+}
+
+func newRecursiveStructErr(line int, column int, structName string, path string) *RecursiveStructErr {
+	rse := new(RecursiveStructErr)
+	rse.code = CodeRecursiveStruct
+	rse.line = line
+	rse.column = column
+	rse.description = fmt.Sprintf("struct %s contains itself: %s", structName, path)
+	return rse
+}
+
+// Analyze checks the tree built from all the parsed files. The parser adds
+// the structs referenced by the field types before they are declared, so
+// the references to the enums are resolved here, and the references to the
+// structs and the packages that were never declared are reported at the
+// position of every reference, like the references to the packages
+// the file does not import. The options of the files are set to the tree here
+func Analyze(tree *ast.AstTree, diagnostics *Diagnostics) {
+	applyOptions(tree, diagnostics)
+	resolveEnums(tree, diagnostics)
+	checkImports(tree, diagnostics)
+	for _, p := range tree.GetRoot().GetPackages() {
+		for _, s := range p.GetStructs() {
+			for _, f := range s.GetFields() {
+				checkReferences(tree, f, f.GetFieldType(), diagnostics, make(map[*ast.AstStructNode]bool))
+			}
+		}
+	}
+	checkCycles(tree, diagnostics)
+}
+
+// replaces the references to the enums in the field types with the enum
+// types, the structs that turned out to be enums are removed from the tree
+func resolveEnums(tree *ast.AstTree, diagnostics *Diagnostics) {
+	enumRefs := make(map[*ast.AstStructNode]bool)
+	for _, p := range tree.GetRoot().GetPackages() {
+		for _, s := range p.GetStructs() {
+			for _, f := range s.GetFields() {
+				f.SetFieldType(resolveEnumType(tree, f, f.GetFieldType(), diagnostics, enumRefs))
+			}
+		}
+	}
+	for n := range enumRefs {
+		tree.RemoveUndeclaredStruct(n)
+	}
+}
+
+// the list and map types are shared by the fields with the same
+// qualified type name, so their element types are replaced in place
+func resolveEnumType(tree *ast.AstTree, field *ast.AstStructFieldNode, t ast.SmeType, diagnostics *Diagnostics, enumRefs map[*ast.AstStructNode]bool) ast.SmeType {
+	switch v := t.(type) {
+	case *ast.SmeList:
+		v.SetValueType(resolveEnumType(tree, field, v.ValueType(), diagnostics, enumRefs))
+	case *ast.SmeMap:
+		v.SetKeyType(resolveEnumType(tree, field, v.KeyType(), diagnostics, enumRefs))
+		v.SetValueType(resolveEnumType(tree, field, v.ValueType(), diagnostics, enumRefs))
+	case *ast.SmeOneof:
+		for _, m := range v.GetImplNode().GetMembers() {
+			m.SetFieldType(resolveEnumType(tree, m, m.GetFieldType(), diagnostics, enumRefs))
+		}
+	case *ast.UserDefinedStruct:
+		n := v.GetImplNode()
+		defaultValue, err := v.DefaultValue()
+		hasDefaultValue := err == nil
+		pos := field.GetPosition()
+		if n.IsDeclared() {
+			if hasDefaultValue {
+				diagnostics.AddError(pos.File, newSyntaxError(CodeIncorrectDefaultValue, pos.Line, pos.Column,
+					fmt.Sprintf("struct field %s cannot have default value", field.GetName())))
+			}
+			return v
+		}
+		enumNode, err := tree.GetEnumNode(n.GetPackageName(), n.GetName())
+		if err != nil {
+			return v
+		}
+		enumRefs[n] = true
+		enumType, err := tree.EnumType(enumNode, v.IsOptional(), hasDefaultValue, defaultValue)
+		if err != nil {
+			diagnostics.AddError(pos.File, newSyntaxError(CodeIncorrectDefaultValue, pos.Line, pos.Column,
+				fmt.Sprintf("no value %s in enum %s.%s", defaultValue, enumNode.GetPackageName(), enumNode.GetName())))
+			enumType, _ = tree.EnumType(enumNode, v.IsOptional(), false, "")
+		}
+		return enumType
+	}
+	return t
+}
+
+// reported is used to report the struct once even if it is used
+// in the field type several times, like map[A, list[A]]
+func checkReferences(tree *ast.AstTree, field *ast.AstStructFieldNode, t ast.SmeType, diagnostics *Diagnostics, reported map[*ast.AstStructNode]bool) {
+	switch v := t.(type) {
+	case *ast.SmeList:
+		checkReferences(tree, field, v.ValueType(), diagnostics, reported)
+	case *ast.SmeMap:
+		checkReferences(tree, field, v.KeyType(), diagnostics, reported)
+		checkReferences(tree, field, v.ValueType(), diagnostics, reported)
+	case *ast.SmeOneof:
+		// the members keep the positions of their own references
+		for _, m := range v.GetImplNode().GetMembers() {
+			checkReferences(tree, m, m.GetFieldType(), diagnostics, make(map[*ast.AstStructNode]bool))
+		}
+	case *ast.UserDefinedStruct:
+		n := v.GetImplNode()
+		if n.IsDeclared() || reported[n] {
+			return
+		}
+		reported[n] = true
+		pos := field.GetReferencePosition(n)
+		if !isPackageDeclared(tree, n.GetPackageName()) {
+			diagnostics.AddError(pos.File, newNoSuchPackageErr(pos.Line, pos.Column, n.GetPackageName()))
+			return
+		}
+		diagnostics.AddError(pos.File, newUndeclaredStructErr(pos.Line, pos.Column, n.GetPackageName(), n.GetName()))
+	}
+}
+
+// sets the options of the files to their packages in the order of the files,
+// the option set to the other value than before in the same package is reported
+func applyOptions(tree *ast.AstTree, diagnostics *Diagnostics) {
+	packages := make(map[string]*ast.AstPackageNode)
+	for _, p := range tree.GetRoot().GetPackages() {
+		packages[p.GetName()] = p
+	}
+	set := make(map[string]map[string]*ast.AstOptionNode)
+	for _, f := range tree.GetRoot().GetFiles() {
+		packageNode, ok := packages[f.GetPackageName()]
+		if !ok {
+			continue
+		}
+		if set[f.GetPackageName()] == nil {
+			set[f.GetPackageName()] = make(map[string]*ast.AstOptionNode)
+		}
+		packageSet := set[f.GetPackageName()]
+		for _, o := range f.GetOptions() {
+			prev, ok := packageSet[o.GetName()]
+			if !ok {
+				packageSet[o.GetName()] = o
+				findPackageOption(o.GetName()).set(packageNode, o.GetValue())
+				continue
+			}
+			if prev.GetValue() != o.GetValue() {
+				pos := o.GetPosition()
+				diagnostics.AddError(pos.File, newOptionConflictErr(pos.Line, pos.Column, o.GetName(), o.GetValue(), prev.GetPosition(), prev.GetValue()))
+			}
+		}
+	}
+}
+
+// the file sees its own package and the packages of the files it
+// imports directly, the imports of the imported files are not visible
+func checkImports(tree *ast.AstTree, diagnostics *Diagnostics) {
+	visible := make(map[string]map[string]bool)
+	for _, f := range tree.GetRoot().GetFiles() {
+		packages := map[string]bool{f.GetPackageName(): true}
+		for _, imp := range f.GetImports() {
+			if imported, err := tree.GetFileNode(imp.GetFileName()); err == nil {
+				packages[imported.GetPackageName()] = true
+			}
+		}
+		visible[f.GetName()] = packages
+	}
+	for _, p := range tree.GetRoot().GetPackages() {
+		for _, s := range p.GetStructs() {
+			for _, f := range s.GetFields() {
+				checkFieldImports(tree, f, visible, diagnostics)
+			}
+		}
+	}
+}
+
+// the packages that are not declared at all are reported by checkReferences
+func checkFieldImports(tree *ast.AstTree, field *ast.AstStructFieldNode, visible map[string]map[string]bool, diagnostics *Diagnostics) {
+	if o, ok := field.GetFieldType().(*ast.SmeOneof); ok {
+		for _, m := range o.GetImplNode().GetMembers() {
+			checkFieldImports(tree, m, visible, diagnostics)
+		}
+	}
+	for name, pos := range field.GetReferencePositions() {
+		packageName := name[:strings.LastIndex(name, ".")]
+		packages, ok := visible[pos.File]
+		if !ok || packages[packageName] || !isPackageDeclared(tree, packageName) {
+			continue
+		}
+		diagnostics.AddError(pos.File, newPackageNotImportedErr(pos.Line, pos.Column, packageName))
+	}
+}
+
+func isPackageDeclared(tree *ast.AstTree, packageName string) bool {
+	for _, p := range tree.GetRoot().GetPackages() {
+		if p.GetName() == packageName {
+			return p.IsDeclared()
+		}
+	}
+	return false
+}
+
+func qualifiedStructName(n *ast.AstStructNode) string {
+	return n.GetPackageName() + "." + n.GetName()
+}
+
+// returns the struct the field embeds by value, the optional fields,
+// oneofs, lists and maps can be empty, so they do not make the struct infinite
+func embeddedStruct(f *ast.AstStructFieldNode) *ast.AstStructNode {
+	t, ok := f.GetFieldType().(*ast.UserDefinedStruct)
+	if !ok || t.IsOptional() || !t.GetImplNode().IsDeclared() {
+		return nil
+	}
+	return t.GetImplNode()
+}
+
+type cycleChecker struct {
+	diagnostics *Diagnostics
+	// the structs that are on the path now are in the stack,
+	// the structs with all the paths checked are done
+	inStack map[*ast.AstStructNode]bool
+	done    map[*ast.AstStructNode]bool
+	path    []*ast.AstStructFieldNode
+	structs []*ast.AstStructNode
+}
+
+// reports every struct that embeds itself by value through the chain of
+// the fields, such struct has infinite size in the wire format
+func checkCycles(tree *ast.AstTree, diagnostics *Diagnostics) {
+	c := &cycleChecker{
+		diagnostics: diagnostics,
+		inStack:     make(map[*ast.AstStructNode]bool),
+		done:        make(map[*ast.AstStructNode]bool),
+	}
+	for _, p := range tree.GetRoot().GetPackages() {
+		for _, s := range p.GetStructs() {
+			c.visit(s)
+		}
+	}
+}
+
+func (c *cycleChecker) visit(s *ast.AstStructNode) {
+	if c.done[s] {
+		return
+	}
+	c.inStack[s] = true
+	c.structs = append(c.structs, s)
+	for _, f := range s.GetFields() {
+		next := embeddedStruct(f)
+		if next == nil {
+			continue
+		}
+		c.path = append(c.path, f)
+		if c.inStack[next] {
+			c.report(next)
+		} else {
+			c.visit(next)
+		}
+		c.path = c.path[:len(c.path)-1]
+	}
+	c.structs = c.structs[:len(c.structs)-1]
+	c.inStack[s] = false
+	c.done[s] = true
+}
+
+// the cycle starts at the struct start on the stack and ends with the
+// last field of the path, it is reported at the first field of the cycle
+func (c *cycleChecker) report(start *ast.AstStructNode) {
+	i := len(c.structs) - 1
+	for c.structs[i] != start {
+		i--
+	}
+	var b strings.Builder
+	for j := i; j < len(c.structs); j++ {
+		fmt.Fprintf(&b, "%s.%s -> ", qualifiedStructName(c.structs[j]), c.path[j].GetName())
+	}
+	b.WriteString(qualifiedStructName(start))
+	pos := c.path[i].GetPosition()
+	c.diagnostics.AddError(pos.File, newRecursiveStructErr(pos.Line, pos.Column, qualifiedStructName(start), b.String()))
+}
@@ -0,0 +1,39 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestOptionsArePerPackage(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.sme":  "syntax 0.0.1\n\npackage a\noption go_package = \"github.com/x/a\"\n",
+		"b.sme":  "syntax 0.0.1\n\npackage b\noption go_package = \"github.com/y/b\"\n",
+		"b2.sme": "syntax 0.0.1\n\npackage b\noption go_package = \"github.com/y/b\"\n",
+		"c.sme":  "syntax 0.0.1\n\npackage c\n",
+	})
+	tree, diagnostics := Parse([]string{dir}, DefaultExtensions, 1)
+	if diagnostics.Len() != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diagnostics.Sorted())
+	}
+	want := map[string]string{"a": "github.com/x/a", "b": "github.com/y/b", "c": ""}
+	for _, p := range tree.GetRoot().GetPackages() {
+		got := ""
+		if goPackage := p.GetGoPackageName(); goPackage != nil {
+			got = *goPackage
+		}
+		if got != want[p.GetName()] {
+			t.Errorf("go_package of %s is %q, want %q", p.GetName(), got, want[p.GetName()])
+		}
+	}
+
+	// the files of the same package must agree
+	writeFiles(t, dir, map[string]string{
+		"b3.sme": "syntax 0.0.1\n\npackage b\noption go_package = \"github.com/y/other\"\n",
+	})
+	_, diagnostics = Parse([]string{dir}, DefaultExtensions, 1)
+	got := diagnostics.Sorted()
+	if len(got) != 1 || got[0].Code != CodeOptionConflict {
+		t.Errorf("got %v, want one %s", got, CodeOptionConflict)
+	}
+}
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Ghytro/sme/ast"
+)
+
+type IncorrectFieldTagErr struct {
+	SyntaxErr
+}
+
+func newIncorrectFieldTagErr(line int, column int, got string) *IncorrectFieldTagErr {
+	ifte := new(IncorrectFieldTagErr)
+	ifte.code = CodeIncorrectFieldTag
+	ifte.line = line
+	ifte.column = column
+	ifte.description = fmt.Sprintf("field tag must be a number from 1 to %d, but got: %s", ast.MaxFieldTag, got)
+	return ifte
+}
+
+type FieldTagAlreadyUsedErr struct {
+	SyntaxErr
+}
+
+func newFieldTagAlreadyUsedErr(line int, column int, tag int, fieldName string) *FieldTagAlreadyUsedErr {
+	ftaue := new(FieldTagAlreadyUsedErr)
+	ftaue.code = CodeFieldTagAlreadyUsed
+	ftaue.line = line
+	ftaue.column = column
+	ftaue.description = fmt.Sprintf("tag @%d is already used by field %s", tag, fieldName)
+	return ftaue
+}
+
+type MissingFieldTagErr struct {
+	SyntaxErr
+}
+
+func newMissingFieldTagErr(line int, column int, fieldName string, structName string) *MissingFieldTagErr {
+	mfte := new(MissingFieldTagErr)
+	mfte.code = CodeMissingFieldTag
+	mfte.line = line
+	mfte.column = column
+	mfte.description = fmt.Sprintf("field %s has no tag, but the other fields of struct %s have", fieldName, structName)
+	return mfte
+}
+
+// tells if the next tokens are '=' and '@' starting the tag of the field
+func (p *fileParser) peekIsTag() bool {
+	return p.peek().kind == tokAssign && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == tokAt
+}
+
+// parses '=' '@' number, the tag is checked to fit into uint16
+func (p *fileParser) parseTag() (int, token, error) {
+	p.next()
+	at := p.next()
+	t := p.peek()
+	if t.kind != tokNumber {
+		// the incorrect tag is skipped if it is on the line of '@'
+		if t.line == at.line && t.kind != tokLBrace && t.kind != tokRBrace && t.kind != tokSemicolon && t.kind != tokEOF {
+			p.next()
+		}
+		return 0, t, newIncorrectFieldTagErr(t.line, t.column, t.String())
+	}
+	p.next()
+	tag, err := strconv.ParseUint(t.text, 10, 16)
+	if err != nil || tag == 0 {
+		return 0, t, newIncorrectFieldTagErr(t.line, t.column, t.String())
+	}
+	return int(tag), t, nil
+}
+
+func (p *fileParser) setFieldTag(fieldName string, tag int, tagToken token) error {
+	packageName := p.currentPackageNode.GetName()
+	structName := p.currentStructNode.GetName()
+	fieldNode, err := p.tree.SetFieldTag(packageName, structName, fieldName, tag)
+	switch err {
+	case nil:
+		return nil
+	case ast.ErrFieldTagAlreadyUsed:
+		return newFieldTagAlreadyUsedErr(tagToken.line, tagToken.column, tag, fieldNode.GetName())
+	case ast.ErrFieldTagReserved:
+		return p.fieldTagReservedErr(tagToken, tag)
+	case ast.ErrIncorrectFieldTag:
+		return newIncorrectFieldTagErr(tagToken.line, tagToken.column, tagToken.String())
+	}
+	return newSyntaxError(CodeSyntax, tagToken.line, tagToken.column, err.Error())
+}
+
+// the layout of the struct is either positional or tagged,
+// so in the tagged struct every field must have the tag
+func (p *fileParser) checkFieldTags(structNode *ast.AstStructNode) {
+	if !structNode.IsTagged() {
+		return
+	}
+	for _, t := range p.untaggedFields {
+		p.report(newMissingFieldTagErr(t.line, t.column, t.text, structNode.GetName()))
+	}
+}
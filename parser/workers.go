@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/Ghytro/sme/ast"
+)
+
+// parsedFile is the file parsed by the worker into its own tree,
+// the tree is nil if the file can not be read
+type parsedFile struct {
+	fileName     string
+	tree         *ast.AstTree
+	syntaxVerPos ast.Position
+	err          error
+}
+
+func (f *parsedFile) fileNode() *ast.AstFileNode {
+	if f.tree == nil {
+		return nil
+	}
+	fileNode, err := f.tree.GetFileNode(f.fileName)
+	if err != nil {
+		// the file has no correct header
+		return nil
+	}
+	return fileNode
+}
+
+// parses the files and the files they import with the worker pool, the
+// imported files are found only after the importing files are parsed, so
+// they are parsed by the next rounds
+func (l *importLoader) prefetch(fileNames []string) {
+	for len(fileNames) != 0 {
+		l.parseAll(fileNames)
+		var imported []string
+		for _, fileName := range fileNames {
+			fileNode := l.parsed[filepath.Clean(fileName)].fileNode()
+			if fileNode == nil {
+				continue
+			}
+			for _, imp := range fileNode.GetImports() {
+				if _, ok := l.parsed[imp.GetFileName()]; !ok && l.exists(imp.GetFileName()) {
+					imported = append(imported, imp.GetFileName())
+				}
+			}
+		}
+		fileNames = imported
+	}
+}
+
+// parses the files that are not parsed yet, up to l.jobs files at a time.
+// The workers share nothing but the overlay, which is only read, every
+// file gets its own tree with its own type pool
+func (l *importLoader) parseAll(fileNames []string) {
+	var queue []string
+	for _, fileName := range fileNames {
+		fileName = filepath.Clean(fileName)
+		if _, ok := l.parsed[fileName]; !ok {
+			l.parsed[fileName] = nil
+			queue = append(queue, fileName)
+		}
+	}
+	results := make([]*parsedFile, len(queue))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < l.jobs && w < len(queue); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = l.parseFile(queue[i])
+			}
+		}()
+	}
+	for i := range queue {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	for i, f := range results {
+		l.parsed[queue[i]] = f
+	}
+}
+
+func (l *importLoader) parseFile(fileName string) *parsedFile {
+	f := &parsedFile{fileName: fileName}
+	file, err := l.open(fileName)
+	if err != nil {
+		f.err = err
+		return f
+	}
+	defer file.Close()
+	p, err := newFileParser(ast.NewAstTree(), fileName, file)
+	if err != nil {
+		f.err = err
+		return f
+	}
+	p.parseFile()
+	f.tree = p.tree
+	f.syntaxVerPos = p.syntaxVerPos
+	f.err = p.err()
+	return f
+}
+
+// adds the parsed file to the tree of the loader in the order the files
+// are loaded, so the tree does not depend on the order the workers finish
+// in. The syntax version and the structs and the enums declared by the
+// other files are checked here, the file with the other syntax version
+// is not added. Returns the node of the added file
+func (l *importLoader) merge(f *parsedFile) *ast.AstFileNode {
+	fileNode := f.fileNode()
+	if fileNode == nil {
+		return nil
+	}
+	root := l.tree.GetRoot()
+	syntaxVer := f.tree.GetRoot().GetSyntaxVer()
+	if treeVer := root.GetSyntaxVer(); treeVer == "" {
+		root.SetSyntaxVer(syntaxVer)
+	} else if treeVer != syntaxVer {
+		pos := f.syntaxVerPos
+		l.diagnostics.AddError(f.fileName, newSyntaxVerMismatchErr(pos.Line, pos.Column, syntaxVer, treeVer))
+		return nil
+	}
+	for _, c := range l.tree.MergeFile(f.tree) {
+		pos := c.Position
+		if c.Err == ast.ErrEnumAlreadyExists {
+			l.diagnostics.AddError(f.fileName, newEnumAlreadyExistsErr(pos.Line, pos.Column, c.Name, c.Declared))
+		} else {
+			l.diagnostics.AddError(f.fileName, newStructAlreadyExistsErr(pos.Line, pos.Column, c.Name, c.Declared))
+		}
+	}
+	return fileNode
+}
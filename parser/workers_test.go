@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Ghytro/sme/ast"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		fileName := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// describes the tree in the order of its nodes,
+// so the trees built in the other order differ
+func describeTree(tree *ast.AstTree) []string {
+	if tree == nil {
+		return nil
+	}
+	var result []string
+	for _, f := range tree.GetRoot().GetFiles() {
+		result = append(result, "file "+f.GetName()+" "+f.GetPackageName())
+	}
+	for _, p := range tree.GetRoot().GetPackages() {
+		result = append(result, "package "+p.GetName())
+		for _, s := range p.GetStructs() {
+			result = append(result, fmt.Sprintf("struct %s %v", s.GetName(), s.GetPosition()))
+			for _, f := range s.GetFields() {
+				result = append(result, fmt.Sprintf("field %s %s %v", f.GetName(), ast.TypeName(f.GetFieldType()), f.GetPosition()))
+			}
+		}
+		for _, e := range p.GetEnums() {
+			result = append(result, fmt.Sprintf("enum %s %s", e.GetName(), ast.TypeName(e.GetUnderlyingType())))
+			for _, v := range e.GetValues() {
+				result = append(result, "value "+v.GetName()+" "+v.GetNumber())
+			}
+		}
+	}
+	return result
+}
+
+func TestParseDoesNotDependOnJobs(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"common/geo.sme": "syntax 0.0.1\n\npackage geo\n\nstruct Point {\n    double lat, lon\n}\n",
+		"common/units.sme": "syntax 0.0.1\n\npackage geo\n\nenum Unit : uint8 {\n    METER,\n    MILE\n}\n" +
+			"\nstruct Distance {\n    double value\n    Unit unit = METER\n}\n",
+		"shop.sme": "syntax 0.0.1\nimport \"common/geo.sme\"\n\npackage shop\n\nstruct Store {\n    geo.Point location\n" +
+			"    list[Item] items\n}\n",
+		"item.sme":   "syntax 0.0.1\n\npackage shop\n\nstruct Item {\n    string name\n    uint32 price = 100\n}\n",
+		"order.sme":  "syntax 0.0.1\n\npackage shop\n\nstruct Order {\n    map[string, Item] items\n    optional Store store\n}\n",
+		"broken.sme": "syntax 0.0.1\n\npackage shop\n\nstruct Broken {\n    int8 x = 1000\n    Missing m\n}\n",
+		"other.stme": "syntax 0.0.1\n\npackage other\n\nstruct Other {\n    geo.Distance d\n}\n",
+	}
+	writeFiles(t, dir, files)
+
+	sequentialTree, sequentialDiagnostics := Parse([]string{dir}, DefaultExtensions, 1)
+	wantTree := describeTree(sequentialTree)
+	wantDiagnostics := sequentialDiagnostics.Sorted()
+	if len(wantDiagnostics) == 0 {
+		t.Fatal("the schema is expected to have errors")
+	}
+	for _, jobs := range []int{2, 4, 16} {
+		t.Run(fmt.Sprintf("j%d", jobs), func(t *testing.T) {
+			// the workers may finish in any order, so the parse is repeated
+			for i := 0; i < 10; i++ {
+				tree, diagnostics := Parse([]string{dir}, DefaultExtensions, jobs)
+				if got := describeTree(tree); !reflect.DeepEqual(got, wantTree) {
+					t.Fatalf("tree differs from -j 1:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(wantTree, "\n"))
+				}
+				if got := diagnostics.Sorted(); !reflect.DeepEqual(got, wantDiagnostics) {
+					t.Fatalf("diagnostics differ from -j 1:\n%v\nwant:\n%v", got, wantDiagnostics)
+				}
+			}
+		})
+	}
+}
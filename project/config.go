@@ -0,0 +1,85 @@
+// Package project reads sme.yaml, the config of the project with the
+// directories of its schema and the code generated from it, so every
+// target is generated in a single run of sme without the flags:
+//
+//	roots:
+//	  - ./schemas
+//	targets:
+//	  - lang: cpp
+//	    outDir: ./gen/cpp
+//	    options:
+//	      namespace: acme::schemas
+//	  - lang: go
+//	    outDir: ./gen/go
+//	    options:
+//	      package: github.com/acme/app/gen/go
+//
+// The options are passed to the generator of the target as its parameters.
+package project
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is used by sme if it exists and no other config is given
+const DefaultConfigFile = "sme.yaml"
+
+type Config struct {
+	// the directories and the files of the schema
+	Roots []string `yaml:"roots"`
+	// the extensions of the sme files taken from the directories
+	Extensions []string `yaml:"extensions"`
+	Targets    []Target `yaml:"targets"`
+}
+
+// Target is the language generated into the directory
+type Target struct {
+	Lang    string            `yaml:"lang"`
+	OutDir  string            `yaml:"outDir"`
+	Options map[string]string `yaml:"options"`
+}
+
+// Load reads the config from the YAML file, the relative paths of the
+// config are resolved from the directory of the file, the unknown keys
+// and the targets without the language or the directory are the errors
+func Load(fileName string) (*Config, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("incorrect project config %s: %w", fileName, err)
+	}
+	dir := filepath.Dir(fileName)
+	for i, root := range config.Roots {
+		config.Roots[i] = resolve(dir, root)
+	}
+	for i := range config.Targets {
+		t := &config.Targets[i]
+		if t.Lang == "" {
+			return nil, fmt.Errorf("incorrect project config %s: target %d has no lang", fileName, i+1)
+		}
+		if t.OutDir == "" {
+			return nil, fmt.Errorf("incorrect project config %s: target %s has no outDir", fileName, t.Lang)
+		}
+		t.OutDir = resolve(dir, t.OutDir)
+	}
+	return config, nil
+}
+
+func resolve(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}